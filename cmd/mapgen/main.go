@@ -0,0 +1,116 @@
+// mapgen показывает, что генератор строит для сида, без запуска сервера и без игры.
+//
+//	mapgen [-seed 1] [-level 1] [-format ascii|png|json] [-scale 8] [-o out] [-content dir]
+//	mapgen -count 50 [-seed 1] [-level 1] [-format png] [-dir mapgen] [-content dir]
+//
+// Сид - мастер-сид мира, как у сервера (-seed): сид уровня выводится из него так же.
+// Паки контента читаются из той же папки, что и у сервера (-content).
+// ascii - карта с символами сущностей, png - клетка карты цветным квадратом scale x scale,
+// json - типы клеток и сущности. Скрытые ловушки на превью видны.
+// С -count > 1 дампятся сиды seed..seed+count-1, каждый в свой файл в -dir.
+package main
+
+import (
+	"bufio"
+	"cognitive-server/internal/cli"
+	"cognitive-server/internal/engine"
+	"cognitive-server/pkg/logger"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	seed := flag.Int64("seed", 1, "Master seed (the server's -seed)")
+	level := flag.Int("level", 1, "Level depth (0 - surface town)")
+	format := flag.String("format", "ascii", "Output format: ascii, png or json")
+	scale := flag.Int("scale", 8, "PNG pixels per tile")
+	out := flag.String("o", "", "Output file (default stdout)")
+	count := flag.Int("count", 1, "Batch mode: how many consecutive seeds to dump")
+	dir := flag.String("dir", "mapgen", "Batch mode: output directory")
+	contentDir := cli.ContentFlag()
+	verbose := flag.Bool("v", false, "Show generator logs")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-seed 1] [-level 1] [-format ascii|png|json] [-scale 8] [-o out] [-content dir]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s -count N [-seed 1] [-level 1] [-format ascii|png|json] [-dir mapgen] [-content dir]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	render, ok := renderers[*format]
+	if flag.NArg() != 0 || !ok || *level < 0 || *scale <= 0 || *count < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger.Init()
+	logger.Log.SetOutput(os.Stderr)
+	if !*verbose {
+		logger.Log.SetLevel(logrus.FatalLevel)
+	}
+
+	cli.LoadContent(*contentDir)
+	opts := renderOptions{Scale: *scale}
+
+	if *count == 1 {
+		if err := writeTo(*out, func(w io.Writer) error {
+			return render(w, generate(*seed, *level), opts)
+		}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for s := *seed; s < *seed+int64(*count); s++ {
+		path := filepath.Join(*dir, fmt.Sprintf("seed_%d_level_%d.%s", s, *level, extensions[*format]))
+		if err := writeTo(path, func(w io.Writer) error {
+			return render(w, generate(s, *level), opts)
+		}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d maps written to %s\n", *count, *dir)
+}
+
+// generate строит уровень тем же менеджером уровней, что и сервер
+func generate(seed int64, level int) levelMap {
+	levels := engine.NewLevelManager(nil, seed)
+	world, entities, start := levels.Generate(level)
+	return levelMap{
+		Seed:      seed,
+		Level:     level,
+		LevelSeed: levels.Seed(level),
+		Recipe:    levels.Recipe(level),
+		World:     world,
+		Entities:  entities,
+		Start:     start,
+	}
+}
+
+// writeTo пишет в файл path или в stdout, если путь пустой
+func writeTo(path string, write func(io.Writer) error) error {
+	var w io.Writer = os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	buf := bufio.NewWriter(w)
+	if err := write(buf); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
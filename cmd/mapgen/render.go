@@ -0,0 +1,203 @@
+package main
+
+import (
+	"cognitive-server/internal/domain"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// levelMap - сгенерированный уровень и откуда он взялся
+type levelMap struct {
+	Seed      int64
+	Level     int
+	LevelSeed int64
+	Recipe    string
+	World     *domain.GameWorld
+	Entities  []domain.Entity
+	Start     domain.Position
+}
+
+type renderOptions struct {
+	Scale int // PNG: пикселей на клетку
+}
+
+var renderers = map[string]func(io.Writer, levelMap, renderOptions) error{
+	"ascii": renderASCII,
+	"png":   renderPNG,
+	"json":  renderJSON,
+}
+
+var extensions = map[string]string{"ascii": "txt", "png": "png", "json": "json"}
+
+// tileLook - символ и цвет клетки на превью. Скрытая ловушка показывается сработавшей:
+// дизайнеру нужно ее видеть.
+func tileLook(t domain.TileType) (string, string) {
+	def := t.Def()
+	if def.OnEnter == domain.TileEffectTrap {
+		def = def.Sprung.Def()
+	}
+	return def.Symbol, def.Color
+}
+
+// layered - сущности в порядке отрисовки: предметы, затем остальные, выходы поверх всех,
+// чтобы было видно, где лестницы
+func layered(entities []domain.Entity) []domain.Entity {
+	var items, others, exits []domain.Entity
+	for _, e := range entities {
+		switch {
+		case e.Render == nil:
+		case e.Type == domain.EntityTypeItem:
+			items = append(items, e)
+		case e.Type == domain.EntityTypeExit:
+			exits = append(exits, e)
+		default:
+			others = append(others, e)
+		}
+	}
+	return append(append(items, others...), exits...)
+}
+
+// renderASCII - строка заголовка и карта с символами сущностей поверх клеток
+func renderASCII(w io.Writer, m levelMap, _ renderOptions) error {
+	grid := make([][]byte, m.World.Height)
+	for y := range grid {
+		grid[y] = make([]byte, m.World.Width)
+		for x := range grid[y] {
+			symbol, _ := tileLook(m.World.Map[y][x].Type)
+			grid[y][x] = symbol[0]
+		}
+	}
+	for _, e := range layered(m.Entities) {
+		if inside(m.World, e.Pos) {
+			grid[e.Pos.Y][e.Pos.X] = e.Render.Symbol
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(header(m) + "\n")
+	for _, row := range grid {
+		sb.Write(row)
+		sb.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// header - сид, глубина и рецепт уровня
+func header(m levelMap) string {
+	return "seed " + strconv.FormatInt(m.Seed, 10) + "  level " + strconv.Itoa(m.Level) +
+		"  recipe " + m.Recipe + "  level seed " + strconv.FormatInt(m.LevelSeed, 10)
+}
+
+// renderPNG - клетка карты квадратом Scale x Scale цвета клетки, сущности - квадратом своего цвета
+func renderPNG(w io.Writer, m levelMap, opts renderOptions) error {
+	s := opts.Scale
+	img := image.NewRGBA(image.Rect(0, 0, m.World.Width*s, m.World.Height*s))
+	fill := func(pos domain.Position, c color.RGBA) {
+		for y := pos.Y * s; y < (pos.Y+1)*s; y++ {
+			for x := pos.X * s; x < (pos.X+1)*s; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+
+	for y := range m.World.Height {
+		for x := range m.World.Width {
+			_, hex := tileLook(m.World.Map[y][x].Type)
+			c, _ := parseColor(hex)
+			fill(domain.Position{X: x, Y: y}, c)
+		}
+	}
+	for _, e := range layered(m.Entities) {
+		// Цвета вида "text-gray-500" (классы клиента) на картинке не нарисовать - такие сущности белые
+		c, ok := parseColor(e.Render.Color)
+		if !ok {
+			c = color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}
+		}
+		if inside(m.World, e.Pos) {
+			fill(e.Pos, c)
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// parseColor разбирает "#RRGGBB"
+func parseColor(hex string) (color.RGBA, bool) {
+	if len(hex) != 7 || hex[0] != '#' {
+		return color.RGBA{A: 0xFF}, false
+	}
+	rgb, err := strconv.ParseUint(hex[1:], 16, 32)
+	if err != nil {
+		return color.RGBA{A: 0xFF}, false
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xFF}, true
+}
+
+// jsonMap - уровень в формате json: ряды типов клеток и сущности
+type jsonMap struct {
+	Seed      int64             `json:"seed"`
+	Level     int               `json:"level"`
+	LevelSeed int64             `json:"levelSeed"`
+	Recipe    string            `json:"recipe"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Start     domain.Position   `json:"start"`
+	Tiles     [][]string        `json:"tiles"`
+	Entities  []jsonMapEntity   `json:"entities"`
+	Legend    map[string]string `json:"legend"` // Тип клетки -> символ в ascii
+}
+
+type jsonMapEntity struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Name     string          `json:"name"`
+	Template string          `json:"template,omitempty"`
+	Pos      domain.Position `json:"pos"`
+	Symbol   string          `json:"symbol,omitempty"`
+	Color    string          `json:"color,omitempty"`
+}
+
+// renderJSON - типы клеток по рядам (скрытая ловушка - "trap_hidden") и сущности
+func renderJSON(w io.Writer, m levelMap, _ renderOptions) error {
+	out := jsonMap{
+		Seed:      m.Seed,
+		Level:     m.Level,
+		LevelSeed: m.LevelSeed,
+		Recipe:    m.Recipe,
+		Width:     m.World.Width,
+		Height:    m.World.Height,
+		Start:     m.Start,
+		Tiles:     make([][]string, m.World.Height),
+		Entities:  make([]jsonMapEntity, 0, len(m.Entities)),
+		Legend:    make(map[string]string),
+	}
+	for y, row := range m.World.Map {
+		out.Tiles[y] = make([]string, len(row))
+		for x, tile := range row {
+			name := tile.Type.String()
+			if tile.Type.Def().OnEnter == domain.TileEffectTrap {
+				name = "trap_hidden"
+			}
+			out.Tiles[y][x] = name
+			out.Legend[name], _ = tileLook(tile.Type)
+		}
+	}
+	for _, e := range m.Entities {
+		je := jsonMapEntity{ID: e.ID, Type: e.Type.String(), Name: e.Name, Template: e.TemplateID, Pos: e.Pos}
+		if e.Render != nil {
+			je.Symbol, je.Color = string(e.Render.Symbol), e.Render.Color
+		}
+		out.Entities = append(out.Entities, je)
+	}
+
+	return json.NewEncoder(w).Encode(out)
+}
+
+func inside(w *domain.GameWorld, pos domain.Position) bool {
+	return pos.X >= 0 && pos.Y >= 0 && pos.X < w.Width && pos.Y < w.Height
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"cognitive-server/internal/domain"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testMap() levelMap {
+	world := &domain.GameWorld{Width: 3, Height: 2, Map: make([][]domain.Tile, 2)}
+	for y := range world.Map {
+		world.Map[y] = make([]domain.Tile, 3)
+	}
+	world.Map[0][0].SetType(domain.TileStone)
+	world.Map[1][2].SetType(domain.TileTrap)
+
+	item := domain.Entity{Type: domain.EntityTypeItem, Pos: domain.Position{X: 1}, Render: &domain.RenderComponent{Symbol: '!'}}
+	goblin := domain.Entity{Type: domain.EntityTypeEnemy, Pos: domain.Position{X: 1}, Render: &domain.RenderComponent{Symbol: 'g', Color: "#22C55E"}}
+	return levelMap{Seed: 7, Level: 2, World: world, Entities: []domain.Entity{goblin, item}}
+}
+
+func TestRenderASCII_EntitiesOverTiles(t *testing.T) {
+	var buf bytes.Buffer
+	if err := renderASCII(&buf, testMap(), renderOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	// Гоблин закрывает предмет, скрытая ловушка на превью видна
+	if got := lines[1:]; len(got) != 2 || got[0] != "#g." || got[1] != "..^" {
+		t.Errorf("map = %q", got)
+	}
+}
+
+func TestRenderPNG_CellPerTile(t *testing.T) {
+	var buf bytes.Buffer
+	if err := renderPNG(&buf, testMap(), renderOptions{Scale: 4}); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 12 || b.Dy() != 8 {
+		t.Errorf("size = %v", b)
+	}
+	if r, g, b, _ := img.At(5, 2).RGBA(); r>>8 != 0x22 || g>>8 != 0xC5 || b>>8 != 0x5E {
+		t.Errorf("goblin cell color = %x %x %x", r>>8, g>>8, b>>8)
+	}
+}
+
+// mapgen строит тот же уровень, что и сервер: одинаковый сид - одинаковая карта
+func TestGenerate_Deterministic(t *testing.T) {
+	for _, level := range []int{0, 3} {
+		var a, b bytes.Buffer
+		if err := renderJSON(&a, generate(11, level), renderOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := renderJSON(&b, generate(11, level), renderOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(a.Bytes(), b.Bytes()) {
+			t.Errorf("level %d differs between runs", level)
+		}
+	}
+}
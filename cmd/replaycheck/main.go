@@ -0,0 +1,156 @@
+// replaycheck проигрывает записанные реплеи и сверяет хеши состояния с записанными.
+// Используется перед релизом, чтобы поймать регрессии детерминизма.
+//
+//	replaycheck [-v] [-failfast] [-content dir] <file.cdrp|dir>...
+//
+// Коды выхода: 0 - все реплеи сошлись, 1 - найдено расхождение, 2 - ошибка загрузки или аргументов.
+package main
+
+import (
+	"cognitive-server/internal/cli"
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/engine"
+	"cognitive-server/pkg/logger"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	exitOK     = 0
+	exitDesync = 1
+	exitError  = 2
+)
+
+func main() {
+	verbose := flag.Bool("v", false, "Show engine logs")
+	failFast := flag.Bool("failfast", false, "Stop at the first divergent replay")
+	contentDir := cli.ContentFlag()
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-v] [-failfast] [-content dir] <file.cdrp|dir>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(exitError)
+	}
+
+	logger.Init()
+	logger.Log.SetOutput(os.Stderr)
+	if !*verbose {
+		logger.Log.SetLevel(logrus.FatalLevel)
+	}
+
+	cli.LoadContent(*contentDir)
+
+	files, err := collectReplays(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "no .cdrp files found")
+		os.Exit(exitError)
+	}
+
+	code := exitOK
+	for _, path := range files {
+		result := checkReplay(path)
+		if result > code {
+			code = result
+		}
+		if result != exitOK && *failFast {
+			break
+		}
+	}
+	os.Exit(code)
+}
+
+// collectReplays раскрывает аргументы: файлы берутся как есть, директории обходятся рекурсивно
+func collectReplays(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(d.Name(), ".cdrp") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// checkReplay проигрывает один файл и печатает результат. Возвращает код выхода для него.
+func checkReplay(path string) int {
+	service := engine.NewPlaybackService()
+	if err := service.LoadReplay(path); err != nil {
+		fmt.Printf("ERROR  %s: %v\n", path, err)
+		return exitError
+	}
+
+	result, err := service.PlayReplay(true)
+	if err != nil {
+		fmt.Printf("ERROR  %s: %v\n", path, err)
+		return exitError
+	}
+
+	// Оборванный файл (падение сервера) проверяем по тому, что удалось восстановить
+	note := ""
+	if service.Playback.Truncated {
+		note = " (truncated, recovered up to the last valid block)"
+	}
+
+	if len(result.Desyncs) == 0 {
+		fmt.Printf("OK     %s: %d segments, %d actions, %d checkpoints%s\n",
+			path, result.Segments, result.Actions, result.Checkpoints, note)
+		return exitOK
+	}
+
+	d := result.Desyncs[0]
+	fmt.Printf("DESYNC %s%s\n", path, note)
+	fmt.Printf("  reason:   %s\n", d.Reason)
+	fmt.Printf("  segment:  %d (level %d)\n", d.Segment, d.Level)
+	fmt.Printf("  action:   %d\n", d.Action)
+	fmt.Printf("  tick:     %d\n", d.Tick)
+	fmt.Printf("  actor:    %s\n", d.Actor)
+	if d.Reason == engine.DesyncStateHash {
+		fmt.Printf("  expected: %016x\n", d.Expected)
+		fmt.Printf("  actual:   %016x\n", d.Actual)
+	}
+
+	changes, err := domain.DiffEntities(d.Before, d.After)
+	if err != nil {
+		fmt.Printf("  diff unavailable: %v\n", err)
+		return exitDesync
+	}
+	if len(changes) == 0 {
+		fmt.Println("  no entity changes since the last matching state")
+		return exitDesync
+	}
+	fmt.Println("  entity changes since the last matching state:")
+	for _, c := range changes {
+		fmt.Printf("    %s\n", c)
+	}
+	return exitDesync
+}
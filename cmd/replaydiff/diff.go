@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/engine"
+	"fmt"
+)
+
+// Причины расхождения
+const (
+	reasonEnded  = "one replay ended earlier"
+	reasonInput  = "recorded inputs differ"
+	reasonLevel  = "replays are on different levels"
+	reasonTick   = "level time differs"
+	reasonEntity = "entity state differs"
+)
+
+// divergence - первое место, где реплеи разошлись
+type divergence struct {
+	Step    int // Записанных действий, выполненных к моменту расхождения
+	Reason  string
+	A, B    engine.ReplayPosition
+	Changes []string // Поля сущностей, которые отличаются, в виде "a -> b"
+}
+
+// findDivergence проигрывает оба реплея в ногу, по одному записанному действию,
+// и после каждого шага сравнивает состояние. nil - реплеи сошлись до конца.
+func findDivergence(a, b *engine.ReplayCursor) (*divergence, error) {
+	for done := false; ; {
+		if reason := compareCursors(a, b); reason != "" {
+			return newDivergence(a, b, reason)
+		}
+		if done {
+			return nil, nil
+		}
+		moreA, moreB := a.Next(), b.Next()
+		done = !moreA && !moreB
+	}
+}
+
+// compareCursors возвращает причину расхождения или пустую строку
+func compareCursors(a, b *engine.ReplayCursor) string {
+	pa, pb := a.Position(), b.Position()
+	switch {
+	case pa.Step != pb.Step:
+		return reasonEnded
+	case !sameAction(a, b, pa.Step-1):
+		return reasonInput
+	case pa.Level != pb.Level:
+		return reasonLevel
+	case pa.Tick != pb.Tick:
+		return reasonTick
+	case a.StateHash() != b.StateHash():
+		return reasonEntity
+	}
+	return ""
+}
+
+func newDivergence(a, b *engine.ReplayCursor, reason string) (*divergence, error) {
+	d := &divergence{Reason: reason, A: a.Position(), B: b.Position()}
+	d.Step = min(d.A.Step, d.B.Step)
+	if d.A.Level != d.B.Level {
+		return d, nil // Разные уровни построчно сравнивать бессмысленно
+	}
+
+	before, err := a.Entities()
+	if err != nil {
+		return nil, err
+	}
+	after, err := b.Entities()
+	if err != nil {
+		return nil, err
+	}
+	if d.Changes, err = domain.DiffEntities(before, after); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// sameAction сравнивает записанное действие номер step в обоих реплеях (до начала записи все совпадает)
+func sameAction(a, b *engine.ReplayCursor, step int) bool {
+	if step < 0 {
+		return true
+	}
+	actA, okA := a.Action(step)
+	actB, okB := b.Action(step)
+	return okA == okB && equalActions(actA, actB)
+}
+
+func equalActions(a, b domain.ReplayAction) bool {
+	return a.Tick == b.Tick &&
+		a.Token == b.Token &&
+		a.Action == b.Action &&
+		a.Controller == b.Controller &&
+		bytes.Equal(a.Payload, b.Payload)
+}
+
+// formatAction - действие одной строкой: время, кто, что и payload как есть
+func formatAction(act domain.ReplayAction) string {
+	line := fmt.Sprintf("t=%d %s %s (%s)", act.Tick, act.Token, act.Action, act.Controller)
+	if len(act.Payload) > 0 {
+		line += " " + string(act.Payload)
+	}
+	return line
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"cognitive-server/internal/engine"
+	"cognitive-server/pkg/logger"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+const corpus = "../../internal/engine/testdata/replays/"
+
+func openCursor(t *testing.T, name string) *engine.ReplayCursor {
+	t.Helper()
+	c, err := engine.OpenReplay(corpus + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestFindDivergence(t *testing.T) {
+	logger.Init()
+	logger.Log.SetLevel(logrus.FatalLevel)
+
+	d, err := findDivergence(openCursor(t, "solo_descent.cdrp"), openCursor(t, "solo_descent.cdrp"))
+	if err != nil || d != nil {
+		t.Fatalf("same replay diverged: %+v (%v)", d, err)
+	}
+
+	// Разные забеги одного сервера расходятся, и место расхождения указывает на реальный шаг
+	d, err = findDivergence(openCursor(t, "duo_hero_1.cdrp"), openCursor(t, "duo_hero_2.cdrp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d == nil || d.Reason == "" || d.Step > min(d.A.Total, d.B.Total) {
+		t.Errorf("expected a divergence within both replays, got %+v", d)
+	}
+}
@@ -0,0 +1,118 @@
+// replaydiff ищет первое расхождение двух реплеев одного сида, например записанных
+// разными сборками или на разных машинах. Оба проигрываются в ногу через Instance,
+// и после каждого записанного действия сравнивается состояние уровня.
+//
+//	replaydiff [-context 3] [-v] [-content dir] <a.cdrp> <b.cdrp>
+//
+// Коды выхода: 0 - реплеи совпали, 1 - найдено расхождение, 2 - ошибка загрузки или аргументов.
+package main
+
+import (
+	"cognitive-server/internal/cli"
+	"cognitive-server/internal/engine"
+	"cognitive-server/pkg/logger"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	exitSame     = 0
+	exitDiverged = 1
+	exitError    = 2
+)
+
+func main() {
+	context := flag.Int("context", 3, "Recorded actions to show around the divergence")
+	verbose := flag.Bool("v", false, "Show engine logs")
+	contentDir := cli.ContentFlag()
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-context 3] [-v] [-content dir] <a.cdrp> <b.cdrp>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 || *context < 0 {
+		flag.Usage()
+		os.Exit(exitError)
+	}
+
+	logger.Init()
+	logger.Log.SetOutput(os.Stderr)
+	if !*verbose {
+		logger.Log.SetLevel(logrus.FatalLevel)
+	}
+
+	cli.LoadContent(*contentDir)
+
+	a, err := engine.OpenReplay(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", flag.Arg(0), err)
+		os.Exit(exitError)
+	}
+	b, err := engine.OpenReplay(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", flag.Arg(1), err)
+		os.Exit(exitError)
+	}
+
+	d, err := findDivergence(a, b)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+	if d == nil {
+		pos := a.Position()
+		fmt.Printf("SAME     %d actions, %d segments\n", pos.Step, pos.Segments)
+		os.Exit(exitSame)
+	}
+
+	printDivergence(os.Stdout, a, b, d, *context)
+	os.Exit(exitDiverged)
+}
+
+// printDivergence печатает место расхождения, соседние записанные действия и отличающиеся поля
+func printDivergence(w io.Writer, a, b *engine.ReplayCursor, d *divergence, context int) {
+	fmt.Fprintf(w, "DIVERGED after %d actions: %s\n", d.Step, d.Reason)
+	fmt.Fprintf(w, "  a: segment %d/%d, level %d, tick %d, step %d/%d\n",
+		d.A.Segment, d.A.Segments, d.A.Level, d.A.Tick, d.A.Step, d.A.Total)
+	fmt.Fprintf(w, "  b: segment %d/%d, level %d, tick %d, step %d/%d\n",
+		d.B.Segment, d.B.Segments, d.B.Level, d.B.Tick, d.B.Step, d.B.Total)
+
+	// Последнее выполненное действие - step-1, с него и началось расхождение
+	last := d.Step - 1
+	fmt.Fprintln(w, "  actions:")
+	for step := max(0, last-context); step <= last+context; step++ {
+		actA, okA := a.Action(step)
+		actB, okB := b.Action(step)
+		if !okA && !okB {
+			break
+		}
+
+		mark := " "
+		if step == last {
+			mark = ">"
+		}
+		if okA && okB && equalActions(actA, actB) {
+			fmt.Fprintf(w, "  %s   %6d %s\n", mark, step, formatAction(actA))
+			continue
+		}
+		if okA {
+			fmt.Fprintf(w, "  %s a %6d %s\n", mark, step, formatAction(actA))
+		}
+		if okB {
+			fmt.Fprintf(w, "  %s b %6d %s\n", mark, step, formatAction(actB))
+		}
+	}
+
+	if len(d.Changes) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "  entity fields (a -> b):")
+	for _, c := range d.Changes {
+		fmt.Fprintf(w, "    %s\n", c)
+	}
+}
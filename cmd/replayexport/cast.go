@@ -0,0 +1,244 @@
+package main
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/engine"
+	"cognitive-server/pkg/api"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Темп записи такой же, как у зрителя реплея на сервере (/ws/replay)
+const (
+	castTickDuration = 2 * time.Millisecond
+	castMaxDelay     = time.Second
+	castLogLines     = 3 // Последние сообщения лога под картой
+)
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiDim   = "\x1b[2m"
+	ansiClear = "\x1b[2J\x1b[H"
+)
+
+type castOptions struct {
+	Entity     string // Чьими глазами смотрим ("" - владелец забега)
+	Omniscient bool
+	Speed      float64
+	Color      bool
+}
+
+// castHeader - первая строка файла asciicast v2
+type castHeader struct {
+	Version int               `json:"version"`
+	Width   int               `json:"width"`
+	Height  int               `json:"height"`
+	Title   string            `json:"title,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+}
+
+// castEvent - вывод в терминал в момент Time от начала записи
+type castEvent struct {
+	Time float64
+	Data string
+}
+
+// exportCast проигрывает реплей и пишет каждый кадр как вывод терминала.
+// Размер терминала известен только после всех кадров, поэтому кадры сначала копятся в памяти.
+func exportCast(w io.Writer, path string, opts castOptions) error {
+	cursor, err := engine.OpenReplay(path)
+	if err != nil {
+		return err
+	}
+
+	viewer := opts.Entity
+	if viewer == "" {
+		viewer = cursor.PlayerID()
+	}
+	if opts.Omniscient {
+		viewer = ""
+	}
+
+	r := &castRenderer{color: opts.Color}
+	var events []castEvent
+	var elapsed time.Duration
+
+	pos := cursor.Position()
+	events = append(events, castEvent{Data: r.render(cursor.Frame(viewer), pos)})
+	for cursor.Next() {
+		next := cursor.Position()
+		elapsed += castDelay(pos, next, opts.Speed)
+		pos = next
+		events = append(events, castEvent{Time: elapsed.Seconds(), Data: r.render(cursor.Frame(viewer), pos)})
+	}
+
+	header := castHeader{
+		Version: 2,
+		Width:   r.width,
+		Height:  r.height,
+		Title:   "replay " + cursor.PlayerID(),
+		Env:     map[string]string{"TERM": "xterm-256color"},
+	}
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := enc.Encode([]any{e.Time, "o", e.Data}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// castDelay - сколько держать кадр: столько, сколько игровых тиков занял ход
+func castDelay(before, after engine.ReplayPosition, speed float64) time.Duration {
+	ticks := after.Tick - before.Tick
+	if after.Segment != before.Segment || ticks < 0 {
+		ticks = domain.TimeCostMove // Время другого уровня несравнимо: показываем как обычный шаг
+	}
+	d := time.Duration(float64(ticks) * float64(castTickDuration) / speed)
+	return min(d, castMaxDelay)
+}
+
+// castRenderer рисует кадры и запоминает наибольший размер экрана
+type castRenderer struct {
+	color  bool
+	logs   []string
+	width  int
+	height int
+}
+
+// render рисует кадр: строка статуса, карта и последние сообщения лога
+func (r *castRenderer) render(frame *api.ServerResponse, pos engine.ReplayPosition) string {
+	for _, l := range frame.Logs {
+		r.logs = append(r.logs, l.Text)
+	}
+	if len(r.logs) > castLogLines {
+		r.logs = r.logs[len(r.logs)-castLogLines:]
+	}
+
+	lines := []string{statusLine(frame, pos)}
+	lines = append(lines, renderMap(frame, r.color)...)
+	lines = append(lines, r.logs...)
+
+	for _, line := range lines {
+		r.width = max(r.width, visibleWidth(line))
+	}
+	r.height = max(r.height, len(lines))
+	return ansiClear + strings.Join(lines, "\r\n")
+}
+
+// statusLine - позиция в реплее и здоровье того, чьими глазами смотрим
+func statusLine(frame *api.ServerResponse, pos engine.ReplayPosition) string {
+	status := fmt.Sprintf("step %d/%d  level %d  tick %d", pos.Step, pos.Total, pos.Level, pos.Tick)
+	for _, e := range frame.Entities {
+		if e.ID == frame.MyEntityID && e.Stats != nil {
+			status = fmt.Sprintf("%s  %s HP %d/%d", status, e.ID, e.Stats.HP, e.Stats.MaxHP)
+		}
+	}
+	if pos.Ended {
+		status += "  [end]"
+	}
+	return status
+}
+
+// cell - символ карты и как его красить
+type cell struct {
+	symbol string
+	color  string
+	dim    bool
+}
+
+// renderMap рисует видимую и исследованную часть карты с сущностями поверх
+func renderMap(frame *api.ServerResponse, color bool) []string {
+	if frame.Grid == nil {
+		return nil
+	}
+	w, h := frame.Grid.Width, frame.Grid.Height
+	grid := make([][]cell, h)
+	for y := range grid {
+		grid[y] = make([]cell, w)
+		for x := range grid[y] {
+			grid[y][x] = cell{symbol: " "}
+		}
+	}
+
+	inside := func(x, y int) bool { return x >= 0 && y >= 0 && x < w && y < h }
+	for _, t := range frame.Map {
+		if inside(t.X, t.Y) {
+			grid[t.Y][t.X] = cell{symbol: t.Symbol, color: t.Color, dim: !t.IsVisible}
+		}
+	}
+	// Предметы рисуем первыми, чтобы их закрывали стоящие на них существа, а игроков - последними
+	for _, layer := range []func(string) bool{
+		func(t string) bool { return t == "ITEM" },
+		func(t string) bool { return t != "ITEM" && t != "PLAYER" },
+		func(t string) bool { return t == "PLAYER" },
+	} {
+		for _, e := range frame.Entities {
+			if layer(e.Type) && inside(e.Pos.X, e.Pos.Y) {
+				grid[e.Pos.Y][e.Pos.X] = cell{symbol: e.Render.Symbol, color: e.Render.Color}
+			}
+		}
+	}
+
+	lines := make([]string, h)
+	for y, row := range grid {
+		var sb strings.Builder
+		style := ""
+		for _, c := range row {
+			if color {
+				if s := c.style(); s != style {
+					sb.WriteString(ansiReset + s)
+					style = s
+				}
+			}
+			sb.WriteString(c.symbol)
+		}
+		if color && style != "" {
+			sb.WriteString(ansiReset)
+		}
+		lines[y] = strings.TrimRight(sb.String(), " ")
+	}
+	return lines
+}
+
+// style - ANSI-последовательность цвета клетки (truecolor из "#RRGGBB")
+func (c cell) style() string {
+	s := ""
+	if c.dim {
+		s = ansiDim
+	}
+	if len(c.color) != 7 || c.color[0] != '#' {
+		return s
+	}
+	rgb, err := strconv.ParseUint(c.color[1:], 16, 32)
+	if err != nil {
+		return s
+	}
+	return fmt.Sprintf("%s\x1b[38;2;%d;%d;%dm", s, rgb>>16, rgb>>8&0xFF, rgb&0xFF)
+}
+
+// visibleWidth - ширина строки в терминале без ANSI-последовательностей
+func visibleWidth(s string) int {
+	width := 0
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case r == '\x1b':
+			inEscape = true
+		case inEscape:
+			if r == 'm' {
+				inEscape = false
+			}
+		default:
+			width++
+		}
+	}
+	return width
+}
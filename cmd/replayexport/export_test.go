@@ -0,0 +1,60 @@
+package main
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/pkg/api"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRenderMap_EntitiesOverTiles(t *testing.T) {
+	frame := &api.ServerResponse{
+		Grid: &api.GridMeta{Width: 3, Height: 2},
+		Map: []api.TileView{
+			{X: 0, Y: 0, Symbol: "#", IsVisible: true},
+			{X: 1, Y: 0, Symbol: ".", IsVisible: true},
+			{X: 2, Y: 0, Symbol: ".", IsVisible: true},
+			{X: 0, Y: 1, Symbol: "#"},
+		},
+	}
+	item := api.EntityView{Type: "ITEM"}
+	item.Pos.X, item.Render.Symbol = 1, "!"
+	player := api.EntityView{Type: "PLAYER"}
+	player.Pos.X, player.Render.Symbol = 1, "@"
+	monster := api.EntityView{Type: "ENEMY"}
+	monster.Pos.X, monster.Render.Symbol = 2, "g"
+	frame.Entities = []api.EntityView{player, item, monster}
+
+	got := renderMap(frame, false)
+	want := []string{"#@g", "#"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("renderMap = %q, want %q", got, want)
+	}
+}
+
+func TestVisibleWidth_SkipsEscapes(t *testing.T) {
+	line := ansiDim + "\x1b[38;2;1;2;3m" + "#.." + ansiReset
+	if got := visibleWidth(line); got != 3 {
+		t.Errorf("visibleWidth = %d, want 3", got)
+	}
+}
+
+func TestActionLine_Payload(t *testing.T) {
+	act := domain.ReplayAction{Tick: 5, Token: "hero", Action: domain.ActionMove, Payload: json.RawMessage(`{"dx":1}`)}
+	line := newActionLine(3, 1, 0, 2, act)
+	data, err := json.Marshal(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"step":3,"segment":1,"index":0,"level":2,"tick":5,"actor":"hero","action":"MOVE","controller":"client","payload":{"dx":1}}`
+	if string(data) != want {
+		t.Errorf("got %s\nwant %s", data, want)
+	}
+
+	// Битый payload уходит строкой, строка JSONL остается валидной
+	act.Payload = json.RawMessage(`{"dx":`)
+	if data, err = json.Marshal(newActionLine(0, 0, 0, 0, act)); err != nil || !json.Valid(data) {
+		t.Errorf("invalid line for broken payload: %s (%v)", data, err)
+	}
+}
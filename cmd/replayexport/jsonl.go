@@ -0,0 +1,64 @@
+package main
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/infrastructure/storage"
+	"encoding/json"
+	"io"
+)
+
+// actionLine - строка JSONL: одно записанное действие
+type actionLine struct {
+	Step       int             `json:"step"`    // Номер действия с начала забега
+	Segment    int             `json:"segment"` // Сегмент забега и номер действия в нем
+	Index      int             `json:"index"`
+	Level      int             `json:"level"`
+	Tick       int             `json:"tick"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	Controller string          `json:"controller"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+}
+
+// exportJSONL пишет действия реплея по одному на строку. Симуляция не нужна: все есть в записи.
+func exportJSONL(w io.Writer, path string) error {
+	run, err := storage.NewReplayService("").LoadRun(path)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	step := 0
+	for k, segment := range run.Segments {
+		for index, act := range segment.Actions {
+			if err := enc.Encode(newActionLine(step, k, index, segment.LevelID, act)); err != nil {
+				return err
+			}
+			step++
+		}
+	}
+	return nil
+}
+
+func newActionLine(step, segment, index, level int, act domain.ReplayAction) actionLine {
+	line := actionLine{
+		Step:       step,
+		Segment:    segment,
+		Index:      index,
+		Level:      level,
+		Tick:       act.Tick,
+		Actor:      act.Token,
+		Action:     act.Action.String(),
+		Controller: act.Controller.String(),
+	}
+
+	// Payload в записи - JSON команды клиента. Если он битый, отдаем его строкой, а не ломаем строку
+	if len(act.Payload) > 0 {
+		if json.Valid(act.Payload) {
+			line.Payload = act.Payload
+		} else {
+			line.Payload, _ = json.Marshal(string(act.Payload))
+		}
+	}
+	return line
+}
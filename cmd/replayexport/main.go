@@ -0,0 +1,80 @@
+// replayexport переводит реплей .cdrp в читаемый вид без запуска сервера.
+//
+//	replayexport [-format jsonl|cast] [-o out] [-entity id | -omni] [-speed 1] [-mono] [-content dir] <file.cdrp>
+//
+// jsonl - одно записанное действие на строку, payload раскодирован.
+// cast  - запись терминала asciinema v2: карта уровня кадр за кадром, как ее видел игрок.
+// Без -o результат пишется в stdout.
+package main
+
+import (
+	"bufio"
+	"cognitive-server/internal/cli"
+	"cognitive-server/pkg/logger"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	format := flag.String("format", "jsonl", "Output format: jsonl or cast")
+	out := flag.String("o", "", "Output file (default stdout)")
+	entity := flag.String("entity", "", "Whose eyes to render the cast with (default: run owner)")
+	omni := flag.Bool("omni", false, "Render the cast for an omniscient observer")
+	speed := flag.Float64("speed", 1, "Cast playback speed multiplier")
+	mono := flag.Bool("mono", false, "Render the cast without colors")
+	verbose := flag.Bool("v", false, "Show engine logs")
+	contentDir := cli.ContentFlag()
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-format jsonl|cast] [-o out] [-entity id | -omni] [-speed 1] [-mono] [-content dir] <file.cdrp>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 || *speed <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	path := flag.Arg(0)
+
+	logger.Init()
+	logger.Log.SetOutput(os.Stderr)
+	if !*verbose {
+		logger.Log.SetLevel(logrus.FatalLevel)
+	}
+
+	cli.LoadContent(*contentDir)
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		defer f.Close()
+		w = f
+	}
+	buf := bufio.NewWriter(w)
+
+	var err error
+	switch *format {
+	case "jsonl":
+		err = exportJSONL(buf, path)
+	case "cast":
+		opts := castOptions{Entity: *entity, Omniscient: *omni, Speed: *speed, Color: !*mono}
+		err = exportCast(buf, path, opts)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
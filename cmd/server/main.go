@@ -4,6 +4,7 @@ import (
 	"cognitive-server/internal/engine"
 	"cognitive-server/internal/server"
 	"cognitive-server/internal/version"
+	"cognitive-server/pkg/dungeon"
 	"cognitive-server/pkg/logger"
 	"flag"
 	"os"
@@ -27,39 +28,41 @@ func main() {
 	logger.Log.Info("Starting Cognitive Dungeon...")
 	logger.Log.Info(version.String())
 
+	// Формируем конфиг
+	cfg := engine.NewConfig()
+
+	// Шаблоны нужны до генерации уровней, в том числе в режиме реплея: запись сверяется с контентом.
+	// Битый пак - повод не стартовать, а не играть без него.
+	content, err := dungeon.ReloadContent(cfg.ContentDir)
+	if err != nil {
+		logger.Log.Fatalf("Failed to load content from %s:\n%v", cfg.ContentDir, err)
+	}
+	logger.Log.Infof("📦 Content: %d enemies, %d NPCs, %d items, %d sentient items",
+		len(content.Enemies), len(content.NPCs), len(content.Items), len(content.SentientItems))
+
 	// РЕЖИМ РЕПЛЕЯ
 	if replayPath != "" {
 		logger.Log.Info("💿 Mode: Replay Simulation")
 
-		// Создаем пустой сервис
-		cfg := engine.NewConfig()
-		gameService := engine.NewService(cfg) // NewService создает дефолтные миры, но мы их перезапишем или добавим свой
+		// Офлайн-сервис: без стартовых уровней, циклов и записи на диск
+		gameService := engine.NewPlaybackService()
 
 		// Загружаем реплей
 		if err := gameService.LoadReplay(replayPath); err != nil {
 			logger.Log.Fatal("Failed to load replay:", err)
 		}
 
-		// Запускаем симуляцию (предполагаем, что LevelID берется из файла, в LoadReplay мы создали инстанс)
-		// Нам нужно узнать какой уровень запускать. LoadReplay создал инстанс в s.Instances.
-		// Пробегаем по всем инстансам, но запускаем только те, где есть флаг IsPlayback
-		simulatedCount := 0
-		for id, inst := range gameService.Instances {
-			if inst.IsPlayback {
-				gameService.StartPlayback(id)
-				simulatedCount++
-			}
-		}
-
-		if simulatedCount == 0 {
-			logger.Log.Warn("No instances ready for playback found.")
+		// Проигрываем сегменты забега по очереди, следуя за игроком между уровнями
+		result, err := gameService.PlayReplay(false)
+		if err != nil {
+			logger.Log.Fatal("Replay playback failed:", err)
 		}
+		logger.Log.Infof("Replay finished: %d segments, %d actions, %d desyncs",
+			result.Segments, result.Actions, len(result.Desyncs))
 
 		return // Выходим после симуляции
 	}
 
-	// Формируем конфиг
-	cfg := engine.NewConfig()
 	if seed != 0 {
 		cfg.Seed = seed
 		logger.Log.Infof("🎲 Using explicit Master Seed: %d", seed)
@@ -72,6 +75,9 @@ func main() {
 		port = "8080"
 	}
 
+	// Admin-эндпоинты только для оператора: без токена их нет вовсе
+	cfg.AdminToken = os.Getenv("CD_ADMIN_TOKEN")
+
 	// 2. Инициализация ядра с конфигом
 	gameService := engine.NewService(cfg)
 	gameService.Start()
@@ -92,10 +98,8 @@ func main() {
 	<-stop
 	logger.Log.Info("Shutting down...")
 
-	// Сохраняем все активные миры
-	for _, inst := range gameService.Instances {
-		inst.SaveReplay()
-	}
+	// Уровни останавливаются и сами сохраняют записи и персонажей, которые еще в игре
+	gameService.Shutdown()
 
 	logger.Log.Info("Done.")
 }
@@ -149,7 +149,7 @@ func (b *Bot) findActors(state api.ServerResponse, localWorld *domain.GameWorld)
 		// Конвертируем EntityView (DTO) в domain.Entity для физического движка
 		ent := &domain.Entity{
 			ID:    ev.ID,
-			Type:  ev.Type,
+			Type:  domain.ParseEntityType(ev.Type),
 			Pos:   domain.Position{X: ev.Pos.X, Y: ev.Pos.Y},
 			Stats: &domain.StatsComponent{IsDead: true}, // По умолчанию считаем мертвым/непроходимым
 		}
@@ -168,7 +168,7 @@ func (b *Bot) findActors(state api.ServerResponse, localWorld *domain.GameWorld)
 			me = ent
 			me.AI = &domain.AIComponent{IsHostile: true} // Предполагаем, что бот всегда враждебен
 		}
-		if ev.Type == domain.EntityTypePlayer {
+		if ent.Type == domain.EntityTypePlayer {
 			target = ent
 		}
 
@@ -0,0 +1,29 @@
+// Package cli - общее для офлайн-утилит (replaycheck, replaydiff, replayexport, mapgen).
+package cli
+
+import (
+	"cognitive-server/internal/engine"
+	"cognitive-server/pkg/dungeon"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// exitError - код выхода утилит при ошибке загрузки или аргументов
+const exitError = 2
+
+// ContentFlag регистрирует флаг -content: папка паков контента, по умолчанию та же, что у сервера.
+// Вызывается до flag.Parse.
+func ContentFlag() *string {
+	return flag.String("content", engine.NewConfig().ContentDir, "Content packs directory (as on the server)")
+}
+
+// LoadContent загружает паки из dir, а при ошибке печатает все проблемы и завершает процесс.
+// Уровни, рецепты и шаблоны утилиты строят из тех же паков, что и сервер: на другом контенте
+// запись не проигрывается (рецепт уровня не совпадет), а превью карты не похоже на игру.
+func LoadContent(dir string) {
+	if _, err := dungeon.ReloadContent(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load content from %s:\n%v\n", dir, err)
+		os.Exit(exitError)
+	}
+}
@@ -56,6 +56,12 @@ var actionCmdToString = map[ActionType]string{
 	ActionUse:      "USE",
 	ActionEquip:    "EQUIP",
 	ActionUnequip:  "UNEQUIP",
+
+	ActionAdminSpawn:    "ADMIN_SPAWN",
+	ActionAdminTeleport: "ADMIN_TELEPORT",
+	ActionAdminHeal:     "ADMIN_HEAL",
+	ActionAdminKill:     "ADMIN_KILL",
+	ActionAdminOmni:     "ADMIN_TOGGLE_OMNI",
 }
 
 // ParseAction конвертирует строку из JSON в ActionType
@@ -31,6 +31,7 @@ func TestActionType_String(t *testing.T) {
 	}{
 		{ActionMove, "MOVE"},
 		{ActionAttack, "ATTACK"},
+		{ActionAdminTeleport, "ADMIN_TELEPORT"},
 		{ActionUnknown, "UNKNOWN"},
 	}
 
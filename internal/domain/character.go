@@ -0,0 +1,93 @@
+package domain
+
+// CharacterRecord - сохраняемое состояние персонажа игрока.
+// Переживает выход из игры и рестарт сервера: статы, инвентарь, экипировка,
+// исследованные клетки и уровень, на котором персонаж находился.
+type CharacterRecord struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name"`
+	Level int      `json:"level"`
+	Pos   Position `json:"pos"`
+
+	Stats     StatsComponent      `json:"stats"`
+	Inventory *InventoryComponent `json:"inventory,omitempty"`
+	Memory    *MemoryComponent    `json:"memory,omitempty"`
+	Run       *RunStatsComponent  `json:"run,omitempty"`
+
+	// Экипировка хранится ссылками на предметы из инвентаря,
+	// чтобы после загрузки Equipment и Inventory указывали на одни и те же Entity.
+	WeaponID string `json:"weaponId,omitempty"`
+	ArmorID  string `json:"armorId,omitempty"`
+
+	SavedAt int64 `json:"savedAt"` // Unix seconds
+}
+
+// NewCharacterRecord снимает состояние с живой сущности.
+// Запись ссылается на компоненты сущности, поэтому сериализовать её нужно сразу.
+func NewCharacterRecord(e *Entity, savedAt int64) *CharacterRecord {
+	rec := &CharacterRecord{
+		ID:        e.ID,
+		Name:      e.Name,
+		Level:     e.Level,
+		Pos:       e.Pos,
+		Inventory: e.Inventory,
+		Memory:    e.Memory,
+		Run:       e.Run,
+		SavedAt:   savedAt,
+	}
+
+	if e.Stats != nil {
+		rec.Stats = *e.Stats
+	}
+
+	if e.Equipment != nil {
+		if e.Equipment.Weapon != nil {
+			rec.WeaponID = e.Equipment.Weapon.ID
+		}
+		if e.Equipment.Armor != nil {
+			rec.ArmorID = e.Equipment.Armor.ID
+		}
+	}
+
+	return rec
+}
+
+// ApplyTo переносит сохраненное состояние на сущность (обычно свежесозданного игрока).
+// Компоненты записи передаются сущности во владение, поэтому запись после этого не переиспользуется.
+func (r *CharacterRecord) ApplyTo(e *Entity) {
+	e.Name = r.Name
+	e.Level = r.Level
+	e.Pos = r.Pos
+
+	if e.Stats == nil {
+		e.Stats = &StatsComponent{}
+	}
+	*e.Stats = r.Stats
+
+	if r.Inventory != nil {
+		e.Inventory = r.Inventory
+	}
+	if r.Memory != nil {
+		if r.Memory.ExploredPerLevel == nil {
+			r.Memory.ExploredPerLevel = make(map[int]map[int]bool)
+		}
+		e.Memory = r.Memory
+	}
+	if r.Run != nil {
+		e.Run = r.Run
+	}
+
+	e.Equipment = &EquipmentComponent{}
+	if r.WeaponID != "" {
+		e.Equipment.Weapon = e.Inventory.FindItem(r.WeaponID)
+	}
+	if r.ArmorID != "" {
+		e.Equipment.Armor = e.Inventory.FindItem(r.ArmorID)
+	}
+
+	// Кэш видимости относится к старой позиции
+	if e.Vision != nil {
+		e.Vision.IsDirty = true
+		e.Vision.CachedVisibleTiles = nil
+	}
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCharacterRecord_RoundTrip(t *testing.T) {
+	sword := &Entity{ID: "sword", Name: "Sword", Item: &ItemComponent{Category: ItemCategoryWeapon, Damage: 5}}
+	potion := &Entity{ID: "potion", Name: "Potion", Item: &ItemComponent{Category: ItemCategoryPotion}}
+
+	hero := &Entity{
+		ID:    "hero",
+		Name:  "Hero",
+		Type:  EntityTypePlayer,
+		Level: 3,
+		Pos:   Position{X: 7, Y: 4},
+		Stats: &StatsComponent{HP: 42, MaxHP: 100, Strength: 12, Gold: 77},
+		Inventory: &InventoryComponent{
+			Items:    []*Entity{sword, potion},
+			MaxSlots: 20,
+		},
+		Equipment: &EquipmentComponent{Weapon: sword},
+		Memory: &MemoryComponent{ExploredPerLevel: map[int]map[int]bool{
+			3: {10: true, 11: true},
+		}},
+	}
+
+	data, err := json.Marshal(NewCharacterRecord(hero, 1))
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+
+	var rec CharacterRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	fresh := &Entity{ID: "hero", Type: EntityTypePlayer, Stats: &StatsComponent{HP: 100}}
+	rec.ApplyTo(fresh)
+
+	if fresh.Level != 3 || fresh.Pos != hero.Pos {
+		t.Errorf("location not restored: level %d pos %v", fresh.Level, fresh.Pos)
+	}
+	if fresh.Stats.HP != 42 || fresh.Stats.Gold != 77 || fresh.Stats.Strength != 12 {
+		t.Errorf("stats not restored: %+v", fresh.Stats)
+	}
+	if len(fresh.Inventory.Items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(fresh.Inventory.Items))
+	}
+	// Экипированный предмет должен быть тем же объектом, что и в инвентаре
+	if fresh.Equipment.Weapon == nil || fresh.Equipment.Weapon != fresh.Inventory.FindItem("sword") {
+		t.Error("equipped weapon is not linked to inventory item")
+	}
+	if fresh.Equipment.Armor != nil {
+		t.Error("armor slot should stay empty")
+	}
+	if !fresh.Memory.ExploredPerLevel[3][11] {
+		t.Error("explored memory not restored")
+	}
+}
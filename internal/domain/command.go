@@ -8,4 +8,6 @@ type InternalCommand struct {
 	Action  ActionType      // Число! Быстро и безопасно.
 	Token   string          // ID сущности (Actor)
 	Payload json.RawMessage // Сырые данные (парсятся хендлером)
+
+	Controller ControllerType // Кто отдал команду (для записи реплея)
 }
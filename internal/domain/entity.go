@@ -8,6 +8,9 @@ type Entity struct {
 	Type EntityType `json:"type"`
 	Name string     `json:"name"`
 
+	// TemplateID - ключ шаблона, из которого создана сущность ("goblin"). Пусто для уникальных.
+	TemplateID string `json:"templateId,omitempty"`
+
 	// ControllerID - ID сессии/пользователя, который управляет этой сущностью.
 	// Если пусто - управляется AI.
 	ControllerID string `json:"controllerId,omitempty"`
@@ -28,4 +31,7 @@ type Entity struct {
 	Item      *ItemComponent      `json:"item,omitempty"`      // Делает Entity предметом
 	Inventory *InventoryComponent `json:"inventory,omitempty"` // Инвентарь для существ
 	Equipment *EquipmentComponent `json:"equipment,omitempty"` // Экипировка для существ
+
+	// Статистика забега (только у игроков)
+	Run *RunStatsComponent `json:"run,omitempty"`
 }
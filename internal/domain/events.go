@@ -7,6 +7,7 @@ const (
 	EventUnknown EventType = iota
 	EventLevelTransition
 	EventItemReaction // Для событий живых предметов (будет обрабатываться микросервисом)
+	EventRestore      // Восстановление здоровья и выносливости (алтари)
 	// Future events:
 	// EventSpawnMonster = "SPAWN_MONSTER"
 	// EventOpenDoor     = "OPEN_DOOR"
@@ -16,12 +17,14 @@ const (
 var eventStringToCmd = map[string]EventType{
 	"LEVEL_TRANSITION": EventLevelTransition,
 	"ITEM_EVENT":       EventItemReaction,
+	"RESTORE":          EventRestore,
 }
 
 // Маппинг для логов Domain -> String
 var eventCmdToString = map[EventType]string{
 	EventLevelTransition: "LEVEL_TRANSITION",
 	EventItemReaction:    "ITEM_EVENT",
+	EventRestore:         "RESTORE",
 }
 
 // ParseEvent конвертирует строку из JSON в EventType
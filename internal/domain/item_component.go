@@ -7,10 +7,13 @@ type ItemComponent struct {
 	Category    ItemCategory `json:"category"`    // "weapon", "armor", "potion", "food", "misc", "container"
 	IsStackable bool         `json:"isStackable"` // можно ли складывать в стаки
 	StackSize   int          `json:"stackSize"`   // текущее количество в стаке
+	Rarity      ItemRarity   `json:"rarity,omitempty"`
+	Affixes     []string     `json:"affixes,omitempty"` // ключи выпавших аффиксов, их бонусы уже вписаны в характеристики
 
 	// Характеристики оружия
 	Damage      int `json:"damage,omitempty"`      // урон оружия
 	AttackSpeed int `json:"attackSpeed,omitempty"` // модификатор скорости атаки (в тиках)
+	LifeOnHit   int `json:"lifeOnHit,omitempty"`   // сколько HP возвращает владельцу каждый удар
 
 	// Характеристики брони
 	Defense   int    `json:"defense,omitempty"`   // защита брони
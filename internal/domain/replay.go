@@ -2,19 +2,123 @@ package domain
 
 import "encoding/json"
 
+// ControllerType - кто принимает решения за сущность
+type ControllerType uint8
+
+const (
+	ControllerClient  ControllerType = iota // Команды приходят от клиента (человек или бот)
+	ControllerTimeout                       // Клиент не успел, сервер подставил ожидание
+	ControllerAI                            // Ходы делает AI сервера (в том числе за неподключенного игрока)
+)
+
+func (c ControllerType) String() string {
+	switch c {
+	case ControllerClient:
+		return "client"
+	case ControllerTimeout:
+		return "timeout"
+	case ControllerAI:
+		return "ai"
+	default:
+		return "unknown"
+	}
+}
+
 // ReplayAction - это запись одного действия извне (от игрока)
 type ReplayAction struct {
-	Tick    int             `json:"tick"`
-	Token   string          `json:"token"`   // Кто сделал
-	Action  ActionType      `json:"action"`  // Что сделал
-	Payload json.RawMessage `json:"payload"` // С какими параметрами
+	Tick       int             `json:"tick"`
+	Token      string          `json:"token"`      // Кто сделал
+	Action     ActionType      `json:"action"`     // Что сделал
+	Payload    json.RawMessage `json:"payload"`    // С какими параметрами
+	Controller ControllerType  `json:"controller"` // Кто принял решение
+}
+
+// DefaultReplayHashInterval - через сколько действий в запись попадает хеш состояния
+const DefaultReplayHashInterval = 16
+
+// ReplayChecksum - хеш состояния уровня перед выполнением действия с индексом Action
+type ReplayChecksum struct {
+	Action int    `json:"action"`
+	Hash   uint64 `json:"hash"`
 }
 
-// ReplaySession - полная запись партии
+// DefaultReplayKeyframeInterval - через сколько действий в запись попадает полный кадр состояния
+const DefaultReplayKeyframeInterval = 256
+
+// ReplayKeyframe - полное состояние уровня перед действием с индексом Action.
+// С него воспроизведение может начаться, не симулируя сегмент с начала.
+type ReplayKeyframe struct {
+	Action   int                `json:"action"`
+	Tick     int                `json:"tick"`
+	RngDraws uint64             `json:"rngDraws"` // Сколько чисел вытянуто из RNG уровня с его создания
+	Queue    []ReplayQueueEntry `json:"queue"`    // Очередь ходов (в ней бывают и мертвые, и ждущие не по NextActionTick)
+	Entities json.RawMessage    `json:"entities"` // EncodeEntities
+}
+
+// ReplayQueueEntry - место сущности в очереди ходов
+type ReplayQueueEntry struct {
+	EntityID string `json:"entityId"`
+	Priority int    `json:"priority"`
+}
+
+// ReplayTransition - переход игрока на другой уровень, которым закончился сегмент
+type ReplayTransition struct {
+	Action   int    `json:"action"`   // Индекс действия сегмента, вызвавшего переход
+	EntityID string `json:"entityId"` // Кто перешел
+	ToLevel  int    `json:"toLevel"`
+	TargetID string `json:"targetId,omitempty"` // Точка появления на новом уровне (лестница)
+}
+
+// ReplaySession - запись одного уровня (сегмент забега)
 type ReplaySession struct {
 	LevelID     int             `json:"levelId"`
-	Seed        int64           `json:"seed"` // Зерно генерации мира и рандома
+	Seed        int64           `json:"seed"`             // Зерно генерации мира и рандома
+	Recipe      string          `json:"recipe,omitempty"` // Рецепт генерации уровня (пусто в старых записях)
 	Timestamp   int64           `json:"timestamp"`
 	PlayerState json.RawMessage `json:"playerState,omitempty"`
-	Actions     []ReplayAction  `json:"actions"`
+
+	// Entities - снапшот всех сущностей уровня на момент начала записи (EncodeEntities).
+	// Пусто в записях v1: тогда сущности генерируются заново по сиду.
+	Entities json.RawMessage `json:"entities,omitempty"`
+
+	// Controllers - кто управлял игроками уровня в этом сегменте. Ходы игроков с ControllerClient
+	// берутся из Actions, остальные сущности ходят по AI. Внутри сегмента карта не меняется.
+	// nil в старых записях: тогда из записи ходит игрок с ControllerID.
+	Controllers map[string]ControllerType `json:"controllers,omitempty"`
+
+	// RngDraws - сколько чисел было вытянуто из RNG уровня к началу записи. Живой генератор
+	// не пересевается: воспроизведение проматывает его от сида до этого места.
+	// ActiveID - чьей команды ждал уровень, когда начался сегмент (пусто, если ход не шел):
+	// он ходит первым, даже если вошедший вместе с ним стоит в очереди раньше.
+	RngDraws uint64 `json:"rngDraws,omitempty"`
+	ActiveID string `json:"activeId,omitempty"`
+
+	HashInterval int              `json:"hashInterval,omitempty"`
+	Checksums    []ReplayChecksum `json:"checksums,omitempty"`
+	Keyframes    []ReplayKeyframe `json:"keyframes,omitempty"`
+
+	Actions []ReplayAction `json:"actions"`
+
+	// Exit - переход, которым закончился сегмент. nil, если игрок остался на уровне.
+	Exit *ReplayTransition `json:"exit,omitempty"`
+}
+
+// RunReplay - запись забега игрока: сегменты уровней в порядке прохождения.
+// Сегмент заканчивается, когда игрок уходит с уровня; переход в Exit связывает его со следующим.
+type RunReplay struct {
+	PlayerID  string           `json:"playerId"`
+	Timestamp int64            `json:"timestamp"`
+	Segments  []*ReplaySession `json:"segments"`
+
+	// Truncated - файл оборван или поврежден: в Segments все, что прочиталось до первого битого блока
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// NewRunReplay оборачивает одиночную запись уровня в забег из одного сегмента.
+func NewRunReplay(session *ReplaySession) *RunReplay {
+	run := &RunReplay{Timestamp: session.Timestamp, Segments: []*ReplaySession{session}}
+	if len(session.Actions) > 0 {
+		run.PlayerID = session.Actions[0].Token
+	}
+	return run
 }
@@ -0,0 +1,141 @@
+package domain
+
+import "fmt"
+
+// Исход забега
+const (
+	RunOutcomeDeath   = "death"   // Персонаж погиб
+	RunOutcomeEscaped = "escaped" // Персонаж поднялся из подземелья на поверхность
+)
+
+// RunStatsComponent - статистика текущего забега игрока.
+// Копится, пока персонаж в подземелье, и превращается в RunSummary при смерти или выходе.
+type RunStatsComponent struct {
+	StartedAt    int64          `json:"startedAt"` // Unix seconds
+	MaxDepth     int            `json:"maxDepth"`
+	Ticks        int            `json:"ticks"` // Игровое время, прожитое персонажем
+	Kills        map[string]int `json:"kills"` // TemplateID -> количество
+	DamageDealt  int            `json:"damageDealt"`
+	DamageTaken  int            `json:"damageTaken"`
+	CauseOfDeath string         `json:"causeOfDeath,omitempty"`
+}
+
+func NewRunStats(startedAt int64, depth int) *RunStatsComponent {
+	return &RunStatsComponent{
+		StartedAt: startedAt,
+		MaxDepth:  depth,
+		Kills:     make(map[string]int),
+	}
+}
+
+// ReachDepth обновляет максимальную глубину забега
+func (r *RunStatsComponent) ReachDepth(depth int) {
+	if depth > r.MaxDepth {
+		r.MaxDepth = depth
+	}
+}
+
+// TotalKills - сумма убийств по всем шаблонам
+func (r *RunStatsComponent) TotalKills() int {
+	total := 0
+	for _, n := range r.Kills {
+		total += n
+	}
+	return total
+}
+
+// RecordHit учитывает удар в статистике забегов обеих сторон (если они ее ведут).
+func RecordHit(attacker, target *Entity, damage int, killed bool) {
+	if attacker.Run != nil {
+		attacker.Run.DamageDealt += damage
+		if killed {
+			if attacker.Run.Kills == nil {
+				attacker.Run.Kills = make(map[string]int)
+			}
+			attacker.Run.Kills[target.KillKey()]++
+		}
+	}
+
+	if target.Run != nil {
+		target.Run.DamageTaken += damage
+		if killed {
+			target.Run.CauseOfDeath = fmt.Sprintf("убит: %s", attacker.Name)
+		}
+	}
+}
+
+// RecordHazard учитывает урон от местности (лава, ловушка, падение) в статистике забега.
+func RecordHazard(target *Entity, damage int, killed bool, cause string) {
+	if target.Run == nil {
+		return
+	}
+	target.Run.DamageTaken += damage
+	if killed {
+		target.Run.CauseOfDeath = cause
+	}
+}
+
+// KillKey - ключ для статистики убийств: шаблон сущности или ее имя, если шаблона нет
+func (e *Entity) KillKey() string {
+	if e.TemplateID != "" {
+		return e.TemplateID
+	}
+	return e.Name
+}
+
+// RunSummary - итог завершенного забега (история и лидерборды).
+type RunSummary struct {
+	ID       string `json:"id"`
+	PlayerID string `json:"playerId"`
+	Name     string `json:"name"`
+	Outcome  string `json:"outcome"`
+
+	Depth        int            `json:"depth"`
+	Ticks        int            `json:"ticks"`
+	Kills        map[string]int `json:"kills"`
+	TotalKills   int            `json:"totalKills"`
+	Gold         int            `json:"gold"`
+	DamageDealt  int            `json:"damageDealt"`
+	DamageTaken  int            `json:"damageTaken"`
+	CauseOfDeath string         `json:"causeOfDeath,omitempty"`
+
+	StartedAt int64 `json:"startedAt"`
+	EndedAt   int64 `json:"endedAt"`
+}
+
+// NewRunSummary подводит итог забега сущности. Возвращает nil, если сущность не ведет статистику.
+func NewRunSummary(e *Entity, outcome string, endedAt int64) *RunSummary {
+	if e.Run == nil {
+		return nil
+	}
+
+	kills := make(map[string]int, len(e.Run.Kills))
+	for k, v := range e.Run.Kills {
+		kills[k] = v
+	}
+
+	run := &RunSummary{
+		ID:          fmt.Sprintf("%s_%d", e.ID, e.Run.StartedAt),
+		PlayerID:    e.ID,
+		Name:        e.Name,
+		Outcome:     outcome,
+		Depth:       e.Run.MaxDepth,
+		Ticks:       e.Run.Ticks,
+		Kills:       kills,
+		TotalKills:  e.Run.TotalKills(),
+		DamageDealt: e.Run.DamageDealt,
+		DamageTaken: e.Run.DamageTaken,
+		StartedAt:   e.Run.StartedAt,
+		EndedAt:     endedAt,
+	}
+	if e.Stats != nil {
+		run.Gold = e.Stats.Gold
+	}
+	if outcome == RunOutcomeDeath {
+		run.CauseOfDeath = e.Run.CauseOfDeath
+		if run.CauseOfDeath == "" {
+			run.CauseOfDeath = "неизвестно"
+		}
+	}
+	return run
+}
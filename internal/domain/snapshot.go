@@ -0,0 +1,88 @@
+package domain
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/fnv"
+	"sort"
+)
+
+// EncodeEntities сериализует сущности уровня для снапшота реплея.
+// Порядок сохраняется: от него зависят очередь ходов и выбор целей AI.
+func EncodeEntities(entities []*Entity) (json.RawMessage, error) {
+	return json.Marshal(entities)
+}
+
+// DecodeEntities восстанавливает сущности из снапшота.
+// Экипировка после JSON - это копии предметов, поэтому перепривязываем ее к инвентарю.
+func DecodeEntities(data json.RawMessage) ([]*Entity, error) {
+	var entities []*Entity
+	if err := json.Unmarshal(data, &entities); err != nil {
+		return nil, err
+	}
+	for _, e := range entities {
+		e.RelinkEquipment()
+		if e.Vision != nil {
+			e.Vision.IsDirty = true
+		}
+	}
+	return entities, nil
+}
+
+// RelinkEquipment заменяет экипированные предметы одноименными (по ID) объектами из инвентаря.
+func (e *Entity) RelinkEquipment() {
+	if e.Equipment == nil || e.Inventory == nil {
+		return
+	}
+	if e.Equipment.Weapon != nil {
+		if item := e.Inventory.FindItem(e.Equipment.Weapon.ID); item != nil {
+			e.Equipment.Weapon = item
+		}
+	}
+	if e.Equipment.Armor != nil {
+		if item := e.Inventory.FindItem(e.Equipment.Armor.ID); item != nil {
+			e.Equipment.Armor = item
+		}
+	}
+}
+
+// StateHash - компактный хеш состояния уровня для проверки детерминизма реплеев.
+// Учитывает только то, что влияет на симуляцию: позиции, здоровье, время хода, инвентарь.
+func StateHash(tick int, entities []*Entity) uint64 {
+	sorted := make([]*Entity, len(entities))
+	copy(sorted, entities)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].ID < sorted[b].ID })
+
+	h := fnv.New64a()
+	var buf [8]byte
+	writeInt := func(v int) {
+		binary.LittleEndian.PutUint64(buf[:], uint64(int64(v)))
+		h.Write(buf[:])
+	}
+
+	writeInt(tick)
+	for _, e := range sorted {
+		h.Write([]byte(e.ID))
+		writeInt(e.Level)
+		writeInt(e.Pos.X)
+		writeInt(e.Pos.Y)
+		if e.Stats != nil {
+			writeInt(e.Stats.HP)
+			writeInt(e.Stats.Stamina)
+			writeInt(e.Stats.Strength)
+			writeInt(e.Stats.Gold)
+			if e.Stats.IsDead {
+				writeInt(1)
+			} else {
+				writeInt(0)
+			}
+		}
+		if e.AI != nil {
+			writeInt(e.AI.NextActionTick)
+		}
+		if e.Inventory != nil {
+			writeInt(len(e.Inventory.Items))
+		}
+	}
+	return h.Sum64()
+}
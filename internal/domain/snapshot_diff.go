@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// diffIgnoredFields не влияют на симуляцию и только зашумляют дифф
+var diffIgnoredFields = map[string]bool{
+	"memory": true,
+	"vision": true,
+}
+
+// DiffEntities сравнивает два снапшота сущностей (EncodeEntities) и возвращает
+// построчные изменения вида "goblin_1 stats.hp: 10 -> 7", отсортированные по сущности и полю.
+func DiffEntities(before, after json.RawMessage) ([]string, error) {
+	old, err := flattenEntities(before)
+	if err != nil {
+		return nil, fmt.Errorf("before: %w", err)
+	}
+	cur, err := flattenEntities(after)
+	if err != nil {
+		return nil, fmt.Errorf("after: %w", err)
+	}
+
+	ids := make(map[string]bool)
+	for id := range old {
+		ids[id] = true
+	}
+	for id := range cur {
+		ids[id] = true
+	}
+	sortedIDs := make([]string, 0, len(ids))
+	for id := range ids {
+		sortedIDs = append(sortedIDs, id)
+	}
+	sort.Strings(sortedIDs)
+
+	var changes []string
+	for _, id := range sortedIDs {
+		oldFields, wasThere := old[id]
+		curFields, isThere := cur[id]
+		switch {
+		case !wasThere:
+			changes = append(changes, id+": added")
+			continue
+		case !isThere:
+			changes = append(changes, id+": removed")
+			continue
+		}
+
+		keys := make(map[string]bool)
+		for k := range oldFields {
+			keys[k] = true
+		}
+		for k := range curFields {
+			keys[k] = true
+		}
+		sortedKeys := make([]string, 0, len(keys))
+		for k := range keys {
+			sortedKeys = append(sortedKeys, k)
+		}
+		sort.Strings(sortedKeys)
+
+		for _, k := range sortedKeys {
+			a, okA := oldFields[k]
+			b, okB := curFields[k]
+			if okA && okB && a == b {
+				continue
+			}
+			if !okA {
+				a = "<none>"
+			}
+			if !okB {
+				b = "<none>"
+			}
+			changes = append(changes, fmt.Sprintf("%s %s: %s -> %s", id, k, a, b))
+		}
+	}
+	return changes, nil
+}
+
+// flattenEntities раскладывает массив сущностей в ID -> (путь поля -> значение)
+func flattenEntities(data json.RawMessage) (map[string]map[string]string, error) {
+	result := make(map[string]map[string]string)
+	if len(data) == 0 {
+		return result, nil
+	}
+
+	var entities []map[string]any
+	if err := json.Unmarshal(data, &entities); err != nil {
+		return nil, err
+	}
+	for _, e := range entities {
+		id, _ := e["id"].(string)
+		fields := make(map[string]string)
+		for k, v := range e {
+			if k == "id" || diffIgnoredFields[k] {
+				continue
+			}
+			flattenField(k, v, fields)
+		}
+		result[id] = fields
+	}
+	return result, nil
+}
+
+// flattenField раскладывает значение в пути вида stats.hp и items[0].id
+func flattenField(prefix string, v any, out map[string]string) {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			flattenField(prefix+"."+k, child, out)
+		}
+	case []any:
+		out[prefix+".len"] = strconv.Itoa(len(val))
+		for i, child := range val {
+			flattenField(prefix+"["+strconv.Itoa(i)+"]", child, out)
+		}
+	default:
+		b, _ := json.Marshal(val)
+		out[prefix] = string(b)
+	}
+}
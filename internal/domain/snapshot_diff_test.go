@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffEntities(t *testing.T) {
+	before := json.RawMessage(`[
+		{"id":"hero","pos":{"x":1,"y":1},"stats":{"hp":10},"vision":{"radius":8}},
+		{"id":"goblin_1","pos":{"x":5,"y":5}}
+	]`)
+	after := json.RawMessage(`[
+		{"id":"hero","pos":{"x":2,"y":1},"stats":{"hp":7},"vision":{"radius":3}},
+		{"id":"orc_1","pos":{"x":9,"y":9}}
+	]`)
+
+	changes, err := DiffEntities(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"goblin_1: removed",
+		"hero pos.x: 1 -> 2",
+		"hero stats.hp: 10 -> 7",
+		"orc_1: added",
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("unexpected diff:\n got %q\nwant %q", changes, want)
+	}
+}
@@ -0,0 +1,42 @@
+package domain
+
+import "testing"
+
+func TestEntitySnapshot_RoundTrip(t *testing.T) {
+	sword := &Entity{ID: "sword", Item: &ItemComponent{Category: ItemCategoryWeapon, Damage: 5}}
+	hero := &Entity{
+		ID:        "hero",
+		Pos:       Position{X: 3, Y: 4},
+		Stats:     &StatsComponent{HP: 10},
+		AI:        &AIComponent{NextActionTick: 700},
+		Inventory: &InventoryComponent{Items: []*Entity{sword}, MaxSlots: 5},
+		Equipment: &EquipmentComponent{Weapon: sword},
+	}
+	goblin := &Entity{ID: "goblin", Pos: Position{X: 5, Y: 5}, Stats: &StatsComponent{HP: 7}, AI: &AIComponent{NextActionTick: 650}}
+
+	data, err := EncodeEntities([]*Entity{hero, goblin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := DecodeEntities(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(restored) != 2 || restored[0].ID != "hero" || restored[1].ID != "goblin" {
+		t.Fatalf("order not preserved: %+v", restored)
+	}
+	if restored[0].Equipment.Weapon != restored[0].Inventory.Items[0] {
+		t.Error("equipped weapon is not linked to inventory item")
+	}
+
+	// Хеш не зависит от порядка сущностей, но зависит от состояния
+	original := StateHash(650, []*Entity{hero, goblin})
+	if got := StateHash(650, []*Entity{restored[1], restored[0]}); got != original {
+		t.Errorf("hash changed after round trip: %x != %x", got, original)
+	}
+	restored[1].Pos.X++
+	if StateHash(650, restored) == original {
+		t.Error("hash should change when an entity moves")
+	}
+}
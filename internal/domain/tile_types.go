@@ -0,0 +1,101 @@
+package domain
+
+// TileType - тип клетки карты. Поведение и вид каждого типа задает реестр tileTypes.
+// Нулевой тип - пол, чтобы пустая клетка была проходимой.
+type TileType uint8
+
+const (
+	TileFloor      TileType = iota // 0
+	TileStone                      // 1 - стена, порода
+	TileGrass                      // 2
+	TileStreet                     // 3
+	TileDoorClosed                 // 4
+	TileDoorOpen                   // 5
+	TileWater                      // 6 - глубокая вода, замедляет
+	TileLava                       // 7 - обжигает при входе
+	TileChasm                      // 8 - падение на уровень ниже
+	TileTrap                       // 9 - скрытая ловушка, выглядит как пол
+	TileTrapSprung                 // 10 - сработавшая ловушка
+)
+
+// TileEffect - что происходит с тем, кто вошел на клетку
+type TileEffect uint8
+
+const (
+	TileEffectNone TileEffect = iota
+	TileEffectBurn            // Урон Damage при каждом входе
+	TileEffectFall            // Урон Damage и падение на уровень ниже
+	TileEffectTrap            // Урон Damage, ловушка становится Sprung
+)
+
+// TileDef - описание типа клетки в реестре
+type TileDef struct {
+	Name     string // Ключ для клиента (TileView.type)
+	Walkable bool
+	Opaque   bool // Закрывает обзор
+	MoveCost int  // Тиков на шаг на эту клетку
+	Hazard   bool // Генератор не ставит сюда сущности
+	AIAvoids bool // ИИ обходит: опасность видна. Скрытую ловушку не обходят, иначе она себя выдаст.
+	OnEnter  TileEffect
+	Damage   int
+	Sprung   TileType // Во что превращается сработавшая ловушка
+
+	Toggles  bool     // Меняется через INTERACT (двери)
+	ToggleTo TileType // Во что превращается
+
+	Symbol string
+	Color  string
+}
+
+var tileTypes = map[TileType]TileDef{
+	TileFloor:  {Name: "floor", Walkable: true, MoveCost: TimeCostMove, Symbol: ".", Color: "#333333"},
+	TileStone:  {Name: "stone", Opaque: true, Symbol: "#", Color: "#666666"},
+	TileGrass:  {Name: "grass", Walkable: true, MoveCost: TimeCostMove, Symbol: ",", Color: "#3F6212"},
+	TileStreet: {Name: "street", Walkable: true, MoveCost: TimeCostMove, Symbol: ".", Color: "#78716C"},
+	TileDoorClosed: {
+		Name: "door_closed", Opaque: true, Toggles: true, ToggleTo: TileDoorOpen,
+		Symbol: "+", Color: "#A0522D",
+	},
+	TileDoorOpen: {
+		Name: "door_open", Walkable: true, MoveCost: TimeCostMove, Toggles: true, ToggleTo: TileDoorClosed,
+		Symbol: "'", Color: "#A0522D",
+	},
+	TileWater: {Name: "water", Walkable: true, MoveCost: TimeCostMove * 2, Symbol: "~", Color: "#2563EB"},
+	TileLava: {
+		Name: "lava", Walkable: true, MoveCost: TimeCostMove, Hazard: true, AIAvoids: true, OnEnter: TileEffectBurn, Damage: 6,
+		Symbol: "~", Color: "#F97316",
+	},
+	TileChasm: {
+		Name: "chasm", Walkable: true, MoveCost: TimeCostMove, Hazard: true, AIAvoids: true, OnEnter: TileEffectFall, Damage: 3,
+		Symbol: ":", Color: "#1E1B4B",
+	},
+	// Скрытая ловушка рисуется полом и отдается клиенту как пол, пока не сработает
+	TileTrap: {
+		Name: "floor", Walkable: true, MoveCost: TimeCostMove, Hazard: true, OnEnter: TileEffectTrap, Damage: 4,
+		Sprung: TileTrapSprung, Symbol: ".", Color: "#333333",
+	},
+	TileTrapSprung: {Name: "trap", Walkable: true, MoveCost: TimeCostMove, Symbol: "^", Color: "#DC2626"},
+}
+
+// Def возвращает описание типа. Неизвестный тип ведет себя как стена.
+func (t TileType) Def() TileDef {
+	if def, ok := tileTypes[t]; ok {
+		return def
+	}
+	return tileTypes[TileStone]
+}
+
+func (t TileType) String() string {
+	return t.Def().Name
+}
+
+// SetType меняет тип клетки и пересчитывает IsWall
+func (tile *Tile) SetType(t TileType) {
+	tile.Type = t
+	tile.IsWall = !t.Def().Walkable
+}
+
+// BlocksSight - закрывает ли клетка обзор
+func (tile Tile) BlocksSight() bool {
+	return tile.IsWall || tile.Type.Def().Opaque
+}
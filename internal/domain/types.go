@@ -13,6 +13,7 @@ const (
 	EntityTypeEnemy                     // 3
 	EntityTypeItem                      // 4
 	EntityTypeExit                      // 5
+	EntityTypeObject                    // 6 - неподвижный объект с триггером (алтарь, рычаг)
 )
 
 var entityTypeToString = map[EntityType]string{
@@ -21,6 +22,7 @@ var entityTypeToString = map[EntityType]string{
 	EntityTypeEnemy:  "ENEMY",
 	EntityTypeItem:   "ITEM",
 	EntityTypeExit:   "EXIT",
+	EntityTypeObject: "OBJECT",
 }
 
 var entityTypeStringToType = map[string]EntityType{
@@ -28,6 +30,7 @@ var entityTypeStringToType = map[string]EntityType{
 	"NPC":    EntityTypeNPC,
 	"ENEMY":  EntityTypeEnemy,
 	"EXIT":   EntityTypeExit,
+	"OBJECT": EntityTypeObject,
 }
 
 // String возвращает строковое представление (для логов и дебага)
@@ -92,6 +95,43 @@ func ParseItemCategory(s string) ItemCategory {
 	return ItemCategoryUnknown
 }
 
+// ItemRarity - редкость предмета. Обычная редкость нулевая, чтобы не попадать в JSON.
+type ItemRarity uint8
+
+const (
+	ItemRarityCommon ItemRarity = iota // 0
+	ItemRarityRare                     // 1
+	ItemRarityEpic                     // 2
+)
+
+var itemRarityToString = map[ItemRarity]string{
+	ItemRarityCommon: "COMMON",
+	ItemRarityRare:   "RARE",
+	ItemRarityEpic:   "EPIC",
+}
+
+var itemRarityStringToType = map[string]ItemRarity{
+	"COMMON": ItemRarityCommon,
+	"RARE":   ItemRarityRare,
+	"EPIC":   ItemRarityEpic,
+}
+
+// ItemRarities - все редкости по возрастанию (порядок важен для детерминизма бросков)
+var ItemRarities = []ItemRarity{ItemRarityCommon, ItemRarityRare, ItemRarityEpic}
+
+func (r ItemRarity) String() string {
+	if val, ok := itemRarityToString[r]; ok {
+		return val
+	}
+	return "UNKNOWN"
+}
+
+// ParseItemRarity конвертирует строку в редкость. ok = false для неизвестной строки.
+func ParseItemRarity(s string) (ItemRarity, bool) {
+	val, ok := itemRarityStringToType[strings.ToUpper(s)]
+	return val, ok
+}
+
 type AIStateType uint8
 
 const (
@@ -99,6 +139,7 @@ const (
 	AIStateIdle
 	AIStateCombat
 	AIStateFleeing
+	AIStateWander // Мирный житель бродит без цели
 )
 
 // ActionType - Внутренний числовой идентификатор действия
@@ -6,10 +6,10 @@ type Position struct {
 }
 
 type Tile struct {
-	X      int    `json:"x"`
-	Y      int    `json:"y"`
-	IsWall bool   `json:"isWall"`
-	Env    string `json:"env"` // floor, stone, grass
+	X      int      `json:"x"`
+	Y      int      `json:"y"`
+	IsWall bool     `json:"isWall"` // Непроходима; меняется вместе с типом через SetType
+	Type   TileType `json:"type"`
 
 	// В будущем сюда добавятся ссылки на предметы на полу
 }
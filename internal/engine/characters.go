@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/infrastructure/storage"
+	"cognitive-server/pkg/logger"
+	"errors"
+	"fmt"
+)
+
+// newCharacterStore выбирает хранилище персонажей по конфигу
+func newCharacterStore(dir string) storage.CharacterStore {
+	if dir == "" {
+		return storage.NewMemoryCharacterStore()
+	}
+	return storage.NewFileCharacterStore(dir)
+}
+
+// SaveCharacter сохраняет персонажа игрока. Для остальных сущностей ничего не делает.
+func (s *GameService) SaveCharacter(e *domain.Entity) {
+	if e == nil || e.Type != domain.EntityTypePlayer || s.Characters == nil {
+		return
+	}
+
+	rec := domain.NewCharacterRecord(e, now().Unix())
+	if err := s.Characters.Save(rec); err != nil {
+		logger.Log.WithField("entity_id", e.ID).Errorf("Failed to save character: %v", err)
+	}
+}
+
+// RestoreCharacter накладывает сохраненное состояние на свежесозданного игрока.
+// Возвращает false, если сохранения нет. Погибшие персонажи не восстанавливаются:
+// их запись удаляется, и игрок начинает заново.
+func (s *GameService) RestoreCharacter(e *domain.Entity) bool {
+	if s.Characters == nil {
+		return false
+	}
+
+	rec, err := s.Characters.Load(e.ID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrCharacterNotFound) {
+			logger.Log.WithField("entity_id", e.ID).Errorf("Failed to load character: %v", err)
+		}
+		return false
+	}
+
+	if rec.Stats.IsDead {
+		logger.Log.WithField("entity_id", e.ID).Info("Saved character is dead, starting fresh")
+		_ = s.Characters.Delete(e.ID)
+		return false
+	}
+
+	rec.ApplyTo(e)
+	logger.Log.WithField("entity_id", e.ID).Infof("Character restored on level %d", e.Level)
+	return true
+}
+
+// isWalkable проверяет, можно ли поставить сущность в клетку
+func isWalkable(w *domain.GameWorld, pos domain.Position) bool {
+	if pos.X < 0 || pos.X >= w.Width || pos.Y < 0 || pos.Y >= w.Height {
+		return false
+	}
+	return !w.Map[pos.Y][pos.X].IsWall
+}
+
+// findSpawnPos выбирает точку входа на уровень: верхняя лестница, центр карты
+// или первая свободная клетка.
+func findSpawnPos(w *domain.GameWorld, levelID int) domain.Position {
+	if exit := w.GetEntity(fmt.Sprintf("exit_up_from_%d", levelID)); exit != nil {
+		return exit.Pos
+	}
+
+	center := domain.Position{X: w.Width / 2, Y: w.Height / 2}
+	if isWalkable(w, center) {
+		return center
+	}
+
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if !w.Map[y][x].IsWall {
+				return domain.Position{X: x, Y: y}
+			}
+		}
+	}
+	return domain.Position{X: 1, Y: 1}
+}
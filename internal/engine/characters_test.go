@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/infrastructure/storage"
+	"cognitive-server/pkg/dungeon"
+	"cognitive-server/pkg/utils"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// newHero - свежий персонаж, как при входе клиента (см. server.Client)
+func newHero(id string) *domain.Entity {
+	return dungeon.CreatePlayer(id, rand.New(rand.NewSource(utils.StringToSeed(id))))
+}
+
+func loadCharacter(t *testing.T, s *GameService, id string) *domain.CharacterRecord {
+	t.Helper()
+	rec, err := s.Characters.Load(id)
+	if err != nil {
+		t.Fatalf("load %s: %v", id, err)
+	}
+	return rec
+}
+
+func TestRestoreCharacter(t *testing.T) {
+	s := newTestService(3)
+	s.Characters = storage.NewMemoryCharacterStore()
+
+	if s.RestoreCharacter(newHero("hero_1")) {
+		t.Fatal("restored a character that was never saved")
+	}
+
+	saved := newHero("hero_1")
+	saved.Level, saved.Pos = 2, domain.Position{X: 7, Y: 4}
+	saved.Stats.Gold = 321
+	s.SaveCharacter(saved)
+
+	hero := newHero("hero_1")
+	if !s.RestoreCharacter(hero) {
+		t.Fatal("saved character not restored")
+	}
+	if hero.Level != 2 || hero.Pos != saved.Pos || hero.Stats.Gold != 321 {
+		t.Errorf("restored level %d at %+v with %d gold", hero.Level, hero.Pos, hero.Stats.Gold)
+	}
+}
+
+// Погибший персонаж не возвращается, а его запись удаляется: следующий вход - новый персонаж
+func TestRestoreCharacter_DeadRecordDeleted(t *testing.T) {
+	s := newTestService(3)
+	s.Characters = storage.NewMemoryCharacterStore()
+
+	dead := newHero("hero_1")
+	dead.Level = 3
+	dead.Stats.IsDead = true
+	s.SaveCharacter(dead)
+
+	hero := newHero("hero_1")
+	if s.RestoreCharacter(hero) {
+		t.Fatal("dead character restored")
+	}
+	if hero.Level != 0 || hero.Stats.IsDead {
+		t.Errorf("fresh character changed: level %d, dead %v", hero.Level, hero.Stats.IsDead)
+	}
+	if _, err := s.Characters.Load("hero_1"); !errors.Is(err, storage.ErrCharacterNotFound) {
+		t.Errorf("dead record kept: %v", err)
+	}
+}
+
+// Сохраненная клетка стала стеной (карта уровня поменялась) - персонаж встает в точку входа
+func TestAddPlayerToLevel_FallbackSpawn(t *testing.T) {
+	s := newTestService(3)
+	s.Characters = storage.NewMemoryCharacterStore()
+
+	level1, _ := s.Levels.GetOrCreate(1, nil)
+	world := level1.World
+	var wall domain.Position
+	for y := 0; y < world.Height; y++ {
+		for x := 0; x < world.Width; x++ {
+			if world.Map[y][x].IsWall {
+				wall = domain.Position{X: x, Y: y}
+				y = world.Height
+				break
+			}
+		}
+	}
+
+	saved := newHero("hero_1")
+	saved.Level, saved.Pos = 1, wall
+	s.SaveCharacter(saved)
+
+	hero := newHero("hero_1")
+	if !s.RestoreCharacter(hero) {
+		t.Fatal("saved character not restored")
+	}
+	s.AddPlayerToLevel(hero)
+
+	want := findSpawnPos(world, 1)
+	if hero.Pos != want || !isWalkable(world, hero.Pos) {
+		t.Errorf("spawned at %+v, want the level entry %+v", hero.Pos, want)
+	}
+	if level, _ := s.Levels.Location(hero.ID); level != 1 {
+		t.Errorf("hero indexed on level %d, want 1", level)
+	}
+}
+
+// Персонаж сохраняется при переходе между уровнями, при выходе из игры и при остановке сервера
+func TestSaveCharacter_Triggers(t *testing.T) {
+	s := newTestService(3)
+	s.Characters = storage.NewMemoryCharacterStore()
+	hero := s.Levels.createInitial()
+	hero.ControllerID = "session_hero_1"
+
+	s.ChangeLevel(hero, 1, "exit_up_from_1")
+	if rec := loadCharacter(t, s, hero.ID); rec.Level != 1 || rec.Pos != hero.Pos {
+		t.Errorf("after level change saved level %d at %+v, want 1 at %+v", rec.Level, rec.Pos, hero.Pos)
+	}
+
+	// Выход из игры: игрок все еще числится на уровне
+	level1, _ := s.Levels.Get(1)
+	hero.Stats.Gold = 111
+	level1.handleLeave(hero.ID)
+	if rec := loadCharacter(t, s, hero.ID); rec.Stats.Gold != 111 {
+		t.Errorf("after logout saved %d gold, want 111", rec.Stats.Gold)
+	}
+	if level1.World.GetEntity(hero.ID) != nil {
+		t.Error("hero left on the level after logout")
+	}
+
+	// Остановка сервера: уровни сохраняют управляемых игроков в своих циклах
+	level1.handleJoin(hero)
+	hero.Stats.Gold = 222
+	for _, instance := range s.Levels.All() {
+		go instance.Run()
+	}
+	s.Shutdown()
+	if rec := loadCharacter(t, s, hero.ID); rec.Stats.Gold != 222 {
+		t.Errorf("after shutdown saved %d gold, want 222", rec.Stats.Gold)
+	}
+}
@@ -7,11 +7,38 @@ type Config struct {
 	// Seed - мастер-зерно. От него будут зависеть все уровни.
 	// Level N Seed = MasterSeed + N (или хеш от этого сочетания)
 	Seed int64
+
+	// ReplayDir - папка для сохранения реплеев. Пустая строка - реплеи забегов не пишутся.
+	ReplayDir string
+
+	// CharacterDir - папка для сохранения персонажей. Пустая строка - хранить только в памяти.
+	CharacterDir string
+	// CharacterSaveInterval - как часто инстанс сохраняет онлайн-игроков.
+	CharacterSaveInterval time.Duration
+
+	// JournalDir - папка для журнала действий (crash recovery). Пустая строка - журнал выключен.
+	JournalDir string
+
+	// StatsDir - папка для истории забегов. Пустая строка - хранить только в памяти.
+	StatsDir string
+
+	// ContentDir - папка с паками контента (*.json) поверх встроенного. Нет папки - только встроенный контент.
+	ContentDir string
+
+	// AdminToken - общий секрет admin-эндпоинтов (заголовок Authorization: Bearer).
+	// Пустая строка - admin-эндпоинты выключены.
+	AdminToken string
 }
 
 // NewConfig создает конфиг по умолчанию (случайный сид)
 func NewConfig() Config {
 	return Config{
-		Seed: time.Now().UnixNano(),
+		Seed:                  time.Now().UnixNano(),
+		ReplayDir:             "./replays",
+		CharacterDir:          "./characters",
+		CharacterSaveInterval: 30 * time.Second,
+		JournalDir:            "./journal",
+		StatsDir:              "./stats",
+		ContentDir:            "./content",
 	}
 }
@@ -29,8 +29,12 @@ func (s *GameService) processEvent(actor *domain.Entity, eventData json.RawMessa
 		return
 	}
 
-	levelID := s.EntityLocations[actor.ID]
-	instance := s.Instances[levelID]
+	levelID, _ := s.Levels.Location(actor.ID)
+	instance, ok := s.Levels.Get(levelID)
+	if !ok {
+		logger.Log.Warnf("Event for %s on unknown level %d", actor.ID, levelID)
+		return
+	}
 
 	ctx := handlers.Context{
 		Finder:   instance.World,
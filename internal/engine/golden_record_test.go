@@ -0,0 +1,280 @@
+package engine
+
+import (
+	"bytes"
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/infrastructure/storage"
+	"cognitive-server/pkg/api"
+	"cognitive-server/pkg/dungeon"
+	"cognitive-server/pkg/logger"
+	"cognitive-server/pkg/utils"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Корпус золотых реплеев записывается из исходников: сценарий - это входы клиентов
+// (логин, команды), а запись ведет тот же движок, что и на сервере. Ходы идут синхронно,
+// по одному, поэтому при тех же сценариях и том же геймплее корпус байт в байт тот же.
+
+// goldenMaxSteps - предохранитель от сценария, который никогда не закончится
+const goldenMaxSteps = 100000
+
+// goldenScenario - сценарий записи одного забега (или нескольких, если игроков больше одного)
+type goldenScenario struct {
+	Name string
+	Seed int64
+	Play func(r *goldenRecorder)
+}
+
+var goldenScenarios = []goldenScenario{
+	{
+		Name: "solo_descent",
+		Seed: 3,
+		Play: func(r *goldenRecorder) {
+			r.login("hero_1")
+			r.queue("hero_1", teleportCmd(1))
+			r.queue("hero_1", walkCmds(31, 200)...)
+			r.play()
+		},
+	},
+	{
+		// Длинная прогулка по городу: несколько ключевых кадров в одном сегменте (для перемотки)
+		Name: "long_walk",
+		Seed: 5,
+		Play: func(r *goldenRecorder) {
+			r.login("hero_1")
+			r.queue("hero_1", walkCmds(51, 3*domain.DefaultReplayKeyframeInterval)...)
+			r.play()
+		},
+	},
+	{
+		// Второй игрок входит посреди игры первого и догоняет его на первом уровне
+		Name: "duo",
+		Seed: 9,
+		Play: func(r *goldenRecorder) {
+			r.login("hero_1")
+			r.queue("hero_1", teleportCmd(1))
+			r.queue("hero_1", walkCmds(91, 40)...)
+			r.play()
+
+			r.login("hero_2")
+			r.queue("hero_1", walkCmds(92, 110)...)
+			r.queue("hero_2", teleportCmd(1))
+			r.queue("hero_2", walkCmds(93, 150)...)
+			r.play()
+		},
+	},
+}
+
+// goldenRecorder проигрывает сценарий на офлайн-сервисе с обычными (не playback) уровнями.
+// Уровнями управляет сам рекордер: ходит тот, у кого меньше время, пока у игроков есть команды.
+type goldenRecorder struct {
+	t       *testing.T
+	service *GameService
+	queues  map[string][]domain.InternalCommand
+	files   map[string]*bytes.Buffer
+	players []string // Порядок входа
+}
+
+func newGoldenRecorder(t *testing.T, seed int64) *goldenRecorder {
+	logger.Init()
+	logger.Log.SetLevel(logrus.FatalLevel)
+
+	// Папка нужна только как признак "реплеи пишутся": файлы забегов заведены заранее
+	s := newService(Config{Seed: seed, ReplayDir: t.TempDir()})
+	s.offline = true
+	s.Levels.createInitial()
+
+	return &goldenRecorder{
+		t:       t,
+		service: s,
+		queues:  make(map[string][]domain.InternalCommand),
+		files:   make(map[string]*bytes.Buffer),
+	}
+}
+
+// login повторяет вход клиента (см. server.Client): создание персонажа, подписка и INIT.
+// Новый персонаж встает в точку входа поверхности.
+func (r *goldenRecorder) login(token string) {
+	s := r.service
+
+	// Файл забега заводим сами: имя и время в заголовке не должны зависеть от часов
+	buf := &bytes.Buffer{}
+	w, err := storage.NewRunWriter(buf, token, 0)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	s.runReplays[token] = w
+	r.files[token] = buf
+	r.players = append(r.players, token)
+
+	ent := s.GetEntity(token)
+	if ent == nil {
+		ent = dungeon.CreatePlayer(token, rand.New(rand.NewSource(utils.StringToSeed(token))))
+		ent.Pos = findSpawnPos(s.Levels.World(0), 0)
+		s.AddPlayerToLevel(ent)
+		instance, _ := s.Levels.Get(ent.Level)
+		instance.handleJoin(<-instance.JoinChan)
+	}
+
+	ent.ControllerID = "session_" + token
+	s.Hub.Register(token)
+	r.queue(token, domain.InternalCommand{Action: domain.ActionInit, Token: token})
+}
+
+func (r *goldenRecorder) queue(token string, cmds ...domain.InternalCommand) {
+	for _, cmd := range cmds {
+		cmd.Token = token
+		r.queues[token] = append(r.queues[token], cmd)
+	}
+}
+
+// play ходит, пока у живых игроков остаются команды
+func (r *goldenRecorder) play() {
+	for step := 0; ; step++ {
+		if step > goldenMaxSteps {
+			r.t.Fatalf("scenario did not finish in %d steps", goldenMaxSteps)
+		}
+		instance := r.nextInstance()
+		if instance == nil {
+			return
+		}
+		r.step(instance)
+	}
+}
+
+// nextInstance выбирает уровень с игроком, у которого остались команды, и наименьшим временем
+func (r *goldenRecorder) nextInstance() *Instance {
+	var next *Instance
+	for _, token := range r.players {
+		e := r.service.GetEntity(token)
+		if e == nil || (e.Stats != nil && e.Stats.IsDead) {
+			delete(r.queues, token)
+		}
+		if len(r.queues[token]) == 0 {
+			continue
+		}
+		instance, ok := r.service.Levels.Get(e.Level)
+		if !ok {
+			continue
+		}
+		if next == nil || instance.CurrentTick < next.CurrentTick ||
+			(instance.CurrentTick == next.CurrentTick && instance.ID < next.ID) {
+			next = instance
+		}
+	}
+	return next
+}
+
+// step - один ход уровня, как в Instance.Run, но команда берется из сценария
+func (r *goldenRecorder) step(i *Instance) {
+	actor, isHuman := i.beginTurn()
+	if actor == nil {
+		return
+	}
+
+	if !isHuman {
+		i.processAITurn(actor)
+	} else {
+		for {
+			cmds := r.queues[actor.ID]
+			if len(cmds) == 0 {
+				// Игрок молчит: как по таймауту на сервере
+				i.executeCommand(domain.InternalCommand{
+					Action:     domain.ActionWait,
+					Token:      actor.ID,
+					Controller: domain.ControllerTimeout,
+				}, actor)
+				break
+			}
+			cmd := cmds[0]
+			r.queues[actor.ID] = cmds[1:]
+			i.executeCommand(cmd, actor)
+			if cmd.Action != domain.ActionInit {
+				break
+			}
+		}
+	}
+
+	i.endTurn(actor)
+}
+
+// finish закрывает сегменты и файлы забегов, как остановка сервера
+func (r *goldenRecorder) finish() map[string][]byte {
+	s := r.service
+	for _, instance := range s.Levels.All() {
+		instance.cutReplay(nil)
+	}
+	for _, token := range r.players {
+		s.saveRunReplay(token)
+	}
+
+	// Забег, начатый заново (после смерти или выхода), ушел бы в отдельный файл
+	if extra, _ := filepath.Glob(filepath.Join(s.Config.ReplayDir, "*.cdrp")); len(extra) > 0 {
+		r.t.Fatalf("scenario produced extra run files: %v", extra)
+	}
+
+	out := make(map[string][]byte, len(r.files))
+	for token, buf := range r.files {
+		out[token] = buf.Bytes()
+	}
+	return out
+}
+
+// recordGoldenCorpus перезаписывает .cdrp корпуса по сценариям
+func recordGoldenCorpus(t *testing.T) {
+	t.Helper()
+
+	// Метки времени в сегментах и статистике забега - как у записи в начале эпохи
+	now = func() time.Time { return time.Unix(0, 0) }
+	defer func() { now = time.Now }()
+
+	for _, sc := range goldenScenarios {
+		r := newGoldenRecorder(t, sc.Seed)
+		sc.Play(r)
+		runs := r.finish()
+
+		players := make([]string, 0, len(runs))
+		for token := range runs {
+			players = append(players, token)
+		}
+		sort.Strings(players)
+
+		for _, token := range players {
+			name := sc.Name
+			if len(players) > 1 {
+				name += "_" + token
+			}
+			if err := os.WriteFile(filepath.Join(goldenDir, name+".cdrp"), runs[token], 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func teleportCmd(level int) domain.InternalCommand {
+	payload, _ := json.Marshal(map[string]int{"x": 0, "y": 0, "level": level})
+	return domain.InternalCommand{Action: domain.ActionAdminTeleport, Payload: payload}
+}
+
+// walkCmds - n случайных шагов (восемь направлений) из своего генератора
+func walkCmds(seed int64, n int) []domain.InternalCommand {
+	rng := rand.New(rand.NewSource(seed))
+	cmds := make([]domain.InternalCommand, 0, n)
+	for len(cmds) < n {
+		dx, dy := rng.Intn(3)-1, rng.Intn(3)-1
+		if dx == 0 && dy == 0 {
+			continue
+		}
+		payload, _ := json.Marshal(api.DirectionPayload{Dx: dx, Dy: dy})
+		cmds = append(cmds, domain.InternalCommand{Action: domain.ActionMove, Payload: payload})
+	}
+	return cmds
+}
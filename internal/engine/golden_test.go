@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/pkg/logger"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Золотые реплеи: testdata/replays/*.cdrp проигрываются целиком, итог сверяется с <имя>.golden.
+// После осознанного изменения геймплея или формата записи корпус перезаписывается по сценариям
+// (golden_record_test.go), а эталоны - по новому корпусу:
+//
+//	go test ./internal/engine -run TestGoldenReplays -update
+var updateGolden = flag.Bool("update", false, "Re-record replays and rewrite golden files in testdata/replays")
+
+const goldenDir = "testdata/replays"
+
+// goldenResult - итог проигрывания реплея, который хранится в .golden
+type goldenResult struct {
+	Segments int           `json:"segments"`
+	Actions  int           `json:"actions"`
+	Levels   []goldenLevel `json:"levels"`
+}
+
+// goldenLevel - конечное состояние уровня, затронутого реплеем
+type goldenLevel struct {
+	Level    int    `json:"level"`
+	Tick     int    `json:"tick"`
+	Entities int    `json:"entities"`
+	Hash     string `json:"hash"`
+}
+
+func TestGoldenReplays(t *testing.T) {
+	logger.Init()
+	logger.Log.SetLevel(logrus.FatalLevel)
+
+	if *updateGolden {
+		recordGoldenCorpus(t)
+	}
+
+	files, err := filepath.Glob(filepath.Join(goldenDir, "*.cdrp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no replays in %s", goldenDir)
+	}
+
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.Base(path), ".cdrp")
+		t.Run(name, func(t *testing.T) {
+			got := playGolden(t, path)
+			goldenPath := strings.TrimSuffix(path, ".cdrp") + ".golden"
+
+			if *updateGolden {
+				data, err := json.MarshalIndent(got, "", "  ")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(goldenPath, append(data, '\n'), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			data, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			var want goldenResult
+			if err := json.Unmarshal(data, &want); err != nil {
+				t.Fatalf("corrupted golden file: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("replay outcome changed (run with -update if intended)\ngot:  %+v\nwant: %+v", got, want)
+			}
+		})
+	}
+}
+
+// playGolden проигрывает реплей без сверки записанных хешей: эталоном служит .golden,
+// поэтому изменение геймплея видно как разница итогов, а не как первое расхождение.
+func playGolden(t *testing.T, path string) goldenResult {
+	t.Helper()
+
+	service := NewPlaybackService()
+	if err := service.LoadReplay(path); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	result, err := service.PlayReplay(false)
+	if err != nil {
+		t.Fatalf("play: %v", err)
+	}
+
+	got := goldenResult{Segments: result.Segments, Actions: result.Actions}
+	for _, instance := range service.Levels.All() {
+		got.Levels = append(got.Levels, goldenLevel{
+			Level:    instance.ID,
+			Tick:     instance.CurrentTick,
+			Entities: len(instance.Entities),
+			Hash:     fmt.Sprintf("%016x", domain.StateHash(instance.CurrentTick, instance.Entities)),
+		})
+	}
+	sort.Slice(got.Levels, func(a, b int) bool { return got.Levels[a].Level < got.Levels[b].Level })
+	return got
+}
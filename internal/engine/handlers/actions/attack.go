@@ -1,7 +1,6 @@
 package actions
 
 import (
-	"cognitive-server/internal/domain"
 	"cognitive-server/internal/engine/handlers"
 	"cognitive-server/internal/systems" // Импортируем системы
 	"cognitive-server/pkg/api"
@@ -22,7 +21,7 @@ func HandleAttack(ctx handlers.Context, p api.EntityPayload) (handlers.Result, e
 	logMsg := systems.ApplyAttack(ctx.Actor, target, ctx.Rng)
 
 	// 3. Трата времени
-	handlers.SpendActionPoints(ctx.Actor, domain.TimeCostAttackLight)
+	handlers.SpendActionPoints(ctx.Actor, systems.AttackCost(ctx.Actor))
 
 	return handlers.Result{
 		Msg:     logMsg,
@@ -8,7 +8,17 @@ import (
 	"fmt"
 )
 
-func HandleInteract(ctx handlers.Context, p api.EntityPayload) (handlers.Result, error) {
+func HandleInteract(ctx handlers.Context, p api.InteractPayload) (handlers.Result, error) {
+	// Клетка (дверь) вместо сущности
+	if p.Tile != nil {
+		msg, err := systems.ToggleTile(ctx.Actor, domain.Position{X: p.Tile.X, Y: p.Tile.Y}, ctx.World)
+		if err != nil {
+			return handlers.Result{Msg: err.Error(), MsgType: "ERROR"}, nil
+		}
+		handlers.SpendActionPoints(ctx.Actor, domain.TimeCostInteract)
+		return handlers.Result{Msg: msg, MsgType: "INFO"}, nil
+	}
+
 	// 1. Валидация через TargetingSystem
 	// Дистанция 1.5 (можно нажать рычаг под ногами или рядом)
 	// LOS = false, так как если мы стоим на лестнице, мы её "чувствуем", даже если под ногами
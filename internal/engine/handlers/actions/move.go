@@ -24,7 +24,7 @@ func HandleMove(ctx handlers.Context, p api.DirectionPayload) (handlers.Result,
 
 		if actorHostile != targetHostile {
 			logMsg := systems.ApplyAttack(ctx.Actor, res.BlockedBy, ctx.Rng)
-			ctx.Actor.AI.Wait(domain.TimeCostAttackLight)
+			ctx.Actor.AI.Wait(systems.AttackCost(ctx.Actor))
 			return handlers.Result{Msg: logMsg, MsgType: "COMBAT"}, nil
 		}
 	}
@@ -40,12 +40,22 @@ func HandleMove(ctx handlers.Context, p api.DirectionPayload) (handlers.Result,
 			ctx.Actor.Vision.IsDirty = true
 		}
 
-		ctx.Actor.AI.Wait(domain.TimeCostMove)
-		return handlers.EmptyResult(), nil
+		// Шаг стоит столько, сколько клетка, на которую шагнули (по воде дольше)
+		ctx.Actor.AI.Wait(ctx.World.Map[res.NewY][res.NewX].Type.Def().MoveCost)
+
+		// Лава, ловушки, пропасти
+		effect := systems.ApplyTileEffects(ctx.Actor, ctx.World)
+		if effect.Msg == "" && effect.Event == nil {
+			return handlers.EmptyResult(), nil
+		}
+		return handlers.Result{Msg: effect.Msg, MsgType: "COMBAT", Event: effect.Event}, nil
 	}
 
 	if res.IsWall {
 		if ctx.Actor.Type == domain.EntityTypePlayer {
+			if res.IsDoor {
+				return handlers.Result{Msg: "Дверь закрыта.", MsgType: "ERROR"}, nil
+			}
 			return handlers.Result{Msg: "Путь прегражден.", MsgType: "ERROR"}, nil
 		}
 		ctx.Actor.AI.Wait(domain.TimeCostWait)
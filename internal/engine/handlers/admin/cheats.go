@@ -16,10 +16,11 @@ type TeleportPayload struct {
 func HandleTeleport(ctx handlers.Context, p TeleportPayload) (handlers.Result, error) {
 	// 1. Смена уровня, если нужно
 	if p.Level != 0 && p.Level != ctx.Actor.Level {
-		ctx.Switcher.ChangeLevel(ctx.Actor, p.Level, "") // "" targetPosID -> force coords later
-		// Координаты обновятся в следующем цикле или тут же, если Switcher синхронный.
-		// Но в нашей архитектуре ChangeLevel перемещает в дефолтную точку.
+		// Встаем к верхней лестнице, как при обычном спуске. Координаты не применяем:
+		// актор уже передан другому инстансу, а ctx.World - старый мир.
 		// Для точного телепорта лучше реализовать метод ForcePosition в GameService.
+		ctx.Switcher.ChangeLevel(ctx.Actor, p.Level, fmt.Sprintf("exit_up_from_%d", p.Level))
+		return handlers.Result{Msg: "⚡ Teleported via Admin Magic", MsgType: "INFO"}, nil
 	}
 
 	// 2. Перемещение внутри уровня
@@ -43,8 +44,10 @@ type SpawnPayload struct {
 }
 
 func HandleSpawn(ctx handlers.Context, p SpawnPayload) (handlers.Result, error) {
+	content := dungeon.Templates()
+
 	// Ищем врага
-	if tmpl, ok := dungeon.EnemyTemplates[p.Template]; ok {
+	if tmpl, ok := content.Enemies[p.Template]; ok {
 		// Спавним рядом с игроком
 		pos := ctx.Actor.Pos.Shift(1, 0)
 		if ctx.World.Map[pos.Y][pos.X].IsWall {
@@ -57,7 +60,7 @@ func HandleSpawn(ctx handlers.Context, p SpawnPayload) (handlers.Result, error)
 	}
 
 	// Ищем предмет
-	if tmpl, ok := dungeon.ItemTemplates[p.Template]; ok {
+	if tmpl, ok := content.Items[p.Template]; ok {
 		pos := ctx.Actor.Pos // Под ноги
 		item := tmpl.SpawnItem(pos, ctx.Actor.Level, ctx.Rng)
 		ctx.AddGlobalEntity(item)
@@ -86,7 +89,9 @@ func HandleKill(ctx handlers.Context, p KillPayload) (handlers.Result, error) {
 		return handlers.Result{Msg: "Target not found", MsgType: "ERROR"}, nil
 	}
 	if target.Stats != nil {
-		target.Stats.TakeDamage(9999)
+		if target.Stats.TakeDamage(9999) && target.Run != nil {
+			target.Run.CauseOfDeath = "кара администратора"
+		}
 	}
 	return handlers.Result{Msg: fmt.Sprintf("💀 Smited %s", target.Name), MsgType: "COMBAT"}, nil
 }
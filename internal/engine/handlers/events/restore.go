@@ -0,0 +1,42 @@
+package events
+
+import (
+	"cognitive-server/internal/engine/handlers"
+	"encoding/json"
+	"fmt"
+)
+
+// HandleRestore восстанавливает здоровье и выносливость актора (алтари в хранилищах).
+// {"event": "RESTORE", "hp": 30, "stamina": 0, "message": "..."} - 0 или пропуск значит "до максимума".
+func HandleRestore(ctx handlers.Context, eventData json.RawMessage) (handlers.Result, error) {
+	var restoreEvent struct {
+		HP      int    `json:"hp"`
+		Stamina int    `json:"stamina"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(eventData, &restoreEvent); err != nil {
+		return handlers.EmptyResult(), fmt.Errorf("parse RESTORE event: %w", err)
+	}
+
+	stats := ctx.Actor.Stats
+	if stats == nil || stats.IsDead {
+		return handlers.EmptyResult(), nil
+	}
+
+	stats.HP = restoreTo(stats.HP, stats.MaxHP, restoreEvent.HP)
+	stats.Stamina = restoreTo(stats.Stamina, stats.MaxStamina, restoreEvent.Stamina)
+
+	msg := restoreEvent.Message
+	if msg == "" {
+		msg = "Силы возвращаются к вам."
+	}
+	return handlers.Result{Msg: msg, MsgType: "INFO"}, nil
+}
+
+// restoreTo прибавляет amount, не выходя за максимум. amount <= 0 - до максимума.
+func restoreTo(current, maximum, amount int) int {
+	if amount <= 0 {
+		return max(current, maximum)
+	}
+	return max(current, min(maximum, current+amount))
+}
@@ -25,8 +25,7 @@ type Context struct {
 	Actor    *domain.Entity   // Тот, кто выполняет команду (Игрок или NPC)
 
 	// --- Global Context for Events ---
-	Worlds          map[int]*domain.GameWorld // Доступ ко всем мирам (нужен для переходов)
-	AddGlobalEntity func(*domain.Entity)      // Коллбэк для регистрации новой сущности в глобальном стейте
+	AddGlobalEntity func(*domain.Entity) // Коллбэк для регистрации новой сущности в глобальном стейте
 	Switcher        WorldSwitcher
 	Rng             *rand.Rand
 }
@@ -3,6 +3,7 @@ package engine
 import (
 	"cognitive-server/internal/domain"
 	"cognitive-server/internal/engine/handlers"
+	"cognitive-server/internal/infrastructure/storage"
 	"cognitive-server/internal/systems"
 	"cognitive-server/pkg/api"
 	"cognitive-server/pkg/logger"
@@ -19,6 +20,31 @@ type InstanceCommand struct {
 	Source *domain.Entity
 }
 
+// Причины расхождения реплея
+const (
+	DesyncStateHash  = "state_hash"  // Хеш состояния не совпал с записанным
+	DesyncActorOrder = "actor_order" // Ходит не тот, чье действие следующее в записи
+	DesyncTransition = "transition"  // Переход между уровнями прошел не так, как записано
+	DesyncKeyframe   = "keyframe"    // Кадр записи не совпал с состоянием, до которого дошла симуляция
+)
+
+// Desync - расхождение состояния при воспроизведении реплея
+type Desync struct {
+	Segment  int    // Индекс сегмента в реплее забега
+	Level    int    // Уровень сегмента
+	Action   int    // Индекс действия, перед которым сравнивались хеши
+	Tick     int    // Тик инстанса в этот момент
+	Actor    string // Чье действие стоит в записи под этим индексом
+	Reason   string // DesyncStateHash или DesyncActorOrder
+	Expected uint64 // Хеш из записи
+	Actual   uint64 // Хеш симуляции
+
+	// Сущности (EncodeEntities) на последней совпавшей контрольной точке и в момент расхождения.
+	// Заполняются только в VerifyMode.
+	Before json.RawMessage
+	After  json.RawMessage
+}
+
 // Instance представляет собой один изолированный запущенный уровень (игровую зону).
 type Instance struct {
 	ID    int               // ID уровня
@@ -40,18 +66,37 @@ type Instance struct {
 
 	Logs []api.LogEntry // Локальные логи уровня
 
-	Rng    *rand.Rand            // Локальный генератор
-	Seed   int64                 // Сид, с которого начался уровень
-	Replay *domain.ReplaySession // Лента событий
+	Rng       *rand.Rand            // Локальный генератор
+	rngSource *countingSource       // Источник Rng: по нему кадр реплея запоминает состояние генератора
+	Seed      int64                 // Сид, с которого начался уровень
+	Replay    *domain.ReplaySession // Лента событий
+
+	recorded   int                  // Записано действий в текущем сегменте
+	activeID   string               // Чей ход сейчас идет (пусто между ходами)
+	replayRuns []*storage.RunWriter // Файлы забегов, в которые пишется текущий сегмент
 
 	IsPlayback      bool                  // Флаг режима воспроизведения
 	PlaybackActions []domain.ReplayAction // Очередь действий для исполнения
 	PlaybackCursor  int                   // Индекс текущего действия
+	resumeActor     string                // Кто ходит первым: сегмент начался посреди его хода
 
+	PlaybackChecksums map[int]uint64                 // Ожидаемые хеши состояния (индекс действия -> хеш)
+	PlaybackKeyframes map[int]*domain.ReplayKeyframe // Кадры записи (индекс действия -> кадр)
+	Desyncs           []Desync                       // Найденные при воспроизведении расхождения
+
+	// VerifyMode - режим проверки детерминизма: симуляция останавливается на первом
+	// расхождении, а для диффа хранится состояние последней совпавшей контрольной точки.
+	VerifyMode    bool
+	lastGoodState json.RawMessage
+
+	Journal *storage.Journal // Write-ahead журнал действий (открывается при первом действии)
+
+	quit chan struct{} // Закрывается Stop: цикл сохраняется и выходит
+	done chan struct{} // Закрывается циклом при выходе
 }
 
 func NewInstance(id int, world *domain.GameWorld, service *GameService, seed int64) *Instance {
-	rngSource := rand.NewSource(seed)
+	rngSource := newCountingSource(seed)
 	rng := rand.New(rngSource)
 	return &Instance{
 		ID:          id,
@@ -66,57 +111,64 @@ func NewInstance(id int, world *domain.GameWorld, service *GameService, seed int
 		Logs:        []api.LogEntry{},
 		Seed:        seed,
 		Rng:         rng,
-		Replay: &domain.ReplaySession{
-			LevelID:   id,
-			Seed:      seed,
-			Timestamp: time.Now().Unix(),
-			Actions:   make([]domain.ReplayAction, 0),
-		},
+		rngSource:   rngSource,
+		Replay:      newReplaySession(id, seed),
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// newReplaySession создает пустую запись уровня
+func newReplaySession(levelID int, seed int64) *domain.ReplaySession {
+	return &domain.ReplaySession{
+		LevelID:      levelID,
+		Seed:         seed,
+		Recipe:       levelRecipe(levelID),
+		Timestamp:    now().Unix(),
+		HashInterval: domain.DefaultReplayHashInterval,
+		Actions:      make([]domain.ReplayAction, 0),
 	}
 }
 
 // Run запускает игровой цикл ЭТОГО инстанса.
 func (i *Instance) Run() {
 	logger.Log.WithField("instance_id", i.ID).Info("Instance loop started")
+	defer close(i.done)
+
+	// Периодическое сохранение онлайн-игроков (nil-канал никогда не срабатывает)
+	var saveTick <-chan time.Time
+	if interval := i.Service.Config.CharacterSaveInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		saveTick = ticker.C
+	}
 
 	for {
 		// 1. Обработка входа/выхода (неблокирующая)
 		select {
 		case newEntity := <-i.JoinChan:
-			i.addEntity(newEntity)
+			i.handleJoin(newEntity)
 		case leftID := <-i.LeaveChan:
-			i.removeEntity(leftID)
+			i.handleLeave(leftID)
+		case <-saveTick:
+			i.SaveCharacters()
+		case <-i.quit:
+			i.shutdown()
+			return
 		default:
 		}
 
 		// 2. Кто ходит?
-		item := i.TurnManager.PeekNext()
-		if item == nil {
+		if i.TurnManager.PeekNext() == nil {
 			time.Sleep(100 * time.Millisecond) // Спим, если уровень пуст
 			continue
 		}
-
-		activeActor := item.Value
-		i.CurrentTick = activeActor.AI.NextActionTick
-
-		// 3. Проверка смерти
-		if activeActor.Stats != nil && activeActor.Stats.IsDead {
-			i.TurnManager.RemoveEntity(activeActor.ID)
-			// Если был подписчик - обновляем ему экран
-			if i.Service.Hub.HasSubscriber(activeActor.ID) {
-				// Внимание: publishUpdate пока в Service, мы это поправим на след. шаге
-				i.Service.publishUpdate(activeActor.ID, i)
-			}
+		activeActor, isHuman := i.beginTurn()
+		if activeActor == nil {
 			continue
 		}
 
-		// 4. Рассылка состояния (тем, кто смотрит на этого актора)
-		if i.Service.Hub.HasSubscriber(activeActor.ID) {
-			i.Service.publishUpdate(activeActor.ID, i)
-		}
-
-		// 5. Логика хода
-		isHuman := i.Service.Hub.HasSubscriber(activeActor.ID)
+		// 3. Логика хода
 
 		if !isHuman {
 			i.processAITurn(activeActor)
@@ -129,16 +181,23 @@ func (i *Instance) Run() {
 				select {
 				// Вход во время ожидания
 				case newEntity := <-i.JoinChan:
-					i.addEntity(newEntity)
+					i.handleJoin(newEntity)
 
 				// Выход во время ожидания
 				case leftID := <-i.LeaveChan:
-					i.removeEntity(leftID)
+					i.handleLeave(leftID)
 					if leftID == activeActor.ID {
 						activeActor.AI.Wait(domain.TimeCostWait)
 						processed = true
 					}
 
+				case <-saveTick:
+					i.SaveCharacters()
+
+				case <-i.quit:
+					i.shutdown()
+					return
+
 				// Команда
 				case wrapper := <-i.CommandChan:
 					// Обрабатываем команду, если это активный игрок или системная команда
@@ -146,6 +205,9 @@ func (i *Instance) Run() {
 						i.executeCommand(wrapper.Cmd, wrapper.Source)
 						if wrapper.Cmd.Action != domain.ActionInit {
 							processed = true
+						} else {
+							// INIT не тратит ход, но клиенту нужна первая картинка
+							i.Service.publishUpdate(activeActor.ID, i)
 						}
 					}
 
@@ -154,15 +216,74 @@ func (i *Instance) Run() {
 						"instance": i.ID,
 						"actor":    activeActor.ID,
 					}).Warn("Turn timed out")
-					activeActor.AI.Wait(domain.TimeCostWait)
+					// Пропуск хода пишем в реплей как обычное ожидание, иначе запись разойдется
+					i.executeCommand(domain.InternalCommand{
+						Action:     domain.ActionWait,
+						Token:      activeActor.ID,
+						Controller: domain.ControllerTimeout,
+					}, activeActor)
 					processed = true
 				}
 			}
 		}
 
-		// Обновляем приоритет в очереди
+		i.endTurn(activeActor)
+	}
+}
+
+// beginTurn начинает ход живого уровня: выбирает актора, переводит время, убирает мертвых,
+// переключает запись при смене контроллера и рассылает состояние.
+// nil - ходить некому или ход ушел на смерть актора. isHuman - команду ждут от клиента.
+func (i *Instance) beginTurn() (*domain.Entity, bool) {
+	item := i.TurnManager.PeekNext()
+	if item == nil {
+		return nil, false
+	}
+
+	activeActor := item.Value
+	i.CurrentTick = activeActor.AI.NextActionTick
+
+	// Проверка смерти
+	if activeActor.Stats != nil && activeActor.Stats.IsDead {
+		i.TurnManager.RemoveEntity(activeActor.ID)
+		if activeActor.Type == domain.EntityTypePlayer {
+			i.restartRecording() // Смерть закрывает сегмент: он уйдет в реплей забега
+		}
+		i.Service.FinishRun(activeActor, domain.RunOutcomeDeath)
+		// Если был подписчик - обновляем ему экран
+		if i.Service.Hub.HasSubscriber(activeActor.ID) {
+			i.Service.publishUpdate(activeActor.ID, i)
+		}
+		return nil, false
+	}
+
+	i.activeID = activeActor.ID
+
+	// Рассылка состояния (тем, кто смотрит на этого актора).
+	// Проверяем подписку один раз: клиент мог подключиться между рассылкой и ходом.
+	isHuman := i.Service.Hub.HasSubscriber(activeActor.ID)
+
+	// Игрок подключился или отключился: в новом сегменте записи его ходы
+	// будут браться оттуда же, откуда их берет живая игра
+	if activeActor.Type == domain.EntityTypePlayer && isHuman != i.clientDriven(activeActor) {
+		i.restartRecording()
+		isHuman = i.clientDriven(activeActor)
+	}
+
+	if isHuman {
+		i.Service.publishUpdate(activeActor.ID, i)
+	}
+	return activeActor, isHuman
+}
+
+// endTurn заканчивает ход: время забега и место в очереди.
+// Ушедший на другой уровень актор уже принадлежит горутине того уровня.
+func (i *Instance) endTurn(activeActor *domain.Entity) {
+	if i.World.GetEntity(activeActor.ID) == activeActor {
+		i.trackRunTime(activeActor)
 		i.TurnManager.UpdatePriority(activeActor.ID, activeActor.AI.NextActionTick)
 	}
+	i.activeID = ""
 }
 
 // addEntity добавляет сущность в структуры уровня
@@ -181,6 +302,70 @@ func (i *Instance) addEntity(e *domain.Entity) {
 	}
 }
 
+// restoreEntity добавляет сущность из снапшота реплея.
+// В отличие от addEntity не синхронизирует время хода: оно уже записано в снапшоте.
+func (i *Instance) restoreEntity(e *domain.Entity) {
+	i.Entities = append(i.Entities, e)
+	i.World.RegisterEntity(e)
+	i.World.AddEntity(e)
+
+	if e.Stats != nil && !e.Stats.IsDead {
+		i.TurnManager.AddEntity(e)
+	}
+}
+
+// handleLeave обрабатывает сигнал выхода сущности из инстанса.
+// Если сущность все еще числится на этом уровне, значит игрок вышел из игры,
+// а не перешел на другой уровень - сохраняем его перед удалением.
+func (i *Instance) handleLeave(id string) {
+	e := i.World.GetEntity(id)
+	if e != nil && e.Level == i.ID {
+		i.Service.SaveCharacter(e)
+	}
+	isPlayer := e != nil && e.Type == domain.EntityTypePlayer
+	if isPlayer {
+		i.cutReplay(nil)
+	}
+	i.removeEntity(id)
+	if isPlayer {
+		i.beginRecording()
+	}
+}
+
+// trackRunTime добавляет время, потраченное на ход, в статистику забега.
+// Вызывается после хода, пока CurrentTick указывает на его начало.
+func (i *Instance) trackRunTime(actor *domain.Entity) {
+	if actor.Run == nil || actor.AI == nil {
+		return
+	}
+	if spent := actor.AI.NextActionTick - i.CurrentTick; spent > 0 {
+		actor.Run.Ticks += spent
+	}
+}
+
+// SaveCharacters сохраняет всех игроков уровня, которыми сейчас кто-то управляет.
+func (i *Instance) SaveCharacters() {
+	for _, e := range i.Entities {
+		if e.Type == domain.EntityTypePlayer && e.ControllerID != "" {
+			i.Service.SaveCharacter(e)
+		}
+	}
+}
+
+// Stop останавливает цикл запущенного инстанса и ждет, пока он сохранит игроков и запись.
+// Состояние уровня меняет только его горутина, поэтому сохранение идет в ней же.
+func (i *Instance) Stop() {
+	close(i.quit)
+	<-i.done
+}
+
+// shutdown - последнее, что делает цикл: закрывает сегмент записи и журнал, сохраняет игроков
+func (i *Instance) shutdown() {
+	i.cutReplay(nil)
+	i.SaveCharacters()
+	logger.Log.WithField("instance_id", i.ID).Info("Instance loop stopped")
+}
+
 // removeEntity удаляет сущность из уровня
 func (i *Instance) removeEntity(id string) {
 	// Удаляем из TurnManager
@@ -203,8 +388,8 @@ func (i *Instance) removeEntity(id string) {
 
 // executeCommand выполняет команду в контексте уровня
 func (i *Instance) executeCommand(cmd domain.InternalCommand, actor *domain.Entity) {
-	// Проверяем, управляется ли актор агентом
-	if actor.ControllerID != "" {
+	// В запись попадают только команды клиентов (и подставленные за них сервером)
+	if i.clientDriven(actor) {
 		i.recordAction(cmd, i.CurrentTick)
 	}
 
@@ -218,7 +403,6 @@ func (i *Instance) executeCommand(cmd domain.InternalCommand, actor *domain.Enti
 		World:    i.World,
 		Entities: i.Entities,
 		Actor:    actor,
-		Worlds:   i.Service.Worlds, // Для переходов (пока ссылаемся на глобальную мапу)
 
 		// Для спавна новых сущностей (стрелы, суммоны)
 		AddGlobalEntity: func(e *domain.Entity) {
@@ -242,12 +426,146 @@ func (i *Instance) executeCommand(cmd domain.InternalCommand, actor *domain.Enti
 }
 
 func (i *Instance) recordAction(cmd domain.InternalCommand, tick int) {
-	i.Replay.Actions = append(i.Replay.Actions, domain.ReplayAction{
-		Tick:    tick,
-		Token:   cmd.Token,
-		Action:  cmd.Action,
-		Payload: cmd.Payload,
+	act := domain.ReplayAction{
+		Tick:       tick,
+		Token:      cmd.Token,
+		Action:     cmd.Action,
+		Payload:    cmd.Payload,
+		Controller: cmd.Controller,
+	}
+
+	index := i.recorded
+	if index == 0 && !i.IsPlayback {
+		i.openReplaySegment()
+	}
+
+	// Хеш состояния перед каждым N-м действием (и везде, где он есть в проигрываемой записи)
+	interval := i.Replay.HashInterval
+	_, recorded := i.PlaybackChecksums[index]
+	if (interval > 0 && index%interval == 0) || recorded {
+		hash := domain.StateHash(i.CurrentTick, i.Entities)
+		c := domain.ReplayChecksum{Action: index, Hash: hash}
+		if i.IsPlayback {
+			i.Replay.Checksums = append(i.Replay.Checksums, c)
+		} else {
+			i.writeReplay(func(w *storage.RunWriter) error { return w.Checksum(c) })
+		}
+		i.verifyChecksum(index, cmd.Token, hash)
+	}
+
+	// Полный кадр для быстрой перемотки. Перед первым действием его заменяет снапшот сегмента.
+	if !i.IsPlayback && index > 0 && index%domain.DefaultReplayKeyframeInterval == 0 {
+		i.captureKeyframe(index)
+	}
+	if kf, ok := i.PlaybackKeyframes[index]; ok && i.VerifyMode {
+		i.verifyKeyframe(index, cmd.Token, kf)
+	}
+
+	// Живая запись уходит в файлы забегов по мере игры, в памяти копится только проигрываемая
+	if i.IsPlayback {
+		i.Replay.Actions = append(i.Replay.Actions, act)
+	} else {
+		i.journalAction(act)
+		i.writeReplay(func(w *storage.RunWriter) error { return w.Action(act) })
+	}
+	i.recorded++
+}
+
+// verifyChecksum сверяет хеш симуляции с записанным (только при воспроизведении)
+func (i *Instance) verifyChecksum(index int, actor string, actual uint64) {
+	if !i.IsPlayback {
+		return
+	}
+	expected, ok := i.PlaybackChecksums[index]
+	if !ok {
+		return
+	}
+	if expected == actual {
+		if i.VerifyMode {
+			i.lastGoodState, _ = domain.EncodeEntities(i.Entities)
+		}
+		return
+	}
+
+	i.addDesync(Desync{
+		Action:   index,
+		Tick:     i.CurrentTick,
+		Actor:    actor,
+		Reason:   DesyncStateHash,
+		Expected: expected,
+		Actual:   actual,
 	})
+	logger.Log.WithFields(logrus.Fields{
+		"instance": i.ID,
+		"action":   index,
+		"tick":     i.CurrentTick,
+	}).Errorf("Desync detected: state hash %016x, expected %016x", actual, expected)
+}
+
+// beginRecording начинает сегмент записи: при входе, выходе или смене контроллера игрока.
+// До него уровень мог жить сколько угодно (AI, ожидание игроков), поэтому в запись
+// попадает снапшот сущностей и положение RNG. Сам генератор не трогается: пересев
+// повторял бы броски живой игры при каждом переподключении.
+func (i *Instance) beginRecording() {
+	if i.IsPlayback {
+		return
+	}
+
+	// Ход игрока берется из записи, только если в живой игре его ждали от клиента
+	i.Replay.Controllers = make(map[string]domain.ControllerType)
+	for _, e := range i.Entities {
+		if e.Type != domain.EntityTypePlayer {
+			continue
+		}
+		controller := domain.ControllerAI
+		if i.Service.Hub.HasSubscriber(e.ID) {
+			controller = domain.ControllerClient
+		}
+		i.Replay.Controllers[e.ID] = controller
+	}
+
+	i.captureStartSnapshot()
+	i.Replay.RngDraws = i.rngSource.draws
+	i.Replay.ActiveID = i.activeID
+}
+
+// restartRecording закрывает текущий сегмент и сразу начинает новый
+func (i *Instance) restartRecording() {
+	i.cutReplay(nil)
+	i.beginRecording()
+}
+
+// clientDriven - берутся ли ходы сущности из записи (в живой игре - ждутся от клиента)
+func (i *Instance) clientDriven(e *domain.Entity) bool {
+	if i.Replay.Controllers == nil {
+		// Старые записи без карты контроллеров: из записи ходит игрок с ControllerID.
+		// В живой игре nil значит, что сегмент еще не начат и записывать нечего.
+		return i.IsPlayback && e.ControllerID != ""
+	}
+	c, ok := i.Replay.Controllers[e.ID]
+	return ok && c == domain.ControllerClient
+}
+
+// addDesync фиксирует расхождение. В VerifyMode к нему прикладывается состояние для диффа.
+func (i *Instance) addDesync(d Desync) {
+	if i.VerifyMode {
+		d.Before = i.lastGoodState
+		if d.Before == nil {
+			d.Before = i.Replay.Entities // Контрольных точек еще не было: сравниваем с началом записи
+		}
+		d.After, _ = domain.EncodeEntities(i.Entities)
+	}
+	i.Desyncs = append(i.Desyncs, d)
+}
+
+// captureStartSnapshot запоминает сущности уровня как начальное состояние записи.
+func (i *Instance) captureStartSnapshot() {
+	data, err := domain.EncodeEntities(i.Entities)
+	if err != nil {
+		logger.Log.WithField("instance", i.ID).Errorf("Failed to snapshot entities: %v", err)
+		return
+	}
+	i.Replay.Entities = data
 }
 
 // processAITurn копия логики ИИ, адаптированная под Instance
@@ -256,7 +574,7 @@ func (i *Instance) processAITurn(npc *domain.Entity) {
 		return
 	}
 	if !npc.AI.IsHostile {
-		npc.AI.Wait(domain.TimeCostWait)
+		i.processWander(npc)
 		return
 	}
 
@@ -269,7 +587,7 @@ func (i *Instance) processAITurn(npc *domain.Entity) {
 			continue
 		}
 
-		isInanimate := other.Type == domain.EntityTypeItem || other.Type == domain.EntityTypeExit
+		isInanimate := other.Type == domain.EntityTypeItem || other.Type == domain.EntityTypeExit || other.Type == domain.EntityTypeObject
 		isSameType := other.Type == npc.Type
 
 		if !isInanimate && !isSameType {
@@ -300,17 +618,19 @@ func (i *Instance) processAITurn(npc *domain.Entity) {
 	}
 }
 
-func (i *Instance) SaveReplay() {
-	if len(i.Replay.Actions) == 0 {
-		return // Не сохраняем пустые сессии
+// processWander - ход мирного NPC: бродящие делают случайный шаг, остальные ждут
+func (i *Instance) processWander(npc *domain.Entity) {
+	if npc.AI.State != domain.AIStateWander {
+		npc.AI.Wait(domain.TimeCostWait)
+		return
 	}
-
-	logger.Log.WithField("instance", i.ID).Info("Saving replay...")
-	if err := i.Service.Storage.Save(i.Replay); err != nil {
-		logger.Log.Error("Failed to save replay:", err)
-	} else {
-		logger.Log.Info("Replay saved successfully.")
+	dx, dy := systems.ComputeWanderStep(npc, i.World, i.Rng)
+	if dx == 0 && dy == 0 {
+		npc.AI.Wait(domain.TimeCostMove)
+		return
 	}
+	payload, _ := json.Marshal(api.DirectionPayload{Dx: dx, Dy: dy})
+	i.executeCommand(domain.InternalCommand{Action: domain.ActionMove, Token: npc.ID, Payload: payload}, npc)
 }
 
 // RunSimulation запускает инстанс в режиме воспроизведения реплея.
@@ -329,80 +649,101 @@ func (i *Instance) RunSimulation() {
 
 	startTime := time.Now()
 	steps := 0
+	for i.playbackStep() {
+		steps++
+	}
 
-	for {
-		// Если команд больше нет, выходим СРАЗУ.
-		// Мы не хотим ждать, пока все гоблины походят еще 100 раз.
-		if i.PlaybackCursor >= len(i.PlaybackActions) {
-			logger.Log.Info("✅ Replay finished (all actions executed).")
-			break
-		}
+	duration := time.Since(startTime)
+	logger.Log.Infof("🏁 Simulation finished in %v. Steps: %d. Final Tick: %d. Desyncs: %d",
+		duration, steps, i.CurrentTick, len(i.Desyncs))
+}
 
-		// 1. Кто ходит?
-		item := i.TurnManager.PeekNext()
-		if item == nil {
-			break // Все умерли или пусто
-		}
+// playbackStep выполняет один ход воспроизведения.
+// false - записанные действия кончились или продолжать симуляцию бессмысленно.
+func (i *Instance) playbackStep() bool {
+	// Если команд больше нет, выходим СРАЗУ.
+	// Мы не хотим ждать, пока все гоблины походят еще 100 раз.
+	if i.PlaybackCursor >= len(i.PlaybackActions) {
+		logger.Log.Info("✅ Replay finished (all actions executed).")
+		return false
+	}
 
-		activeActor := item.Value
-		i.CurrentTick = activeActor.AI.NextActionTick
+	// 1. Кто ходит?
+	item := i.TurnManager.PeekNext()
+	if item == nil {
+		return false // Все умерли или пусто
+	}
 
-		// 2. Проверка смерти
-		if activeActor.Stats != nil && activeActor.Stats.IsDead {
-			i.TurnManager.RemoveEntity(activeActor.ID)
-			continue
+	activeActor := item.Value
+	if i.resumeActor != "" {
+		// В живой игре его команды уже ждали, когда в очередь встал вошедший с тем же временем
+		if e := i.World.GetEntity(i.resumeActor); e != nil && e.AI != nil && e.AI.NextActionTick == item.Priority {
+			activeActor = e
 		}
+		i.resumeActor = ""
+	}
+	i.CurrentTick = activeActor.AI.NextActionTick
 
-		// 3. Логика хода
-		// В симуляции мы смотрим на ControllerID. Если он есть — это был агент.
-		isPlayer := activeActor.ControllerID != ""
-
-		if !isPlayer {
-			// --- ХОД AI ---
-			// Используем ту же логику, что и в основной игре
-			i.processAITurn(activeActor)
-		} else {
-			// --- ХОД ИГРОКА (из записи) ---
-
-			// Ищем следующее действие в реплее
-			if i.PlaybackCursor >= len(i.PlaybackActions) {
-				logger.Log.Info("End of replay tape reached.")
-				break
-			}
+	// 2. Проверка смерти
+	if activeActor.Stats != nil && activeActor.Stats.IsDead {
+		i.TurnManager.RemoveEntity(activeActor.ID)
+		return true
+	}
 
-			action := i.PlaybackActions[i.PlaybackCursor]
+	// 3. Логика хода: из записи ходят те, чьи ходы в живой игре ждались от клиента
+	if !i.clientDriven(activeActor) {
+		// --- ХОД AI ---
+		// Используем ту же логику, что и в основной игре
+		i.processAITurn(activeActor)
+	} else {
+		// --- ХОД ИГРОКА (из записи) ---
+		action := i.PlaybackActions[i.PlaybackCursor]
+		cmd := domain.InternalCommand{
+			Action:     action.Action,
+			Token:      action.Token,
+			Payload:    action.Payload,
+			Controller: action.Controller,
+		}
 
-			// Валидация синхронизации
-			// В идеальном детерминированном мире тики должны совпадать.
-			// Но для начала просто проверим порядок: следующее действие должно быть от этого актора.
-			if action.Token != activeActor.ID {
-				logger.Log.Warnf("Desync detected at action %d! Expected actor %s, got action from %s",
-					i.PlaybackCursor, activeActor.ID, action.Token)
-				// В жестком режиме тут можно делать panic или return
+		// INIT не тратит ход: в живой игре он выполняется сразу, чей бы ход ни шел
+		if action.Action == domain.ActionInit {
+			if source := i.World.GetEntity(action.Token); source != nil {
+				i.executeCommand(cmd, source)
 			}
+			i.PlaybackCursor++
+			return true
+		}
 
-			// Выполняем команду
-			cmd := domain.InternalCommand{
-				Action:  action.Action,
-				Token:   action.Token,
-				Payload: action.Payload,
-			}
+		// Действие принадлежит конкретному актору. Если сейчас ходит другой,
+		// порядок ходов разошелся с записью и применять действие не к кому.
+		if action.Token != activeActor.ID {
+			logger.Log.Warnf("Desync detected at action %d! Expected actor %s, got action from %s",
+				i.PlaybackCursor, activeActor.ID, action.Token)
+			i.addDesync(Desync{
+				Action: i.PlaybackCursor,
+				Tick:   i.CurrentTick,
+				Actor:  action.Token,
+				Reason: DesyncActorOrder,
+			})
+			return false
+		}
 
-			logger.Log.Debugf("[Replay] Act %d/%d: %s (%s)",
-				i.PlaybackCursor+1, len(i.PlaybackActions), action.Action, action.Token)
+		logger.Log.Debugf("[Replay] Act %d/%d: %s (%s, %s)",
+			i.PlaybackCursor+1, len(i.PlaybackActions), action.Action, action.Token, action.Controller)
 
-			// Выполняем (важно: executeCommand сама запишет это в новый replay,
-			// если мы не отключим запись, но для симуляции это не страшно)
-			i.executeCommand(cmd, activeActor)
+		// executeCommand заново запишет действие: по этой записи сверяются хеши
+		i.executeCommand(cmd, activeActor)
 
-			i.PlaybackCursor++
+		i.PlaybackCursor++
+		if i.VerifyMode && len(i.Desyncs) > 0 {
+			return false // Дальше сравнивать бессмысленно: состояние уже разошлось
 		}
+	}
 
-		// Обновляем приоритет
+	// Как и в живой игре, время ушедшего на другой уровень здесь не считается
+	if i.World.GetEntity(activeActor.ID) == activeActor {
+		i.trackRunTime(activeActor)
 		i.TurnManager.UpdatePriority(activeActor.ID, activeActor.AI.NextActionTick)
-		steps++
 	}
-
-	duration := time.Since(startTime)
-	logger.Log.Infof("🏁 Simulation finished in %v. Steps: %d. Final Tick: %d", duration, steps, i.CurrentTick)
+	return true
 }
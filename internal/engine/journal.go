@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/infrastructure/storage"
+	"cognitive-server/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newJournalStore выбирает хранилище журналов по конфигу (пустая папка - журнал выключен)
+func newJournalStore(dir string) *storage.JournalStore {
+	if dir == "" {
+		return nil
+	}
+	return storage.NewJournalStore(dir)
+}
+
+// journalAction дописывает действие в журнал инстанса.
+// Журнал открывается лениво: уровни, где никто не действовал, не оставляют файлов.
+func (i *Instance) journalAction(act domain.ReplayAction) {
+	store := i.Service.Journals
+	if store == nil {
+		return
+	}
+
+	if i.Journal == nil {
+		journal, err := store.Rotate(i.Replay)
+		if err != nil {
+			logger.Log.WithField("instance", i.ID).Errorf("Failed to open journal: %v", err)
+			return // Попробуем снова на следующем действии
+		}
+		i.Journal = journal
+	}
+
+	if err := i.Journal.Append(act); err != nil {
+		logger.Log.WithField("instance", i.ID).Errorf("Failed to append to journal: %v", err)
+	}
+}
+
+// CloseJournal сбрасывает журнал на диск и закрывает его.
+func (i *Instance) CloseJournal() {
+	if i.Journal == nil {
+		return
+	}
+	if err := i.Journal.Close(); err != nil {
+		logger.Log.WithField("instance", i.ID).Errorf("Failed to close journal: %v", err)
+	}
+	i.Journal = nil
+}
+
+// recoveredMasterSeed возвращает мастер-сид мира, записанного в журнале.
+// Его нужно узнать до генерации стартового мира, иначе уровни не совпадут с записью.
+func recoveredMasterSeed(store *storage.JournalStore) (int64, bool) {
+	if store == nil {
+		return 0, false
+	}
+
+	levels, err := store.Levels()
+	if err != nil || len(levels) == 0 {
+		return 0, false
+	}
+
+	session, _, err := store.Load(levels[0])
+	if err != nil {
+		logger.Log.Errorf("Failed to read journal snapshot: %v", err)
+		return 0, false
+	}
+	return masterSeedOf(session.Seed, session.LevelID), true
+}
+
+// recoverInstances пересобирает уровни из снапшота и хвоста журнала.
+// Вызывается из NewService до запуска игровых циклов. После симуляции
+// журнал ротируется: снапшот включает все восстановленные действия.
+func (s *GameService) recoverInstances() {
+	if s.Journals == nil {
+		return
+	}
+
+	levels, err := s.Journals.Levels()
+	if err != nil {
+		logger.Log.Errorf("Failed to list journals: %v", err)
+		return
+	}
+
+	for _, levelID := range levels {
+		recoveryLogger := logger.Log.WithFields(logrus.Fields{
+			"component": "journal_recovery",
+			"instance":  levelID,
+		})
+
+		session, tail, err := s.Journals.Load(levelID)
+		if err != nil {
+			recoveryLogger.Errorf("Skipping level: %v", err)
+			continue
+		}
+		if masterSeedOf(session.Seed, session.LevelID) != s.Levels.MasterSeed() {
+			recoveryLogger.Warn("Skipping level recorded with a different master seed")
+			continue
+		}
+
+		instance, err := s.buildReplayInstance(session)
+		if err != nil {
+			recoveryLogger.Errorf("Skipping level: %v", err)
+			continue
+		}
+
+		// Регистрируем до симуляции: переходы между уровнями во время
+		// доигрывания должны попадать в восстановленный инстанс
+		s.Levels.Register(instance)
+
+		instance.RunSimulation()
+
+		// Возвращаем инстанс в живой режим. Игроки остаются в мире без контроллера,
+		// пока клиент снова не подключится.
+		instance.IsPlayback = false
+		instance.PlaybackActions = nil
+		instance.PlaybackCursor = 0
+		for _, e := range instance.Entities {
+			e.ControllerID = ""
+			s.Levels.SetLocation(e.ID, levelID)
+		}
+
+		// Снапшот с восстановленным состоянием нужен на случай повторного падения.
+		// Запись же начинается заново (с новым снапшотом и журналом при первом действии):
+		// часть забега до падения в реплеи не попадает.
+		journal, err := s.Journals.Rotate(instance.Replay)
+		if err != nil {
+			recoveryLogger.Errorf("Failed to rotate journal: %v", err)
+		} else if err := journal.Close(); err != nil {
+			recoveryLogger.Errorf("Failed to close journal: %v", err)
+		}
+		instance.resetReplay()
+
+		recoveryLogger.WithFields(logrus.Fields{
+			"actions":      len(session.Actions),
+			"journal_tail": tail,
+			"tick":         instance.CurrentTick,
+		}).Info("Level recovered from journal")
+	}
+}
@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/infrastructure/storage"
+	"testing"
+)
+
+// Сервис падает посреди игры (без Shutdown), новый сервис над той же папкой журналов
+// восстанавливает уровень в то же состояние. Чужие и несовместимые журналы пропускаются.
+func TestRecoverInstances_AfterCrash(t *testing.T) {
+	dir := t.TempDir()
+
+	// Мир с мастер-сидом 3: игрок ходит по поверхности, действия уходят в журнал
+	r := newGoldenRecorder(t, 3)
+	r.service.Journals = storage.NewJournalStore(dir)
+	r.login("hero_1")
+	r.queue("hero_1", walkCmds(5, 60)...)
+	r.play()
+
+	live, _ := r.service.Levels.Get(0)
+	if live.Journal == nil {
+		t.Fatal("no journal was opened")
+	}
+	// Падение после очередного fsync: несброшенный хвост пачки не теряется
+	if err := live.Journal.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	wantTick := live.CurrentTick
+	wantHash := domain.StateHash(live.CurrentTick, live.Entities)
+
+	// Журнал уровня 2 из мира с другим мастер-сидом
+	foreign := newReplaySession(2, levelSeed(7, 2))
+	foreign.Recipe = levelRecipe(2)
+	writeJournal(t, dir, foreign)
+
+	// Журнал уровня 3 нашего мира, но с рецептом, которого генератор уже не строит
+	stale := newReplaySession(3, levelSeed(3, 3))
+	stale.Recipe = "old_recipe/v1"
+	writeJournal(t, dir, stale)
+
+	s := recoverService(Config{Seed: 99, JournalDir: dir})
+	if got := s.Levels.MasterSeed(); got != 3 {
+		t.Fatalf("master seed = %d, want the journal's 3", got)
+	}
+
+	recovered, ok := s.Levels.Get(0)
+	if !ok {
+		t.Fatal("level 0 not recovered")
+	}
+	if recovered.CurrentTick != wantTick {
+		t.Errorf("tick = %d, want %d", recovered.CurrentTick, wantTick)
+	}
+	if got := domain.StateHash(recovered.CurrentTick, recovered.Entities); got != wantHash {
+		t.Errorf("state hash = %016x, want %016x", got, wantHash)
+	}
+	if hero := s.GetEntity("hero_1"); hero == nil || hero.ControllerID != "" {
+		t.Errorf("recovered hero = %+v, want it in the world without a controller", hero)
+	}
+
+	for _, levelID := range []int{2, 3} {
+		if _, ok := s.Levels.Get(levelID); ok {
+			t.Errorf("level %d recovered from an incompatible journal", levelID)
+		}
+	}
+}
+
+func writeJournal(t *testing.T, dir string, session *domain.ReplaySession) {
+	t.Helper()
+	journal, err := storage.NewJournalStore(dir).Rotate(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
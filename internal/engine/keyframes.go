@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/infrastructure/storage"
+	"cognitive-server/pkg/logger"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Кадр (keyframe) - полное состояние уровня посреди сегмента: сущности, очередь ходов, время и RNG.
+// Перемотка начинает с ближайшего кадра и доигрывает только остаток, а не весь сегмент.
+
+// captureKeyframe снимает кадр перед действием с индексом index
+func (i *Instance) captureKeyframe(index int) {
+	data, err := domain.EncodeEntities(i.Entities)
+	if err != nil {
+		logger.Log.WithField("instance", i.ID).Errorf("Failed to capture keyframe: %v", err)
+		return
+	}
+	kf := &domain.ReplayKeyframe{
+		Action:   index,
+		Tick:     i.CurrentTick,
+		RngDraws: i.rngSource.draws,
+		Queue:    i.TurnManager.Snapshot(),
+		Entities: data,
+	}
+	i.writeReplay(func(w *storage.RunWriter) error { return w.Keyframe(kf) })
+}
+
+// verifyKeyframe сверяет кадр записи с состоянием, до которого дошла симуляция.
+// Расхождение значит, что перемотка на этот кадр покажет не то, что было в игре.
+func (i *Instance) verifyKeyframe(index int, actor string, kf *domain.ReplayKeyframe) {
+	actual := domain.StateHash(i.CurrentTick, i.Entities)
+	var expected uint64
+	if entities, err := domain.DecodeEntities(kf.Entities); err == nil {
+		expected = domain.StateHash(kf.Tick, entities)
+	}
+	if expected == actual && kf.RngDraws == i.rngSource.draws && slices.Equal(kf.Queue, i.TurnManager.Snapshot()) {
+		return
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"instance": i.ID,
+		"action":   index,
+	}).Error("Desync detected: keyframe does not match simulation")
+	i.addDesync(Desync{
+		Action:   index,
+		Tick:     i.CurrentTick,
+		Actor:    actor,
+		Reason:   DesyncKeyframe,
+		Expected: expected,
+		Actual:   actual,
+	})
+}
+
+// restoreKeyframe заменяет состояние проигрываемого инстанса кадром записи.
+// Дальше воспроизведение идет с действия kf.Action, как если бы сегмент доиграли до него.
+func (i *Instance) restoreKeyframe(kf *domain.ReplayKeyframe) error {
+	entities, err := domain.DecodeEntities(kf.Entities)
+	if err != nil {
+		return err
+	}
+
+	for len(i.Entities) > 0 {
+		i.removeEntity(i.Entities[0].ID)
+	}
+	i.TurnManager = NewTurnManager()
+	for _, e := range entities {
+		i.Entities = append(i.Entities, e)
+		i.World.RegisterEntity(e)
+		i.World.AddEntity(e)
+	}
+	// Очередь берем из кадра: время хода в ней не всегда равно NextActionTick
+	for _, q := range kf.Queue {
+		if e := i.World.GetEntity(q.EntityID); e != nil && e.AI != nil {
+			i.TurnManager.AddEntity(e)
+			i.TurnManager.UpdatePriority(e.ID, q.Priority)
+		}
+	}
+
+	i.CurrentTick = kf.Tick
+	i.rngSource.restore(i.Seed, kf.RngDraws)
+
+	// Заново записанные действия нумеруют контрольные точки, поэтому пропущенные кладем как есть
+	i.PlaybackCursor = kf.Action
+	i.Replay.Actions = append(i.Replay.Actions[:0], i.PlaybackActions[:kf.Action]...)
+	i.recorded = kf.Action
+	i.lastGoodState = kf.Entities
+	return nil
+}
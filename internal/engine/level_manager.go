@@ -0,0 +1,208 @@
+package engine
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/pkg/dungeon"
+	"cognitive-server/pkg/logger"
+	"cognitive-server/pkg/utils"
+	"encoding/json"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// LevelManager - единственное место, где уровни появляются и ищутся: деривация сида уровня
+// из мастер-сида, генерация карты, регистрация инстанса и поиск по ID.
+// Стартовые, ленивые, восстановленные из журнала и реплейные уровни строятся одинаково,
+// поэтому при том же мастер-сиде карта уровня всегда одна и та же.
+type LevelManager struct {
+	service    *GameService
+	masterSeed int64
+
+	mu        sync.RWMutex
+	createMu  sync.Mutex // Держится в GetOrCreate от поиска до регистрации: уровень создается один раз
+	instances map[int]*Instance
+	locations map[string]int // Индекс: где находится сущность? (EntityID -> LevelID)
+}
+
+func NewLevelManager(service *GameService, masterSeed int64) *LevelManager {
+	return &LevelManager{
+		service:    service,
+		masterSeed: masterSeed,
+		instances:  make(map[int]*Instance),
+		locations:  make(map[string]int),
+	}
+}
+
+// levelSeed - детерминированная деривация сида уровня: Master + LevelID.
+// Можно использовать хеширование для лучшего разброса, но сложение для старта ок.
+func levelSeed(masterSeed int64, levelID int) int64 {
+	return masterSeed + int64(levelID)
+}
+
+// masterSeedOf - обратная деривация: мастер-сид по сиду уровня из записи
+func masterSeedOf(seed int64, levelID int) int64 {
+	return seed - int64(levelID)
+}
+
+// levelRecipe - имя рецепта, которым Generate строит уровень.
+// Пишется в заголовок реплея, чтобы запись не проигрывалась на чужом генераторе.
+func levelRecipe(levelID int) string {
+	if levelID == 0 {
+		return dungeon.SurfaceRecipeName()
+	}
+	return dungeon.RecipeName(levelID)
+}
+
+// MasterSeed - мастер-сид мира
+func (m *LevelManager) MasterSeed() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.masterSeed
+}
+
+// SetMasterSeed меняет мастер-сид (реплей приносит свой). Уже созданные уровни не трогаются.
+func (m *LevelManager) SetMasterSeed(seed int64) {
+	m.mu.Lock()
+	m.masterSeed = seed
+	m.mu.Unlock()
+}
+
+// Seed - сид уровня levelID
+func (m *LevelManager) Seed(levelID int) int64 {
+	return levelSeed(m.MasterSeed(), levelID)
+}
+
+// Recipe - имя рецепта уровня levelID (см. levelRecipe)
+func (m *LevelManager) Recipe(levelID int) string {
+	return levelRecipe(levelID)
+}
+
+// Generate строит карту и сущности уровня по его сиду. Инстанс не создается,
+// поэтому утилитам (mapgen) хватает менеджера без сервиса: NewLevelManager(nil, seed).
+func (m *LevelManager) Generate(levelID int) (*domain.GameWorld, []domain.Entity, domain.Position) {
+	// Создаем изолированный RNG для генерации этого уровня
+	rng := rand.New(rand.NewSource(m.Seed(levelID)))
+	if levelID == 0 {
+		return dungeon.GenerateSurface(rng)
+	}
+	return dungeon.Generate(levelID, rng)
+}
+
+// create генерирует уровень и собирает его инстанс. Сущности arrivals (игрок стартового мира)
+// ставятся на старт уровня раньше сгенерированных. Инстанс не регистрируется и не запускается.
+func (m *LevelManager) create(levelID int, arrivals ...*domain.Entity) *Instance {
+	world, entities, start := m.Generate(levelID)
+	instance := NewInstance(levelID, world, m.service, m.Seed(levelID))
+	for _, e := range arrivals {
+		e.Pos = start
+		e.Level = levelID
+		instance.addEntity(e)
+		m.SetLocation(e.ID, levelID)
+	}
+	for i := range entities {
+		instance.addEntity(&entities[i])
+		m.SetLocation(entities[i].ID, levelID)
+	}
+	return instance
+}
+
+// Register делает инстанс уровнем levelID (заменяя прежний, если был)
+func (m *LevelManager) Register(instance *Instance) {
+	m.mu.Lock()
+	m.instances[instance.ID] = instance
+	m.mu.Unlock()
+}
+
+// GetOrCreate возвращает инстанс уровня, при необходимости генерируя его на лету.
+// Второе значение равно true, если уровень был создан этим вызовом.
+// playerState - снапшот пришедшего игрока для заголовка первой записи нового уровня.
+// Новый уровень запускается, если сервис живой и не восстанавливается из журнала.
+// Вызывается и из диспетчера, и из горутин инстансов, поэтому создание сериализовано:
+// два вызова не запустят два инстанса одного уровня.
+func (m *LevelManager) GetOrCreate(levelID int, playerState json.RawMessage) (*Instance, bool) {
+	m.createMu.Lock()
+	defer m.createMu.Unlock()
+
+	if instance, ok := m.Get(levelID); ok {
+		return instance, false
+	}
+
+	logger.Log.Infof("Generating new level %d on the fly...", levelID)
+	instance := m.create(levelID)
+	instance.Replay.PlayerState = playerState
+	m.Register(instance)
+
+	if !m.service.recovering && !m.service.offline {
+		go instance.Run()
+	}
+	return instance, true
+}
+
+// Get - инстанс уровня, если он уже создан
+func (m *LevelManager) Get(levelID int) (*Instance, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	instance, ok := m.instances[levelID]
+	return instance, ok
+}
+
+// World - карта уровня или nil, если уровня еще нет
+func (m *LevelManager) World(levelID int) *domain.GameWorld {
+	if instance, ok := m.Get(levelID); ok {
+		return instance.World
+	}
+	return nil
+}
+
+// SetLocation запоминает, на каком уровне сущность
+func (m *LevelManager) SetLocation(entityID string, levelID int) {
+	m.mu.Lock()
+	m.locations[entityID] = levelID
+	m.mu.Unlock()
+}
+
+// Location - уровень, на котором числится сущность
+func (m *LevelManager) Location(entityID string) (int, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	levelID, ok := m.locations[entityID]
+	return levelID, ok
+}
+
+// Entity ищет сущность на ее уровне
+func (m *LevelManager) Entity(entityID string) *domain.Entity {
+	levelID, ok := m.Location(entityID)
+	if !ok {
+		return nil
+	}
+	if world := m.World(levelID); world != nil {
+		return world.GetEntity(entityID)
+	}
+	return nil
+}
+
+// All - все созданные уровни по возрастанию глубины
+func (m *LevelManager) All() []*Instance {
+	m.mu.RLock()
+	all := make([]*Instance, 0, len(m.instances))
+	for _, instance := range m.instances {
+		all = append(all, instance)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(all, func(a, b int) bool { return all[a].ID < all[b].ID })
+	return all
+}
+
+// createInitial создает и регистрирует стартовые уровни (поверхность и первый уровень подземелья)
+// с игроком hero_1 на старте поверхности. Инстансы не запускаются.
+func (m *LevelManager) createInitial() *domain.Entity {
+	playerSeed := utils.StringToSeed("hero_1")
+	rngPlayer := rand.New(rand.NewSource(playerSeed))
+	player := dungeon.CreatePlayer("hero_1", rngPlayer)
+
+	m.Register(m.create(0, player))
+	m.Register(m.create(1))
+	return player
+}
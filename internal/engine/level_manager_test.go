@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"bytes"
+	"cognitive-server/pkg/logger"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestService(masterSeed int64) *GameService {
+	logger.Init()
+	logger.Log.SetLevel(logrus.FatalLevel)
+
+	s := NewPlaybackService()
+	s.Levels.SetMasterSeed(masterSeed)
+	return s
+}
+
+// Стартовый, ленивый и реплейный уровень с тем же мастер-сидом - одна и та же карта
+func TestLevelManager_SameLevelEverywhere(t *testing.T) {
+	live := newTestService(42)
+	live.Levels.createInitial()
+	if _, created := live.Levels.GetOrCreate(2, nil); !created {
+		t.Fatal("level 2 already existed")
+	}
+
+	for _, levelID := range []int{0, 1, 2} {
+		replay := newTestService(42)
+		instance, err := replay.buildReplayInstance(newReplaySession(levelID, replay.Levels.Seed(levelID)))
+		if err != nil {
+			t.Fatalf("level %d: %v", levelID, err)
+		}
+
+		want, _ := json.Marshal(live.Levels.World(levelID))
+		got, _ := json.Marshal(instance.World)
+		if !bytes.Equal(got, want) {
+			t.Errorf("level %d: replay map differs from the live one", levelID)
+		}
+	}
+
+	// Запись из мира с другим мастер-сидом не проигрывается
+	other := newTestService(7)
+	if _, err := other.buildReplayInstance(newReplaySession(1, live.Levels.Seed(1))); err == nil {
+		t.Error("replay with a foreign seed was accepted")
+	}
+}
+
+// Игрок, спустившийся на сгенерированный на лету уровень, находится через GetEntity
+func TestGetEntity_OnLazyLevel(t *testing.T) {
+	s := newTestService(42)
+	player := s.Levels.createInitial()
+	if s.GetEntity(player.ID) != player {
+		t.Fatal("player not found on the surface")
+	}
+
+	for depth := 1; depth <= 3; depth++ {
+		s.ChangeLevel(player, depth, fmt.Sprintf("exit_up_from_%d", depth))
+		if got := s.GetEntity(player.ID); got != player {
+			t.Fatalf("depth %d: GetEntity = %v", depth, got)
+		}
+		if _, ok := s.Levels.Get(depth); !ok {
+			t.Fatalf("depth %d: level not registered", depth)
+		}
+	}
+}
+
+// Новый сегмент записи не пересевает живой RNG: переподключение не повторяет броски.
+// Воспроизведение проматывает генератор до места, где начался сегмент.
+func TestBeginRecording_KeepsLiveRng(t *testing.T) {
+	s := newTestService(42)
+	s.Levels.createInitial()
+	live, _ := s.Levels.Get(1)
+
+	ref := rand.New(rand.NewSource(live.Seed))
+	for range 5 {
+		live.Rng.Int63()
+		ref.Int63()
+	}
+	live.restartRecording()
+	if live.Replay.RngDraws != live.rngSource.draws || live.rngSource.draws == 0 {
+		t.Fatalf("segment starts at draw %d, generator is at %d", live.Replay.RngDraws, live.rngSource.draws)
+	}
+	next := live.Rng.Int63()
+	if next != ref.Int63() {
+		t.Fatal("live generator was reseeded")
+	}
+
+	replay := newTestService(42)
+	instance, err := replay.buildReplayInstance(live.Replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := instance.Rng.Int63(); got != next {
+		t.Errorf("playback draws %d, live drew %d", got, next)
+	}
+}
@@ -0,0 +1,268 @@
+package engine
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/pkg/api"
+	"encoding/json"
+	"errors"
+)
+
+// ReplayCursor проигрывает реплей забега по одному записанному действию.
+// Нужен для показа реплея зрителям: между шагами можно строить кадры, ставить паузу и перематывать.
+// Не потокобезопасен: им владеет одна горутина.
+type ReplayCursor struct {
+	run     *domain.RunReplay
+	service *GameService // Офлайн-сервис текущего прохода, пересоздается при перемотке назад
+
+	instance *Instance // Инстанс текущего сегмента
+	segment  int
+	base     int // Записанных действий в сегментах до текущего
+	total    int
+	ended    bool
+
+	Result PlaybackResult // Расхождения, найденные по ходу проигрывания
+}
+
+// ReplayPosition - где сейчас находится курсор
+type ReplayPosition struct {
+	Step     int // Выполнено записанных действий с начала реплея
+	Total    int
+	Segment  int
+	Segments int
+	Level    int
+	Tick     int
+	Ended    bool
+}
+
+// OpenReplay загружает реплей и ставит курсор в его начало.
+func OpenReplay(path string) (*ReplayCursor, error) {
+	service := NewPlaybackService()
+	run, err := service.Storage.LoadRun(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(run.Segments) == 0 {
+		return nil, errors.New("replay has no segments")
+	}
+
+	c := &ReplayCursor{run: run}
+	for _, segment := range run.Segments {
+		c.total += len(segment.Actions)
+	}
+	if err := c.rewind(service, 0); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// rewind начинает проигрывание заново на чистом сервисе с начала сегмента segment.
+// Расхождения, найденные до перемотки, сбрасываются.
+func (c *ReplayCursor) rewind(service *GameService, segment int) error {
+	inst, err := service.startPlayback(c.run, segment)
+	if err != nil {
+		return err
+	}
+	c.service = service
+	c.enter(inst)
+	c.segment, c.base = segment, c.segmentBase(segment)
+	c.ended = false
+	c.Result = PlaybackResult{}
+	return nil
+}
+
+// segmentBase - сколько записанных действий в сегментах до segment
+func (c *ReplayCursor) segmentBase(segment int) int {
+	base := 0
+	for _, s := range c.run.Segments[:segment] {
+		base += len(s.Actions)
+	}
+	return base
+}
+
+// Next проигрывает ходы до следующего записанного действия включительно.
+// false - реплей закончился.
+func (c *ReplayCursor) Next() bool {
+	for !c.ended {
+		inst := c.instance
+		if inst.PlaybackCursor < len(inst.PlaybackActions) {
+			before := inst.PlaybackCursor
+			more := inst.playbackStep()
+			if inst.PlaybackCursor > before {
+				return true
+			}
+			if more {
+				continue
+			}
+		}
+
+		// Сегмент доигран (или разошелся с записью и дальше не идет): переходим к следующему
+		c.finishSegment()
+		if c.segment+1 >= len(c.run.Segments) {
+			c.ended = true
+			break
+		}
+		next, err := c.service.enterSegment(c.segment+1, &c.Result)
+		if err != nil {
+			c.ended = true
+			break
+		}
+		c.base += len(c.run.Segments[c.segment].Actions)
+		c.segment++
+		c.enter(next)
+	}
+	return false
+}
+
+// enter делает инстанс сегмента текущим. Время уровня до первого хода берется из очереди,
+// чтобы позиция показывала время снапшота, а не ноль.
+func (c *ReplayCursor) enter(inst *Instance) {
+	c.instance = inst
+	if item := inst.TurnManager.PeekNext(); item != nil {
+		inst.CurrentTick = item.Priority
+	}
+}
+
+// finishSegment переносит итоги доигранного сегмента в Result
+func (c *ReplayCursor) finishSegment() {
+	segment := c.run.Segments[c.segment]
+	for _, d := range c.instance.Desyncs {
+		d.Segment, d.Level = c.segment, segment.LevelID
+		c.Result.Desyncs = append(c.Result.Desyncs, d)
+	}
+	c.instance.Desyncs = nil
+	if segment.Exit != nil {
+		c.service.checkExit(c.instance, c.segment, segment, &c.Result)
+	}
+}
+
+// Seek перематывает к состоянию после step записанных действий.
+// Если ближе к цели есть кадр записи (или начало ее сегмента), проигрывание начинается с него,
+// иначе курсор просто идет вперед.
+func (c *ReplayCursor) Seek(step int) error {
+	step = max(0, min(step, c.total))
+	segment, kf := c.keyframeBefore(step)
+	start := c.segmentBase(segment)
+	if kf != nil {
+		start += kf.Action
+	}
+
+	if pos := c.Position().Step; pos > step || pos < start {
+		if err := c.rewind(NewPlaybackService(), segment); err != nil {
+			return err
+		}
+		if kf != nil {
+			if err := c.instance.restoreKeyframe(kf); err != nil {
+				return err
+			}
+		}
+	}
+	for c.Position().Step < step && c.Next() {
+	}
+	// Кадр после перемотки не должен тащить логи всех пропущенных ходов
+	c.instance.Logs = []api.LogEntry{}
+	return nil
+}
+
+// SeekTick перематывает к первому записанному действию, сделанному не раньше tick.
+// Время у каждого уровня свое, поэтому ищется первое совпадение по порядку сегментов.
+func (c *ReplayCursor) SeekTick(tick int) error {
+	step := 0
+	for _, segment := range c.run.Segments {
+		for _, act := range segment.Actions {
+			if act.Tick >= tick {
+				return c.Seek(step)
+			}
+			step++
+		}
+	}
+	return c.Seek(step)
+}
+
+// keyframeBefore находит сегмент, в котором лежит step, и последний кадр записи в нем
+// раньше step (nil - проигрывать с начала сегмента). Кадр с индексом step не подходит:
+// он снят перед действием, уже после ходов AI, а курсор после step действий их еще не сделал.
+func (c *ReplayCursor) keyframeBefore(step int) (int, *domain.ReplayKeyframe) {
+	segment, base := len(c.run.Segments)-1, 0
+	for k, s := range c.run.Segments {
+		// Шаг на границе - конец сегмента, как и при проигрывании подряд
+		if step <= base+len(s.Actions) {
+			segment = k
+			break
+		}
+		base += len(s.Actions)
+	}
+	local := step - c.segmentBase(segment)
+
+	var found *domain.ReplayKeyframe
+	keyframes := c.run.Segments[segment].Keyframes
+	for k := range keyframes {
+		if keyframes[k].Action >= local {
+			break
+		}
+		found = &keyframes[k]
+	}
+	return segment, found
+}
+
+// Position возвращает текущую позицию курсора
+func (c *ReplayCursor) Position() ReplayPosition {
+	return ReplayPosition{
+		Step:     c.base + c.instance.PlaybackCursor,
+		Total:    c.total,
+		Segment:  c.segment,
+		Segments: len(c.run.Segments),
+		Level:    c.instance.ID,
+		Tick:     c.instance.CurrentTick,
+		Ended:    c.ended,
+	}
+}
+
+// Action возвращает записанное действие номер step (с нуля, сквозь все сегменты)
+func (c *ReplayCursor) Action(step int) (domain.ReplayAction, bool) {
+	for _, segment := range c.run.Segments {
+		if step < len(segment.Actions) {
+			return segment.Actions[step], step >= 0
+		}
+		step -= len(segment.Actions)
+	}
+	return domain.ReplayAction{}, false
+}
+
+// StateHash - хеш текущего состояния уровня, тот же, что пишется в контрольные точки
+func (c *ReplayCursor) StateHash() uint64 {
+	return domain.StateHash(c.instance.CurrentTick, c.instance.Entities)
+}
+
+// Entities - снапшот сущностей текущего уровня для построчного сравнения
+func (c *ReplayCursor) Entities() (json.RawMessage, error) {
+	return domain.EncodeEntities(c.instance.Entities)
+}
+
+// PlayerID - чей это забег
+func (c *ReplayCursor) PlayerID() string {
+	return c.run.PlayerID
+}
+
+// Frame строит кадр текущего состояния глазами сущности viewerID.
+// Если ее нет на текущем уровне (или viewerID пуст), кадр строится для всевидящего наблюдателя.
+func (c *ReplayCursor) Frame(viewerID string) *api.ServerResponse {
+	inst := c.instance
+
+	observer := inst.World.GetEntity(viewerID)
+	if viewerID == "" || observer == nil {
+		observer = &domain.Entity{
+			Level:  inst.ID,
+			Vision: &domain.VisionComponent{Omniscient: true},
+		}
+	}
+
+	activeID := ""
+	if item := inst.TurnManager.PeekNext(); item != nil {
+		activeID = item.Value.ID
+	}
+
+	frame := c.service.BuildStateFor(observer, activeID, inst)
+	// Логи уходят в кадр один раз, как при рассылке в живой игре
+	inst.Logs = []api.LogEntry{}
+	return frame
+}
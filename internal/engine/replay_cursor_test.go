@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"cognitive-server/pkg/logger"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Перемотка (с кадров записи и с начала сегментов) приходит в то же состояние,
+// что и проигрывание подряд.
+func TestReplayCursor_SeekMatchesSequential(t *testing.T) {
+	logger.Init()
+	logger.Log.SetLevel(logrus.FatalLevel)
+
+	files, err := filepath.Glob(filepath.Join(goldenDir, "*.cdrp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiKeyframe := false
+
+	for _, path := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".cdrp"), func(t *testing.T) {
+			c, err := OpenReplay(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Эталон: хеш после каждого шага при проигрывании подряд
+			hashes := []uint64{c.StateHash()}
+			for c.Next() {
+				if step := c.Position().Step; step != len(hashes) {
+					t.Fatalf("sequential step = %d, want %d", step, len(hashes))
+				}
+				hashes = append(hashes, c.StateHash())
+			}
+			total := c.Position().Total
+			if len(hashes) != total+1 {
+				t.Fatalf("played %d of %d actions", len(hashes)-1, total)
+			}
+
+			// По обе стороны каждого кадра записи и каждой границы сегментов, плюс конец
+			targets := []int{total}
+			base := 0
+			for _, segment := range c.run.Segments {
+				targets = append(targets, base, base+1)
+				for _, kf := range segment.Keyframes {
+					step := base + kf.Action
+					targets = append(targets, step-1, step, step+1)
+				}
+				if len(segment.Keyframes) > 1 {
+					multiKeyframe = true
+				}
+				base += len(segment.Actions)
+			}
+
+			check := func(step int) {
+				t.Helper()
+				step = max(0, min(step, total))
+				if got := c.Position().Step; got != step {
+					t.Fatalf("seek(%d): step = %d", step, got)
+				}
+				if got := c.StateHash(); got != hashes[step] {
+					t.Fatalf("seek(%d): state hash = %016x, want %016x", step, got, hashes[step])
+				}
+			}
+
+			// Вперед, затем назад: перемотка назад всегда пересоздает сервис
+			for _, order := range [][]int{targets, reversed(targets)} {
+				for _, step := range order {
+					if err := c.Seek(step); err != nil {
+						t.Fatalf("seek(%d): %v", step, err)
+					}
+					check(step)
+				}
+			}
+
+			// SeekTick встает на первое действие не раньше тика
+			for _, step := range targets {
+				act, ok := c.Action(step)
+				if !ok {
+					continue
+				}
+				if err := c.SeekTick(act.Tick); err != nil {
+					t.Fatalf("seek tick %d: %v", act.Tick, err)
+				}
+				want := 0
+				for a, ok := c.Action(want); ok && a.Tick < act.Tick; a, ok = c.Action(want) {
+					want++
+				}
+				check(want)
+			}
+		})
+	}
+
+	if !multiKeyframe {
+		t.Error("no corpus segment has more than one keyframe")
+	}
+}
+
+func reversed(steps []int) []int {
+	out := make([]int, len(steps))
+	for k, step := range steps {
+		out[len(steps)-1-k] = step
+	}
+	return out
+}
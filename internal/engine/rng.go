@@ -0,0 +1,39 @@
+package engine
+
+import "math/rand"
+
+// countingSource - источник случайных чисел уровня, который считает вытянутые числа.
+// Состояние math/rand не сериализуется, но его можно восстановить по сиду и счетчику:
+// так кадр реплея хранит RNG в одном числе, а последовательность остается прежней.
+type countingSource struct {
+	src   rand.Source64
+	draws uint64
+}
+
+func newCountingSource(seed int64) *countingSource {
+	return &countingSource{src: rand.NewSource(seed).(rand.Source64)}
+}
+
+func (s *countingSource) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.draws++
+	return s.src.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.src.Seed(seed)
+	s.draws = 0
+}
+
+// restore пересевает источник и проматывает его на draws чисел вперед
+func (s *countingSource) restore(seed int64, draws uint64) {
+	s.Seed(seed)
+	for range draws {
+		s.src.Uint64()
+	}
+	s.draws = draws
+}
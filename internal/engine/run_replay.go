@@ -0,0 +1,348 @@
+package engine
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/infrastructure/storage"
+	"cognitive-server/pkg/logger"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Запись уровня режется на сегменты при каждом входе, выходе и смене контроллера игрока:
+// внутри сегмента состав игроков и то, кто из них ходит по командам клиента, не меняются,
+// поэтому сегмент можно проиграть отдельно, в том числе с несколькими игроками.
+// Сегмент по ходу игры дописывается в файлы забегов игроков, чьи ходы в нем ждутся от клиента.
+
+// handleJoin добавляет вошедшую сущность. Приход игрока закрывает текущий сегмент
+// и сразу начинает новый: снапшот уже включает игрока, каким он пришел.
+func (i *Instance) handleJoin(e *domain.Entity) {
+	isPlayer := e.Type == domain.EntityTypePlayer
+	if isPlayer {
+		i.cutReplay(nil)
+	}
+	i.addEntity(e)
+
+	if isPlayer {
+		i.beginRecording()
+	}
+}
+
+// cutReplay закрывает текущий сегмент записи в файлах забегов.
+// exit - переход, которым закончился сегмент (nil, если игрок не менял уровень).
+func (i *Instance) cutReplay(exit *domain.ReplayTransition) {
+	if i.IsPlayback {
+		return
+	}
+
+	if i.recorded > 0 {
+		i.writeReplay(func(w *storage.RunWriter) error { return w.EndSegment(exit) })
+	}
+	i.resetReplay()
+
+	// Журнал начинается вместе с новым сегментом: его снапшот - точка восстановления
+	i.CloseJournal()
+}
+
+// resetReplay начинает пустую запись уровня. Сегмент попадет в файлы только с первым действием.
+// Рецепт остается тем, с которым уровень построен: перезагрузка контента не меняет готовую карту.
+func (i *Instance) resetReplay() {
+	recipe := i.Replay.Recipe
+	i.Replay = newReplaySession(i.ID, i.Seed)
+	i.Replay.Recipe = recipe
+	i.recorded = 0
+	i.replayRuns = nil
+}
+
+// openReplaySegment начинает сегмент в файлах забегов живых игроков, чьи ходы в нем
+// ждутся от клиента. Вызывается перед первым действием: пустые сегменты в файлы не попадают.
+func (i *Instance) openReplaySegment() {
+	players := make([]string, 0, len(i.Replay.Controllers))
+	for id, c := range i.Replay.Controllers {
+		if e := i.World.GetEntity(id); c == domain.ControllerClient && e != nil && (e.Stats == nil || !e.Stats.IsDead) {
+			players = append(players, id)
+		}
+	}
+	sort.Strings(players)
+
+	for _, id := range players {
+		w := i.Service.runReplay(id)
+		if w == nil {
+			continue
+		}
+		if err := w.BeginSegment(i.Replay); err != nil {
+			logger.Log.WithFields(logrus.Fields{"instance": i.ID, "entity_id": id}).Errorf("Failed to start replay segment: %v", err)
+			continue
+		}
+		i.replayRuns = append(i.replayRuns, w)
+	}
+}
+
+// writeReplay дописывает запись во все файлы забегов текущего сегмента.
+// Файл, в который записать не удалось (забег закончился, ошибка диска), выпадает из сегмента.
+func (i *Instance) writeReplay(write func(w *storage.RunWriter) error) {
+	kept := i.replayRuns[:0]
+	for _, w := range i.replayRuns {
+		if err := write(w); err != nil {
+			logger.Log.WithField("instance", i.ID).Errorf("Failed to write replay: %v", err)
+			continue
+		}
+		kept = append(kept, w)
+	}
+	i.replayRuns = kept
+}
+
+// runReplay возвращает открытый файл реплея забега игрока, при необходимости создавая его.
+// nil - записывать некуда (папка реплеев не задана или ошибка диска). Вызывается из горутин разных инстансов.
+func (s *GameService) runReplay(playerID string) *storage.RunWriter {
+	if s.Config.ReplayDir == "" {
+		return nil
+	}
+
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	if w, ok := s.runReplays[playerID]; ok {
+		return w
+	}
+	w, err := s.Storage.CreateRun(playerID, now().Unix())
+	if err != nil {
+		logger.Log.WithField("entity_id", playerID).Errorf("Failed to create run replay: %v", err)
+		return nil
+	}
+	s.runReplays[playerID] = w
+	return w
+}
+
+// saveRunReplay закрывает файл реплея забега игрока. Следующий забег начнет новый файл.
+func (s *GameService) saveRunReplay(playerID string) {
+	s.replayMu.Lock()
+	w, ok := s.runReplays[playerID]
+	delete(s.runReplays, playerID)
+	s.replayMu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if err := w.Close(); err != nil {
+		logger.Log.WithField("entity_id", playerID).Errorf("Failed to save run replay: %v", err)
+		return
+	}
+	logger.Log.WithFields(logrus.Fields{
+		"entity_id": playerID,
+		"segments":  w.Segments(),
+	}).Info("Run replay saved")
+}
+
+// Shutdown останавливает циклы всех уровней и дописывает файлы незавершенных забегов.
+// Сегменты записи и персонажей каждый уровень сохраняет сам, в своей горутине (см. Stop).
+// Вызывается при остановке сервера.
+func (s *GameService) Shutdown() {
+	// Пока останавливаются одни уровни, переход с них мог создать новый - останавливаем и его
+	stopped := make(map[*Instance]bool)
+	for pending := true; pending; {
+		pending = false
+		for _, inst := range s.Levels.All() {
+			if !stopped[inst] {
+				inst.Stop()
+				stopped[inst] = true
+				pending = true
+			}
+		}
+	}
+
+	s.replayMu.Lock()
+	players := make([]string, 0, len(s.runReplays))
+	for id := range s.runReplays {
+		players = append(players, id)
+	}
+	s.replayMu.Unlock()
+
+	for _, id := range players {
+		s.saveRunReplay(id)
+	}
+}
+
+// --- Воспроизведение ---
+
+// PlaybackResult - итог проигрывания реплея забега
+type PlaybackResult struct {
+	Segments    int // Проиграно сегментов
+	Actions     int // Выполнено записанных действий
+	Checkpoints int // Записанных хешей состояния
+	Desyncs     []Desync
+}
+
+// LoadReplay загружает реплей (забега или одного уровня) и готовит инстанс первого сегмента.
+func (s *GameService) LoadReplay(path string) error {
+	run, err := s.Storage.LoadRun(path)
+	if err != nil {
+		return err
+	}
+	if len(run.Segments) == 0 {
+		return errors.New("replay has no segments")
+	}
+
+	first := run.Segments[0]
+	logger.Log.Infof("Loaded replay: Player=%s, Segments=%d, Seed=%d, Level=%d",
+		run.PlayerID, len(run.Segments), first.Seed, first.LevelID)
+
+	_, err = s.startPlayback(run, 0)
+	return err
+}
+
+// startPlayback готовит воспроизведение уже загруженного реплея с сегмента segment.
+// Сегменты при проигрывании не меняются, поэтому один реплей можно запускать повторно.
+func (s *GameService) startPlayback(run *domain.RunReplay, segment int) (*Instance, error) {
+	// В реплее хранится сид уровня, мастер-сид восстанавливаем обратной деривацией
+	first := run.Segments[0]
+	s.Levels.SetMasterSeed(masterSeedOf(first.Seed, first.LevelID))
+	s.Playback = run
+
+	return s.loadSegment(segment)
+}
+
+// loadSegment собирает инстанс сегмента и регистрирует его вместо текущего инстанса уровня.
+func (s *GameService) loadSegment(index int) (*Instance, error) {
+	segment := s.Playback.Segments[index]
+	instance, err := s.buildReplayInstance(segment)
+	if err != nil {
+		return nil, fmt.Errorf("segment %d: %w", index, err)
+	}
+	s.Levels.Register(instance)
+	return instance, nil
+}
+
+// PlayReplay проигрывает загруженный реплей сегмент за сегментом.
+// Переход между уровнями выполняет тот же ChangeLevel, что и в живой игре;
+// пришедший игрок сверяется с собой же в снапшоте следующего сегмента.
+// В режиме verify проигрывание останавливается на первом расхождении.
+func (s *GameService) PlayReplay(verify bool) (PlaybackResult, error) {
+	var result PlaybackResult
+	if s.Playback == nil {
+		return result, errors.New("no replay loaded")
+	}
+
+	instance, _ := s.Levels.Get(s.Playback.Segments[0].LevelID)
+	for k, segment := range s.Playback.Segments {
+		if k > 0 {
+			var err error
+			if instance, err = s.enterSegment(k, &result); err != nil {
+				return result, err
+			}
+		}
+		if verify && len(result.Desyncs) > 0 {
+			break
+		}
+
+		instance.VerifyMode = verify
+		instance.RunSimulation()
+
+		result.Segments++
+		result.Actions += instance.PlaybackCursor
+		result.Checkpoints += len(instance.PlaybackChecksums)
+		for _, d := range instance.Desyncs {
+			d.Segment, d.Level = k, segment.LevelID
+			result.Desyncs = append(result.Desyncs, d)
+		}
+		if verify && len(result.Desyncs) > 0 {
+			break
+		}
+
+		if segment.Exit != nil {
+			s.checkExit(instance, k, segment, &result)
+		}
+	}
+	return result, nil
+}
+
+// enterSegment переходит к следующему сегменту после того, как предыдущий доигран.
+func (s *GameService) enterSegment(index int, result *PlaybackResult) (*Instance, error) {
+	// Игрок, которого перевел ChangeLevel, живет во временном инстансе нового уровня.
+	// Запоминаем его до того, как инстанс заменит снапшот сегмента. Если переходил
+	// другой игрок, а этот остался на месте, сверять не с чем.
+	exit := s.Playback.Segments[index-1].Exit
+	var arrived *domain.Entity
+	if exit != nil && exit.ToLevel == s.Playback.Segments[index].LevelID {
+		arrived = s.GetEntity(exit.EntityID)
+	}
+
+	instance, err := s.loadSegment(index)
+	if err != nil {
+		return nil, err
+	}
+	if arrived != nil {
+		checkArrival(instance, index, arrived, result)
+	}
+	return instance, nil
+}
+
+// checkExit проверяет, что записанный переход действительно произошел при воспроизведении.
+func (s *GameService) checkExit(instance *Instance, index int, segment *domain.ReplaySession, result *PlaybackResult) {
+	exit := segment.Exit
+	if level, ok := s.Levels.Location(exit.EntityID); ok && level == exit.ToLevel {
+		return
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"segment": index,
+		"entity":  exit.EntityID,
+	}).Errorf("Desync detected: entity did not move to level %d", exit.ToLevel)
+	result.Desyncs = append(result.Desyncs, Desync{
+		Segment: index,
+		Level:   segment.LevelID,
+		Action:  exit.Action,
+		Tick:    instance.CurrentTick,
+		Actor:   exit.EntityID,
+		Reason:  DesyncTransition,
+	})
+}
+
+// checkArrival сравнивает игрока, которого воспроизведение перевело на уровень,
+// с ним же в снапшоте следующего сегмента.
+func checkArrival(next *Instance, index int, arrived *domain.Entity, result *PlaybackResult) {
+	expected := next.World.GetEntity(arrived.ID)
+	if expected != nil && sameArrival(arrived, expected) {
+		return
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"segment": index,
+		"entity":  arrived.ID,
+	}).Error("Desync detected: arrived entity does not match segment snapshot")
+
+	d := Desync{
+		Segment: index,
+		Level:   next.ID,
+		Tick:    next.CurrentTick,
+		Actor:   arrived.ID,
+		Reason:  DesyncTransition,
+	}
+	// Для диффа: каким игрок записан в снапшоте и каким его привел переход
+	d.After, _ = domain.EncodeEntities([]*domain.Entity{arrived})
+	if expected != nil {
+		d.Before, _ = domain.EncodeEntities([]*domain.Entity{expected})
+	}
+	result.Desyncs = append(result.Desyncs, d)
+}
+
+// sameArrival сравнивает то, что переход переносит с уровня на уровень.
+// Время хода не сравнивается: его выставляет новый уровень при входе.
+func sameArrival(a, b *domain.Entity) bool {
+	if a.Level != b.Level || a.Pos != b.Pos {
+		return false
+	}
+	if (a.Stats == nil) != (b.Stats == nil) || (a.Inventory == nil) != (b.Inventory == nil) {
+		return false
+	}
+	if a.Stats != nil && (a.Stats.HP != b.Stats.HP || a.Stats.Stamina != b.Stats.Stamina || a.Stats.Gold != b.Stats.Gold) {
+		return false
+	}
+	if a.Inventory != nil && len(a.Inventory.Items) != len(b.Inventory.Items) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/infrastructure/storage"
+	"cognitive-server/pkg/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newRunStore выбирает хранилище истории забегов по конфигу
+func newRunStore(dir string) storage.RunStore {
+	if dir == "" {
+		return storage.NewMemoryRunStore()
+	}
+	store, err := storage.NewFileRunStore(dir)
+	if err != nil {
+		logger.Log.Errorf("Failed to open run history, keeping it in memory: %v", err)
+		return storage.NewMemoryRunStore()
+	}
+	return store
+}
+
+// startRun заводит статистику забега игроку в подземелье, у которого ее еще нет.
+// На поверхности забега нет: он начинается со спуска, иначе время в городе попадет в статистику.
+func (s *GameService) startRun(e *domain.Entity) {
+	if e.Type != domain.EntityTypePlayer || e.Run != nil || e.Level == 0 {
+		return
+	}
+	e.Run = domain.NewRunStats(now().Unix(), e.Level)
+}
+
+// FinishRun записывает итог забега в историю и сбрасывает статистику игрока.
+// Следующий забег начнется при следующем входе или переходе.
+func (s *GameService) FinishRun(e *domain.Entity, outcome string) {
+	run := domain.NewRunSummary(e, outcome, now().Unix())
+	if run == nil {
+		return
+	}
+	e.Run = nil
+
+	// При доигрывании журнала забег уже был записан до падения
+	if s.recovering {
+		return
+	}
+
+	s.saveRunReplay(e.ID)
+
+	if err := s.Runs.Add(run); err != nil {
+		logger.Log.WithField("entity_id", e.ID).Errorf("Failed to save run: %v", err)
+		return
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"entity_id": e.ID,
+		"outcome":   outcome,
+		"depth":     run.Depth,
+		"kills":     run.TotalKills,
+	}).Info("Run finished")
+}
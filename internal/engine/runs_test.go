@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/infrastructure/storage"
+	"testing"
+	"time"
+)
+
+// Выход на поверхность закрывает забег, а новый начинается только со следующего спуска
+func TestRun_EscapeThenDescend(t *testing.T) {
+	defer func() { now = time.Now }()
+	at := func(sec int64) { now = func() time.Time { return time.Unix(sec, 0) } }
+
+	s := newTestService(3)
+	hero := s.Levels.createInitial()
+	if hero.Run != nil {
+		t.Fatal("run started on the surface")
+	}
+
+	at(100)
+	s.ChangeLevel(hero, 1, "exit_up_from_1")
+	if hero.Run == nil || hero.Run.StartedAt != 100 || hero.Run.MaxDepth != 1 {
+		t.Fatalf("run after descent = %+v", hero.Run)
+	}
+	hero.Run.Ticks = 500
+
+	at(200)
+	s.ChangeLevel(hero, 0, "exit_down_from_0")
+	if hero.Run != nil {
+		t.Fatalf("run kept on the surface: %+v", hero.Run)
+	}
+
+	// Вход на поверхность (как после рестарта) забег тоже не начинает
+	s.AddPlayerToLevel(hero)
+	if hero.Run != nil {
+		t.Fatalf("run started by joining the surface: %+v", hero.Run)
+	}
+
+	at(300)
+	s.ChangeLevel(hero, 1, "exit_up_from_1")
+	if hero.Run == nil || hero.Run.StartedAt != 300 || hero.Run.Ticks != 0 {
+		t.Fatalf("second run = %+v", hero.Run)
+	}
+
+	page, err := s.Runs.Query(storage.RunQuery{PlayerID: hero.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 {
+		t.Fatalf("%d run summaries, want 1", page.Total)
+	}
+	run := page.Runs[0]
+	if run.Outcome != domain.RunOutcomeEscaped || run.StartedAt != 100 || run.EndedAt != 200 || run.Ticks != 500 {
+		t.Errorf("summary = %+v", run)
+	}
+}
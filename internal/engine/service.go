@@ -15,21 +15,35 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/rand"
+	"sync"
+	"time"
 )
 
+// now - часы движка для меток времени в записях и статистике.
+// Запись корпуса золотых реплеев подменяет их, чтобы файлы не зависели от момента записи.
+var now = time.Now
+
 type GameService struct {
 	Config Config
 
-	// Храним данные всех уровней (статические данные GameWorld)
-	Worlds map[int]*domain.GameWorld
+	// Уровни: сиды, генерация, запущенные инстансы и где какая сущность
+	Levels *LevelManager
 
-	// Активные запущенные инстансы
-	Instances map[int]*Instance
+	Storage    *storage.ReplayService
+	Characters storage.CharacterStore
+	Journals   *storage.JournalStore // nil, если журнал выключен
+	Runs       storage.RunStore
 
-	// Индекс: где находится сущность? (EntityID -> LevelID)
-	EntityLocations map[string]int
+	// recovering - идет восстановление из журнала, новые инстансы не запускаем
+	recovering bool
+	// offline - сервис только проигрывает реплеи: циклов нет, переходы между уровнями синхронные
+	offline bool
 
-	Storage *storage.ReplayService
+	// Файлы реплеев незавершенных забегов (PlayerID -> запись)
+	replayMu   sync.Mutex
+	runReplays map[string]*storage.RunWriter
+	// Playback - загруженный для воспроизведения реплей забега
+	Playback *domain.RunReplay
 
 	// Каналы для main.go (входная точка)
 	JoinChan       chan *domain.Entity
@@ -43,15 +57,54 @@ type GameService struct {
 }
 
 func NewService(cfg Config) *GameService {
-	worlds, allEntities, seeds := buildInitialWorld(cfg.Seed)
+	s := recoverService(cfg)
+	for _, instance := range s.Levels.All() {
+		go instance.Run()
+	}
+	return s
+}
+
+// recoverService собирает живой сервис без запуска циклов: стартовый мир
+// и уровни, восстановленные из журнала после падения.
+func recoverService(cfg Config) *GameService {
+	journals := newJournalStore(cfg.JournalDir)
+
+	// После падения мир должен сгенерироваться с тем же сидом, что и в журнале
+	if seed, ok := recoveredMasterSeed(journals); ok {
+		logger.Log.Infof("Journal found, restoring Master Seed: %d", seed)
+		cfg.Seed = seed
+	}
+
+	s := newService(cfg)
+	s.Journals = journals
+
+	// 1. Стартовые уровни с игроком, циклы пока не запущены
+	s.Levels.createInitial()
+
+	// 2. Доигрываем журнал поверх свежесгенерированных уровней
+	s.recovering = true
+	s.recoverInstances()
+	s.recovering = false
+	return s
+}
+
+// NewPlaybackService создает сервис для офлайн-воспроизведения реплеев (утилиты, тесты).
+// В нем нет стартовых уровней и игровых циклов, а персонажи и статистика живут только в памяти.
+func NewPlaybackService() *GameService {
+	s := newService(Config{})
+	s.offline = true
+	return s
+}
 
+// newService собирает пустой сервис: хранилища, каналы и хендлеры, без уровней.
+func newService(cfg Config) *GameService {
 	s := &GameService{
-		Config:          cfg,
-		Worlds:          worlds,
-		Instances:       make(map[int]*Instance),
-		EntityLocations: make(map[string]int),
+		Config:     cfg,
+		runReplays: make(map[string]*storage.RunWriter),
 
-		Storage: storage.NewReplayService("./replays"),
+		Storage:    storage.NewReplayService(cfg.ReplayDir),
+		Characters: newCharacterStore(cfg.CharacterDir),
+		Runs:       newRunStore(cfg.StatsDir),
 
 		JoinChan:       make(chan *domain.Entity, 10),
 		DisconnectChan: make(chan string, 10),
@@ -61,44 +114,14 @@ func NewService(cfg Config) *GameService {
 		eventHandlers:  make(map[domain.EventType]handlers.HandlerFunc),
 	}
 
+	s.Levels = NewLevelManager(s, cfg.Seed)
 	s.registerHandlers()
-
-	// 1. Создаем и запускаем Инстансы для каждого мира
-	for id, world := range worlds {
-		// Используем прекалькулированный сид
-		instance := NewInstance(id, world, s, seeds[id])
-		s.Instances[id] = instance
-	}
-
-	// 2. Распределяем начальные сущности по инстансам
-	for _, e := range allEntities {
-		if instance, ok := s.Instances[e.Level]; ok {
-			s.EntityLocations[e.ID] = e.Level
-			// Напрямую добавляем, так как циклы только запустились
-			instance.addEntity(e)
-		}
-	}
-
-	for _, instance := range s.Instances {
-		go instance.Run()
-	}
-
 	return s
 }
 
-// GetEntity ищет сущность. Использует быстрый индекс EntityLocations.
+// GetEntity ищет сущность на любом созданном уровне, в том числе сгенерированном на лету
 func (s *GameService) GetEntity(id string) *domain.Entity {
-	// 1. Узнаем уровень
-	levelID, ok := s.EntityLocations[id]
-	if !ok {
-		return nil
-	}
-
-	// 2. Берем из мира (GameWorld хранит реестр)
-	if world, ok := s.Worlds[levelID]; ok {
-		return world.GetEntity(id)
-	}
-	return nil
+	return s.Levels.Entity(id)
 }
 
 func (s *GameService) registerHandlers() {
@@ -117,6 +140,7 @@ func (s *GameService) registerHandlers() {
 	s.actionHandlers[domain.ActionUnequip] = handlers.WithPayload(actions.HandleUnequip)
 
 	s.eventHandlers[domain.EventLevelTransition] = handlers.WithPayload(events.HandleLevelTransition)
+	s.eventHandlers[domain.EventRestore] = handlers.WithPayload(events.HandleRestore)
 
 	// Admin / Cheats
 	s.actionHandlers[domain.ActionAdminTeleport] = handlers.WithPayload(admin.HandleTeleport)
@@ -143,9 +167,9 @@ func (s *GameService) DispatcherLoop() {
 
 		// Дисконнект (из main.go)
 		case entityID := <-s.DisconnectChan:
-			levelID, ok := s.EntityLocations[entityID]
+			levelID, ok := s.Levels.Location(entityID)
 			if ok {
-				if instance, ok := s.Instances[levelID]; ok {
+				if instance, ok := s.Levels.Get(levelID); ok {
 					// Сообщаем инстансу, чтобы он прервал ход
 					select {
 					case instance.LeaveChan <- entityID:
@@ -159,17 +183,17 @@ func (s *GameService) DispatcherLoop() {
 
 // AddPlayerToLevel добавляет игрока в нужный инстанс
 func (s *GameService) AddPlayerToLevel(e *domain.Entity) {
-	instance, ok := s.Instances[e.Level]
+	// Восстановленный персонаж может стоять на уровне, который еще не сгенерирован
+	instance, _ := s.Levels.GetOrCreate(e.Level, nil)
+	s.startRun(e) // Только в подземелье: на поверхности забег начнется со спуска
 
-	// Если уровня нет (например, процедурный левел, который еще не создан)
-	// В текущей архитектуре мы создаем уровни при старте, но тут можно добавить Lazy Init
-	if !ok {
-		logger.Log.Warnf("Level %d not found for player %s", e.Level, e.ID)
-		return
+	// Сохраненная позиция могла стать невалидной (другая карта, клетка занята стеной)
+	if !isWalkable(instance.World, e.Pos) {
+		e.Pos = findSpawnPos(instance.World, e.Level)
 	}
 
 	// Обновляем глобальный индекс
-	s.EntityLocations[e.ID] = e.Level
+	s.Levels.SetLocation(e.ID, e.Level)
 
 	// Отправляем в инстанс
 	instance.JoinChan <- e
@@ -178,7 +202,7 @@ func (s *GameService) AddPlayerToLevel(e *domain.Entity) {
 // ProcessCommand маршрутизирует команды в нужный инстанс
 func (s *GameService) ProcessCommand(cmd api.ClientCommand) {
 	// 1. Где игрок?
-	levelID, ok := s.EntityLocations[cmd.Token]
+	levelID, ok := s.Levels.Location(cmd.Token)
 	if !ok {
 		// Игрока нет в индексе (возможно, только зашел и шлет INIT).
 		// В этом случае игнорируем, так как INIT при входе отправляется автоматически из main.go,
@@ -188,7 +212,7 @@ func (s *GameService) ProcessCommand(cmd api.ClientCommand) {
 	}
 
 	// 2. Получаем инстанс
-	instance, ok := s.Instances[levelID]
+	instance, ok := s.Levels.Get(levelID)
 	if !ok {
 		return
 	}
@@ -219,6 +243,20 @@ func (s *GameService) ChangeLevel(actor *domain.Entity, newLevelID int, targetPo
 
 	logger.Log.Infof("Transitioning entity %s from Level %d to %d", actor.ID, oldLevelID, newLevelID)
 
+	// Переход выполняется в горутине старого инстанса (из хендлера действия), поэтому актора
+	// снимаем со старого уровня сразу, пока у него старая позиция, и только потом отдаем новому.
+	// Переход закрывает сегмент записи старого уровня: вызвавшее его действие - последнее в сегменте.
+	if oldInstance, ok := s.Levels.Get(oldLevelID); ok {
+		oldInstance.cutReplay(&domain.ReplayTransition{
+			Action:   oldInstance.recorded - 1,
+			EntityID: actor.ID,
+			ToLevel:  newLevelID,
+			TargetID: targetPosID,
+		})
+		oldInstance.removeEntity(actor.ID)
+		oldInstance.beginRecording()
+	}
+
 	// Сохраняем состояние игрока ПЕРЕД тем, как он попадет в новый мир.
 	// Это состояние будет записано в заголовок реплея нового уровня.
 	var playerSnapshot json.RawMessage
@@ -228,33 +266,10 @@ func (s *GameService) ChangeLevel(actor *domain.Entity, newLevelID int, targetPo
 		logger.Log.Errorf("Failed to snapshot player: %v", err)
 	}
 
-	// 1. Получаем (или создаем) целевой Инстанс
-	newInstance, ok := s.Instances[newLevelID]
-	if !ok {
-		logger.Log.Infof("Generating new level %d on the fly...", newLevelID)
-
-		levelSeed := s.Config.Seed + int64(newLevelID)
+	// 1. Получаем (или создаем) целевой Инстанс. Снапшот игрока попадает в него до запуска.
+	newInstance, _ := s.Levels.GetOrCreate(newLevelID, playerSnapshot)
 
-		rng := rand.New(rand.NewSource(levelSeed))
-		newWorld, newEntities, _ := dungeon.Generate(newLevelID, rng)
-
-		newInstance = NewInstance(newLevelID, newWorld, s, levelSeed)
-		newInstance.Replay.PlayerState = playerSnapshot
-
-		for i := range newEntities {
-			newInstance.addEntity(&newEntities[i])
-		}
-
-		s.Instances[newLevelID] = newInstance
-		go newInstance.Run()
-	}
-
-	// 2. Удаляем актора из СТАРОГО инстанса
-	if oldInstance, ok := s.Instances[oldLevelID]; ok {
-		oldInstance.LeaveChan <- actor.ID
-	}
-
-	// 3. Вычисляем позицию в НОВОМ инстансе
+	// 2. Вычисляем позицию в НОВОМ инстансе
 	targetPos := domain.Position{X: 1, Y: 1}
 
 	// Ищем в реестре мира (это безопасно, т.к. GameWorld - это данные)
@@ -269,10 +284,19 @@ func (s *GameService) ChangeLevel(actor *domain.Entity, newLevelID int, targetPo
 		}
 	}
 
-	// 4. Обновляем данные актора
+	// 3. Обновляем данные актора
 	actor.Level = newLevelID
 	actor.Pos = targetPos
 
+	// Подъем на поверхность завершает забег, следующий спуск начнет новый (startRun на поверхности не заводит)
+	if newLevelID == 0 && oldLevelID > 0 {
+		s.FinishRun(actor, domain.RunOutcomeEscaped)
+	}
+	s.startRun(actor)
+	if actor.Run != nil {
+		actor.Run.ReachDepth(newLevelID)
+	}
+
 	if actor.AI != nil {
 		actor.AI.State = domain.AIStateIdle
 		// Синхронизация времени
@@ -285,115 +309,138 @@ func (s *GameService) ChangeLevel(actor *domain.Entity, newLevelID int, targetPo
 		actor.Vision.CachedVisibleTiles = nil // Force clear old map
 	}
 
-	// 5. Обновляем Глобальный Индекс
-	s.EntityLocations[actor.ID] = newLevelID
+	// 4. Обновляем Глобальный Индекс
+	s.Levels.SetLocation(actor.ID, newLevelID)
 
-	// 6. Добавляем актора в НОВЫЙ инстанс
-	newInstance.JoinChan <- actor
+	// Фиксируем переход, чтобы после рестарта игрок оказался на новом уровне
+	s.SaveCharacter(actor)
 
-	newInstance.AddLog(fmt.Sprintf("%s переходит на уровень %d.", actor.Name, newLevelID), "INFO")
-}
-
-// LoadReplay инициализирует сервис и один инстанс на основе файла реплея
-func (s *GameService) LoadReplay(path string) error {
-	// 1. Читаем файл
-	session, err := s.Storage.Load(path)
-	if err != nil {
-		return err
+	// 5. Добавляем актора в НОВЫЙ инстанс
+	if s.offline {
+		newInstance.handleJoin(actor)
+	} else {
+		newInstance.JoinChan <- actor
 	}
 
-	logger.Log.Infof("Loaded replay: Seed=%d, Level=%d, Actions=%d", session.Seed, session.LevelID, len(session.Actions))
-
-	// 2. Обновляем конфиг сервиса (чтобы MasterSeed совпадал)
-	// В текущей реализации мы храним Seed в Instance, но глобальный тоже полезно обновить
-	s.Config.Seed = session.Seed
-
-	// 3. Воссоздаем мир с ТЕМ ЖЕ сидом
-	// Важно: мы должны использовать логику генерации, аналогичную ChangeLevel/NewService
-	// Для простоты предположим, что реплей записан для Dungeon (Level 1)
-	// Если replay для Level 0 - logic similar.
+	newInstance.AddLog(fmt.Sprintf("%s переходит на уровень %d.", actor.Name, newLevelID), "INFO")
+}
 
+// buildReplayInstance воссоздает уровень из сессии и готовит его к воспроизведению.
+// Инстанс не регистрируется в сервисе и не запускается.
+func (s *GameService) buildReplayInstance(session *domain.ReplaySession) (*Instance, error) {
 	levelID := session.LevelID
 
-	// Генерируем мир детерминировано
-	rng := rand.New(rand.NewSource(session.Seed))
-
-	var world *domain.GameWorld
-	var entities []domain.Entity
-	var startPos domain.Position
+	// Запись со старым рецептом на текущем генераторе даст другую карту
+	if session.Recipe != "" && session.Recipe != levelRecipe(levelID) {
+		return nil, fmt.Errorf("replay recipe %q does not match level %d recipe %q",
+			session.Recipe, levelID, levelRecipe(levelID))
+	}
 
-	if levelID == 0 {
-		world, entities, startPos = dungeon.GenerateSurface()
-	} else {
-		world, entities, startPos = dungeon.Generate(levelID, rng)
+	// Сид уровня выводится из мастер-сида так же, как в живой игре. Запись из другого мира не подойдет.
+	if seed := s.Levels.Seed(levelID); session.Seed != seed {
+		return nil, fmt.Errorf("replay seed %d does not match level %d seed %d", session.Seed, levelID, seed)
 	}
 
+	// 3. Воссоздаем мир тем же генератором, что и в живой игре
+	world, entities, startPos := s.Levels.Generate(levelID)
+
 	// 4. Создаем Инстанс
 	instance := NewInstance(levelID, world, s, session.Seed)
-	// Важно: восстанавливаем actions и playerState в инстанс, чтобы если мы сохраним его снова, данные не потерялись
+	// Важно: восстанавливаем playerState в инстанс, чтобы если мы сохраним его снова, данные не потерялись
 	instance.Replay.PlayerState = session.PlayerState
+	instance.Replay.Timestamp = session.Timestamp
+	if session.HashInterval > 0 {
+		instance.Replay.HashInterval = session.HashInterval
+	}
+
+	// Генератор в живой игре шел с создания уровня: проматываем его до начала записи
+	instance.rngSource.restore(session.Seed, session.RngDraws)
+	instance.Replay.RngDraws = session.RngDraws
+	instance.Replay.ActiveID = session.ActiveID
+	instance.resumeActor = session.ActiveID
+
+	// Загружаем сущности: из снапшота (v2) или генерацией по сиду (v1)
+	if len(session.Entities) > 0 {
+		snapshot, err := domain.DecodeEntities(session.Entities)
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore entities: %w", err)
+		}
+		for _, e := range snapshot {
+			instance.restoreEntity(e)
+		}
+		instance.Replay.Entities = session.Entities
+	} else {
+		for i := range entities {
+			instance.addEntity(&entities[i])
+		}
+		instance.captureStartSnapshot()
+	}
+
+	if session.Controllers != nil {
+		// Все игроки уже в снапшоте, а кто из них ходит по записи - сказано в карте контроллеров
+		instance.Replay.Controllers = session.Controllers
+		for _, e := range instance.Entities {
+			if e.Type == domain.EntityTypePlayer {
+				s.Levels.SetLocation(e.ID, levelID)
+			}
+		}
+	} else if err := s.restoreReplayPlayer(instance, session, startPos); err != nil {
+		return nil, err
+	}
 
-	// Загружаем сущности
-	for i := range entities {
-		instance.addEntity(&entities[i])
+	// 5. Настраиваем режим воспроизведения
+	instance.IsPlayback = true
+	instance.PlaybackActions = session.Actions
+	instance.PlaybackChecksums = make(map[int]uint64, len(session.Checksums))
+	for _, c := range session.Checksums {
+		instance.PlaybackChecksums[c.Action] = c.Hash
+	}
+	instance.PlaybackKeyframes = make(map[int]*domain.ReplayKeyframe, len(session.Keyframes))
+	for k := range session.Keyframes {
+		instance.PlaybackKeyframes[session.Keyframes[k].Action] = &session.Keyframes[k]
 	}
 
-	// Если в entities нет игрока (он приходит извне), его нужно создать.
-	// В реплее actions[0] обычно содержит логин или первое действие игрока.
-	// Для полной корректности нужно сохранять состояние игрока при входе в уровень.
-	// ПОКА: Предполагаем, что игрок создается через CreatePlayer ("hero_1") и ставим его на старт.
-	// Это упрощение. В продакшене реплей должен содержать snapshot игрока на входе.
-	// TODO: Определиться откуда брать игрока и как это делать лучше всего
+	return instance, nil
+}
 
-	// --- ВОССТАНОВЛЕНИЕ ИГРОКА ---
-	var player *domain.Entity
+// restoreReplayPlayer восстанавливает единственного игрока старой записи (без карты контроллеров)
+// и помечает его как управляемого записью.
+func (s *GameService) restoreReplayPlayer(instance *Instance, session *domain.ReplaySession, startPos domain.Position) error {
+	playerID := "hero_1"
+	if len(session.Actions) > 0 {
+		playerID = session.Actions[0].Token
+	}
+	player := instance.World.GetEntity(playerID)
 
-	if len(session.PlayerState) > 0 {
-		// ВАРИАНТ А: Снапшот есть (v2)
+	if player != nil {
+		// ВАРИАНТ А: Игрок уже в снапшоте уровня (запись началась при нем)
+		logger.Log.Info("Player found in entity snapshot")
+	} else if len(session.PlayerState) > 0 {
+		// ВАРИАНТ Б: Снапшот игрока есть (игрок пришел с другого уровня)
 		logger.Log.Info("Restoring player from snapshot...")
 		player = &domain.Entity{}
 		if err := json.Unmarshal(session.PlayerState, player); err != nil {
 			return fmt.Errorf("failed to restore player: %w", err)
 		}
-
-		// Принудительно ставим позицию, если она была записана криво, или доверяем снапшоту?
-		// В снапшоте позиция с ПРЕДЫДУЩЕГО уровня. Нам нужно её обновить на стартовую для ЭТОГО уровня.
-		player.Pos = startPos
-		player.Level = levelID // Обновляем уровень
-
+		player.RelinkEquipment()
 	} else {
-		// ВАРИАНТ Б: Снапшота нет (v1 или уровень 0)
+		// ВАРИАНТ В: Снапшота нет (игрок появился прямо на этом уровне).
+		// Игрок создается так же, как при логине: сид зависит только от его ID.
 		logger.Log.Info("No snapshot found, creating fresh player...")
-		playerID := "hero_1"
 		playerSeed := utils.StringToSeed(playerID)
 		playerRng := rand.New(rand.NewSource(playerSeed))
 		player = dungeon.CreatePlayer(playerID, playerRng)
+	}
+
+	if instance.World.GetEntity(player.ID) == nil {
+		// В снапшоте позиция с ПРЕДЫДУЩЕГО уровня. Ставим на стартовую для ЭТОГО уровня.
 		player.Pos = startPos
+		player.Level = instance.ID
+		instance.addEntity(player)
 	}
+
 	// Задаем фейковый ControllerID, чтобы движок знал: этим персонажем управляет "внешняя сила" (реплей), а не AI.
 	player.ControllerID = "replay_viewer"
-	// ... логика поиска старта ...
-
-	player.Pos = startPos
-	player.Level = levelID
-	instance.addEntity(player)
-	s.EntityLocations[player.ID] = levelID
-
-	// 5. Настраиваем режим воспроизведения
-	instance.IsPlayback = true
-	instance.PlaybackActions = session.Actions
-
-	// Регистрируем инстанс
-	s.Instances[levelID] = instance
-
+	s.Levels.SetLocation(player.ID, instance.ID)
 	return nil
 }
-
-// StartPlayback запускает симуляцию загруженного инстанса
-func (s *GameService) StartPlayback(levelID int) {
-	if instance, ok := s.Instances[levelID]; ok {
-		instance.RunSimulation()
-	} else {
-		logger.Log.Error("Instance not found for playback")
-	}
-}
@@ -65,15 +65,14 @@ func (s *GameService) BuildStateFor(observer *domain.Entity, activeID string, in
 				tile := observerWorld.Map[y][x]
 				isVisible := isGod || visibleIdxs[idx]
 
+				// Вид клетки берется из реестра типов (скрытая ловушка выглядит полом)
+				def := tile.Type.Def()
 				tView := api.TileView{
 					X: x, Y: y, IsWall: tile.IsWall,
+					Type:       def.Name,
 					IsVisible:  isVisible,
 					IsExplored: true,
-					Symbol:     ".", Color: "#333333",
-				}
-				if tile.IsWall {
-					tView.Symbol = "#"
-					tView.Color = "#666666"
+					Symbol:     def.Symbol, Color: def.Color,
 				}
 				mapDTO = append(mapDTO, tView)
 			}
@@ -180,9 +179,13 @@ func (s *GameService) toEntityView(target *domain.Entity, observer *domain.Entit
 						ID:          item.ID,
 						Name:        item.Name,
 						Category:    item.Item.Category.String(),
+						Rarity:      item.Item.Rarity.String(),
+						Affixes:     item.Item.Affixes,
 						IsStackable: item.Item.IsStackable,
 						StackSize:   item.Item.StackSize,
 						Damage:      item.Item.Damage,
+						AttackSpeed: item.Item.AttackSpeed,
+						LifeOnHit:   item.Item.LifeOnHit,
 						Defense:     item.Item.Defense,
 						Weight:      item.Item.Weight,
 						Price:       item.Item.Price,
@@ -208,12 +211,16 @@ func (s *GameService) toEntityView(target *domain.Entity, observer *domain.Entit
 			if target.Equipment.Weapon != nil && target.Equipment.Weapon.Item != nil {
 				w := target.Equipment.Weapon
 				weaponView := api.ItemView{
-					ID:       w.ID,
-					Name:     w.Name,
-					Category: w.Item.Category.String(),
-					Damage:   w.Item.Damage,
-					Weight:   w.Item.Weight,
-					Price:    w.Item.Price,
+					ID:          w.ID,
+					Name:        w.Name,
+					Category:    w.Item.Category.String(),
+					Rarity:      w.Item.Rarity.String(),
+					Affixes:     w.Item.Affixes,
+					Damage:      w.Item.Damage,
+					AttackSpeed: w.Item.AttackSpeed,
+					LifeOnHit:   w.Item.LifeOnHit,
+					Weight:      w.Item.Weight,
+					Price:       w.Item.Price,
 				}
 				if w.Render != nil {
 					weaponView.Symbol = string(w.Render.Symbol)
@@ -228,6 +235,8 @@ func (s *GameService) toEntityView(target *domain.Entity, observer *domain.Entit
 					ID:       a.ID,
 					Name:     a.Name,
 					Category: a.Item.Category.String(),
+					Rarity:   a.Item.Rarity.String(),
+					Affixes:  a.Item.Affixes,
 					Defense:  a.Item.Defense,
 					Weight:   a.Item.Weight,
 					Price:    a.Item.Price,
@@ -4,6 +4,7 @@ import (
 	"cognitive-server/internal/domain"
 	"cognitive-server/pkg/logger"
 	"container/heap"
+	"sort"
 )
 
 // TurnManager manages the priority queue of entity turns.
@@ -79,3 +80,13 @@ func (tm *TurnManager) DebugDump() []map[string]interface{} {
 	}
 	return result
 }
+
+// Snapshot возвращает очередь для кадра реплея (по возрастанию ID, чтобы запись была стабильной)
+func (tm *TurnManager) Snapshot() []domain.ReplayQueueEntry {
+	entries := make([]domain.ReplayQueueEntry, 0, len(tm.queue))
+	for _, item := range tm.queue {
+		entries = append(entries, domain.ReplayQueueEntry{EntityID: item.Value.ID, Priority: item.Priority})
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].EntityID < entries[b].EntityID })
+	return entries
+}
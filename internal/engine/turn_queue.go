@@ -19,7 +19,12 @@ func (pq TurnQueue) Len() int { return len(pq) }
 
 func (pq TurnQueue) Less(i, j int) bool {
 	// Мы хотим MinHeap, поэтому возвращаем true, если i < j
-	return pq[i].Priority < pq[j].Priority
+	if pq[i].Priority != pq[j].Priority {
+		return pq[i].Priority < pq[j].Priority
+	}
+	// При равном времени порядок задает ID, а не история кучи:
+	// так очередь, собранная из снапшота, ходит в том же порядке, что и живая
+	return pq[i].Value.ID < pq[j].Value.ID
 }
 
 func (pq TurnQueue) Swap(i, j int) {
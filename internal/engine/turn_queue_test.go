@@ -47,3 +47,19 @@ func TestTurnQueue(t *testing.T) {
 		t.Errorf("Expected e1 (Tick 30), got %s", third.Value.ID)
 	}
 }
+
+func TestTurnQueue_TieBreakByID(t *testing.T) {
+	// Порядок вставки не должен влиять на порядок ходов при равном времени
+	for _, order := range [][]string{{"a", "b", "c"}, {"c", "b", "a"}, {"b", "c", "a"}} {
+		pq := make(TurnQueue, 0)
+		for _, id := range order {
+			heap.Push(&pq, &TurnItem{Value: &domain.Entity{ID: id}, Priority: 100})
+		}
+
+		for _, want := range []string{"a", "b", "c"} {
+			if got := heap.Pop(&pq).(*TurnItem).Value.ID; got != want {
+				t.Errorf("insert order %v: expected %s, got %s", order, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"cognitive-server/internal/domain"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrCharacterNotFound возвращается, если для ID нет сохраненного персонажа.
+var ErrCharacterNotFound = errors.New("character not found")
+
+// CharacterStore хранит персонажей игроков между сессиями и рестартами.
+type CharacterStore interface {
+	Load(id string) (*domain.CharacterRecord, error)
+	Save(rec *domain.CharacterRecord) error
+	Delete(id string) error
+}
+
+// --- FILE STORE ---
+
+// FileCharacterStore хранит каждого персонажа в отдельном JSON-файле.
+type FileCharacterStore struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+func NewFileCharacterStore(dir string) *FileCharacterStore {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		_ = os.MkdirAll(dir, 0755)
+	}
+	return &FileCharacterStore{Dir: dir}
+}
+
+// path строит имя файла. ID приходит от клиента, поэтому экранируем его,
+// чтобы токен вида "../x" не вышел за пределы папки.
+func (s *FileCharacterStore) path(id string) string {
+	return filepath.Join(s.Dir, url.PathEscape(id)+".json")
+}
+
+func (s *FileCharacterStore) Load(id string) (*domain.CharacterRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrCharacterNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rec domain.CharacterRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("corrupted character %s: %w", id, err)
+	}
+	return &rec, nil
+}
+
+func (s *FileCharacterStore) Save(rec *domain.CharacterRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Пишем во временный файл и переименовываем, чтобы падение посреди записи
+	// не оставило персонажа с обрезанным JSON.
+	path := s.path(rec.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileCharacterStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// --- MEMORY STORE ---
+
+// MemoryCharacterStore держит персонажей в памяти процесса (тесты, запуск без диска).
+// Записи хранятся сериализованными, чтобы загрузка всегда отдавала независимую копию.
+type MemoryCharacterStore struct {
+	mu      sync.RWMutex
+	records map[string][]byte
+}
+
+func NewMemoryCharacterStore() *MemoryCharacterStore {
+	return &MemoryCharacterStore{records: make(map[string][]byte)}
+}
+
+func (s *MemoryCharacterStore) Load(id string) (*domain.CharacterRecord, error) {
+	s.mu.RLock()
+	data, ok := s.records[id]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrCharacterNotFound
+	}
+
+	var rec domain.CharacterRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *MemoryCharacterStore) Save(rec *domain.CharacterRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[rec.ID] = data
+	return nil
+}
+
+func (s *MemoryCharacterStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, id)
+	return nil
+}
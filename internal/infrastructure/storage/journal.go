@@ -0,0 +1,325 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"cognitive-server/internal/domain"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Параметры группового fsync по умолчанию.
+// Падение процесса теряет не больше одной неполной пачки.
+const (
+	DefaultJournalBatchSize     = 32
+	DefaultJournalFlushInterval = 50 * time.Millisecond
+)
+
+const (
+	snapshotExt = ".snap"
+	journalExt  = ".wal"
+)
+
+// JournalStore управляет снапшотами и журналами (write-ahead log) инстансов.
+//
+// Для каждого уровня на диске лежат два файла:
+//   - level_N.snap - реплей (.cdrp) на момент последней ротации;
+//   - level_N.wal  - действия, выполненные после снапшота.
+//
+// Запись журнала: [len uint32][crc32 uint32][action]. Обрезанная или битая запись
+// в хвосте (падение посреди write) отбрасывается при чтении.
+type JournalStore struct {
+	Dir           string
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+func NewJournalStore(dir string) *JournalStore {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		_ = os.MkdirAll(dir, 0755)
+	}
+	return &JournalStore{
+		Dir:           dir,
+		BatchSize:     DefaultJournalBatchSize,
+		FlushInterval: DefaultJournalFlushInterval,
+	}
+}
+
+func (s *JournalStore) snapshotPath(levelID int) string {
+	return filepath.Join(s.Dir, fmt.Sprintf("level_%d%s", levelID, snapshotExt))
+}
+
+func (s *JournalStore) journalPath(levelID int) string {
+	return filepath.Join(s.Dir, fmt.Sprintf("level_%d%s", levelID, journalExt))
+}
+
+// Levels возвращает отсортированный список уровней, для которых есть снапшот.
+func (s *JournalStore) Levels() ([]int, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var levels []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, snapshotExt) {
+			continue
+		}
+		var levelID int
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, snapshotExt), "level_%d", &levelID); err == nil {
+			levels = append(levels, levelID)
+		}
+	}
+	sort.Ints(levels)
+	return levels, nil
+}
+
+// Load читает снапшот уровня и дописывает к нему хвост журнала.
+// Второе значение - количество действий, восстановленных из журнала.
+func (s *JournalStore) Load(levelID int) (*domain.ReplaySession, int, error) {
+	f, err := os.Open(s.snapshotPath(levelID))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	session, err := readBinary(bufio.NewReader(f))
+	if err != nil {
+		return nil, 0, fmt.Errorf("snapshot level %d: %w", levelID, err)
+	}
+
+	tail, err := readJournal(s.journalPath(levelID))
+	if err != nil {
+		return nil, 0, fmt.Errorf("journal level %d: %w", levelID, err)
+	}
+
+	session.Actions = append(session.Actions, tail...)
+	return session, len(tail), nil
+}
+
+// Rotate атомарно записывает новый снапшот сессии, обнуляет журнал уровня
+// и открывает его для дозаписи. Все действия сессии после этого живут в снапшоте.
+func (s *JournalStore) Rotate(session *domain.ReplaySession) (*Journal, error) {
+	path := s.snapshotPath(session.LevelID)
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(f)
+	if err := writeBinary(w, session); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+
+	// Снапшот уже содержит все действия - старый журнал больше не нужен
+	jf, err := os.OpenFile(s.journalPath(session.LevelID), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return newJournal(jf, s.BatchSize, s.FlushInterval), nil
+}
+
+// Remove удаляет снапшот и журнал уровня.
+func (s *JournalStore) Remove(levelID int) error {
+	for _, path := range []string{s.snapshotPath(levelID), s.journalPath(levelID)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- JOURNAL ---
+
+// Journal - открытый на дозапись журнал одного инстанса.
+// Append буферизует запись; fsync выполняется пачками: по заполнению пачки
+// или по таймеру фоновой горутины.
+type Journal struct {
+	mu      sync.Mutex
+	f       *os.File
+	w       *bufio.Writer
+	pending int
+	batch   int
+	err     error // Первая ошибка записи; после нее журнал перестает писать
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newJournal(f *os.File, batch int, interval time.Duration) *Journal {
+	if batch <= 0 {
+		batch = 1
+	}
+	j := &Journal{
+		f:     f,
+		w:     bufio.NewWriter(f),
+		batch: batch,
+		done:  make(chan struct{}),
+	}
+
+	if interval > 0 {
+		j.wg.Add(1)
+		go j.flushLoop(interval)
+	}
+	return j
+}
+
+func (j *Journal) flushLoop(interval time.Duration) {
+	defer j.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = j.Sync()
+		case <-j.done:
+			return
+		}
+	}
+}
+
+// Append добавляет действие в журнал.
+func (j *Journal) Append(act domain.ReplayAction) error {
+	var body bytes.Buffer
+	if err := writeAction(&body, act); err != nil {
+		return err
+	}
+
+	var head [8]byte
+	binary.LittleEndian.PutUint32(head[0:4], uint32(body.Len()))
+	binary.LittleEndian.PutUint32(head[4:8], crc32.ChecksumIEEE(body.Bytes()))
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.err != nil {
+		return j.err
+	}
+	if _, err := j.w.Write(head[:]); err != nil {
+		j.err = err
+		return err
+	}
+	if _, err := j.w.Write(body.Bytes()); err != nil {
+		j.err = err
+		return err
+	}
+
+	j.pending++
+	if j.pending >= j.batch {
+		return j.syncLocked()
+	}
+	return nil
+}
+
+// Sync сбрасывает буфер на диск и делает fsync.
+func (j *Journal) Sync() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.syncLocked()
+}
+
+func (j *Journal) syncLocked() error {
+	if j.err != nil || j.pending == 0 {
+		return j.err
+	}
+	if err := j.w.Flush(); err != nil {
+		j.err = err
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		j.err = err
+		return err
+	}
+	j.pending = 0
+	return nil
+}
+
+// Close дописывает остаток буфера и закрывает файл.
+func (j *Journal) Close() error {
+	close(j.done)
+	j.wg.Wait()
+
+	err := j.Sync()
+	if cerr := j.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readJournal читает все целые записи журнала.
+// Чтение останавливается на первой обрезанной или битой записи.
+func readJournal(path string) ([]domain.ReplayAction, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return decodeJournal(bufio.NewReader(f)), nil
+}
+
+func decodeJournal(r io.Reader) []domain.ReplayAction {
+	var actions []domain.ReplayAction
+	var head [8]byte
+
+	for {
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return actions
+		}
+		size := binary.LittleEndian.Uint32(head[0:4])
+		sum := binary.LittleEndian.Uint32(head[4:8])
+
+		// Запись не может быть больше заголовка + токена + payload + контроллера
+		if size > 8+255+65535+1 {
+			return actions
+		}
+
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return actions
+		}
+		if crc32.ChecksumIEEE(body) != sum {
+			return actions
+		}
+
+		act, err := readActionBody(body)
+		if err != nil {
+			return actions
+		}
+		actions = append(actions, act)
+	}
+}
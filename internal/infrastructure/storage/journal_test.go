@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"cognitive-server/internal/domain"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestJournal_RecoversAfterTornWrite(t *testing.T) {
+	store := NewJournalStore(t.TempDir())
+
+	session := &domain.ReplaySession{
+		LevelID: 2,
+		Seed:    42,
+		Actions: []domain.ReplayAction{{Tick: 1, Token: "hero", Action: domain.ActionWait}},
+	}
+
+	journal, err := store.Rotate(session)
+	if err != nil {
+		t.Fatalf("rotate failed: %v", err)
+	}
+	for tick := 2; tick <= 4; tick++ {
+		act := domain.ReplayAction{Tick: tick, Token: "hero", Action: domain.ActionMove, Payload: json.RawMessage(`{"dx":1,"dy":0}`)}
+		if err := journal.Append(act); err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	// Имитируем падение посреди записи: в хвосте недописанная запись
+	f, err := os.OpenFile(store.journalPath(2), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write([]byte{40, 0, 0, 0, 1, 2, 3})
+	f.Close()
+
+	levels, err := store.Levels()
+	if err != nil || len(levels) != 1 || levels[0] != 2 {
+		t.Fatalf("unexpected levels %v (err %v)", levels, err)
+	}
+
+	loaded, tail, err := store.Load(2)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if tail != 3 {
+		t.Errorf("expected 3 actions from journal, got %d", tail)
+	}
+	if len(loaded.Actions) != 4 || loaded.Actions[3].Tick != 4 {
+		t.Fatalf("unexpected actions: %+v", loaded.Actions)
+	}
+	if string(loaded.Actions[3].Payload) != `{"dx":1,"dy":0}` {
+		t.Errorf("payload mismatch: %s", loaded.Actions[3].Payload)
+	}
+}
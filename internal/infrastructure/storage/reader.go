@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"bufio"
+	"bytes"
 	"cognitive-server/internal/domain"
 	"encoding/binary"
 	"encoding/json"
@@ -19,19 +21,105 @@ func (s *ReplayService) Load(path string) (*domain.ReplaySession, error) {
 	return readBinary(f)
 }
 
-func readBinary(r io.Reader) (*domain.ReplaySession, error) {
-	// 1. Читаем заголовок целиком
-	var header ReplayFileHeader
+// LoadRun читает запись забега. Одиночные записи уровня (v1, v2) становятся забегом из одного сегмента.
+func (s *ReplayService) LoadRun(path string) (*domain.RunReplay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readRun(bufio.NewReader(f))
+}
+
+func readRun(r io.Reader) (*domain.RunReplay, error) {
+	version, r, err := readPreamble(r)
+	if err != nil {
+		return nil, err
+	}
+	if version == Version4 {
+		return readV4(r)
+	}
+	if version != Version3 {
+		session, err := readVersion(version, r)
+		if err != nil {
+			return nil, err
+		}
+		return domain.NewRunReplay(session), nil
+	}
+
+	var header RunHeaderV3
 	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
 		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
+	player := make([]byte, header.PlayerLen)
+	if _, err := io.ReadFull(r, player); err != nil {
+		return nil, fmt.Errorf("failed to read player id: %w", err)
+	}
+
+	run := &domain.RunReplay{
+		PlayerID:  string(player),
+		Timestamp: header.Timestamp,
+		Segments:  make([]*domain.ReplaySession, 0, header.SegmentCount),
+	}
+	for k := 0; k < int(header.SegmentCount); k++ {
+		segment, err := readBinary(r)
+		if err != nil {
+			return nil, fmt.Errorf("segment %d: %w", k, err)
+		}
+		run.Segments = append(run.Segments, segment)
+	}
+	return run, nil
+}
+
+func readBinary(r io.Reader) (*domain.ReplaySession, error) {
+	version, r, err := readPreamble(r)
+	if err != nil {
+		return nil, err
+	}
+	return readVersion(version, r)
+}
+
+// readPreamble читает magic и версию, не съедая их: возвращает поток, который начинается с заголовка.
+func readPreamble(r io.Reader) (uint32, io.Reader, error) {
+	// 1. Magic и версия общие для всех форматов
+	var preamble struct {
+		Magic   [4]byte
+		Version uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &preamble); err != nil {
+		return 0, nil, fmt.Errorf("failed to read header: %w", err)
+	}
 
 	// Валидация
-	if string(header.Magic[:]) != MagicHeader {
-		return nil, fmt.Errorf("invalid magic")
+	if string(preamble.Magic[:]) != MagicHeader {
+		return 0, nil, fmt.Errorf("invalid magic")
 	}
-	if header.Version != Version1 {
-		return nil, fmt.Errorf("unsupported version: %d (expected %d)", header.Version, Version1)
+
+	// Возвращаем прочитанное в поток, чтобы заголовок версии читался целиком
+	var head bytes.Buffer
+	_ = binary.Write(&head, binary.LittleEndian, &preamble)
+	return preamble.Version, io.MultiReader(&head, r), nil
+}
+
+// readVersion читает одиночную запись уровня нужной версии
+func readVersion(version uint32, r io.Reader) (*domain.ReplaySession, error) {
+	switch version {
+	case Version1:
+		return readV1(r)
+	case Version2:
+		return readV2(r)
+	default:
+		return nil, fmt.Errorf("unsupported version: %d (expected %d or %d)", version, Version1, Version2)
+	}
+}
+
+// readV1 читает старый формат. В памяти он сразу становится сессией v2
+// без снапшота сущностей и хешей: такие уровни генерируются заново по сиду.
+func readV1(r io.Reader) (*domain.ReplaySession, error) {
+	var header ReplayFileHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
 
 	session := &domain.ReplaySession{
@@ -51,33 +139,222 @@ func readBinary(r io.Reader) (*domain.ReplaySession, error) {
 
 	// 3. Читаем Actions
 	for i := 0; i < int(header.ActionCount); i++ {
-		var ah ActionHeader
-		if err := binary.Read(r, binary.LittleEndian, &ah); err != nil {
+		act, err := readAction(r)
+		if err != nil {
 			return nil, err
 		}
+		session.Actions[i] = act
+	}
 
-		act := domain.ReplayAction{
-			Tick:   int(ah.Tick),
-			Action: domain.ActionType(ah.ActionType),
-		}
+	return session, nil
+}
 
-		tokenBuf := make([]byte, ah.TokenLen)
-		if _, err := io.ReadFull(r, tokenBuf); err != nil {
-			return nil, err
+// readV2 читает заголовок v2 и поток записей до RecordEnd.
+func readV2(r io.Reader) (*domain.ReplaySession, error) {
+	var header ReplayHeaderV2
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	recipe := make([]byte, header.RecipeLen)
+	if _, err := io.ReadFull(r, recipe); err != nil {
+		return nil, fmt.Errorf("failed to read recipe: %w", err)
+	}
+
+	session := &domain.ReplaySession{
+		Seed:         header.Seed,
+		Timestamp:    header.Timestamp,
+		LevelID:      int(header.LevelID),
+		Recipe:       string(recipe),
+		HashInterval: int(header.HashInterval),
+		Actions:      make([]domain.ReplayAction, 0),
+	}
+
+	for {
+		tag, body, err := readRecord(r)
+		if err != nil {
+			// Прочитанное отдаем вместе с ошибкой: из оборванной записи берут то, что успело записаться
+			return session, fmt.Errorf("failed to read record after %d actions: %w", len(session.Actions), err)
 		}
-		act.Token = string(tokenBuf)
 
-		if ah.PayloadLen > 0 {
-			act.Payload = make([]byte, ah.PayloadLen)
-			if _, err := io.ReadFull(r, act.Payload); err != nil {
+		switch tag {
+		case RecordEnd:
+			return session, nil
+		case RecordPlayerState:
+			session.PlayerState = body
+		case RecordEntities:
+			session.Entities = body
+		case RecordAction:
+			act, err := readActionBody(body)
+			if err != nil {
+				return nil, fmt.Errorf("corrupted action %d: %w", len(session.Actions), err)
+			}
+			session.Actions = append(session.Actions, act)
+		case RecordChecksum:
+			if len(body) != 12 {
+				return nil, fmt.Errorf("corrupted checksum record")
+			}
+			session.Checksums = append(session.Checksums, domain.ReplayChecksum{
+				Action: int(binary.LittleEndian.Uint32(body[0:4])),
+				Hash:   binary.LittleEndian.Uint64(body[4:12]),
+			})
+		case RecordKeyframe:
+			kf, err := readKeyframe(body)
+			if err != nil {
 				return nil, err
 			}
-		} else {
-			act.Payload = json.RawMessage{}
+			session.Keyframes = append(session.Keyframes, kf)
+		case RecordControllers:
+			controllers, err := readControllers(body)
+			if err != nil {
+				return nil, err
+			}
+			session.Controllers = controllers
+		case RecordStartState:
+			if len(body) < 10 || len(body) != 10+int(binary.LittleEndian.Uint16(body[8:10])) {
+				return nil, fmt.Errorf("corrupted start state record")
+			}
+			session.RngDraws = binary.LittleEndian.Uint64(body[0:8])
+			session.ActiveID = string(body[10:])
+		case RecordTransition:
+			exit, err := readTransition(body)
+			if err != nil {
+				return nil, err
+			}
+			session.Exit = exit
+		default:
+			// Запись из более новой версии формата - пропускаем
+		}
+	}
+}
+
+// readControllers разбирает тело записи RecordControllers
+func readControllers(body []byte) (map[string]domain.ControllerType, error) {
+	controllers := make(map[string]domain.ControllerType)
+	for len(body) > 0 {
+		if len(body) < 2 {
+			return nil, fmt.Errorf("corrupted controllers record")
+		}
+		idLen := int(binary.LittleEndian.Uint16(body[0:2]))
+		if len(body) < 2+idLen+1 {
+			return nil, fmt.Errorf("corrupted controllers record")
 		}
+		controllers[string(body[2:2+idLen])] = domain.ControllerType(body[2+idLen])
+		body = body[2+idLen+1:]
+	}
+	return controllers, nil
+}
 
-		session.Actions[i] = act
+// readKeyframe разбирает тело записи RecordKeyframe
+func readKeyframe(body []byte) (domain.ReplayKeyframe, error) {
+	if len(body) < 24 {
+		return domain.ReplayKeyframe{}, fmt.Errorf("corrupted keyframe record")
 	}
+	kf := domain.ReplayKeyframe{
+		Action:   int(binary.LittleEndian.Uint32(body[0:4])),
+		Tick:     int(int64(binary.LittleEndian.Uint64(body[4:12]))),
+		RngDraws: binary.LittleEndian.Uint64(body[12:20]),
+	}
+	count := int(binary.LittleEndian.Uint32(body[20:24]))
+	body = body[24:]
 
-	return session, nil
+	kf.Queue = make([]domain.ReplayQueueEntry, 0, min(count, len(body)/10))
+	for range count {
+		if len(body) < 2 {
+			return domain.ReplayKeyframe{}, fmt.Errorf("corrupted keyframe record")
+		}
+		idLen := int(binary.LittleEndian.Uint16(body[0:2]))
+		if len(body) < 2+idLen+8 {
+			return domain.ReplayKeyframe{}, fmt.Errorf("corrupted keyframe record")
+		}
+		kf.Queue = append(kf.Queue, domain.ReplayQueueEntry{
+			EntityID: string(body[2 : 2+idLen]),
+			Priority: int(int64(binary.LittleEndian.Uint64(body[2+idLen : 2+idLen+8]))),
+		})
+		body = body[2+idLen+8:]
+	}
+
+	kf.Entities = body
+	return kf, nil
+}
+
+// readTransition разбирает тело записи RecordTransition
+func readTransition(body []byte) (*domain.ReplayTransition, error) {
+	if len(body) < 12 {
+		return nil, fmt.Errorf("corrupted transition record")
+	}
+	entityLen := int(binary.LittleEndian.Uint16(body[8:10]))
+	targetLen := int(binary.LittleEndian.Uint16(body[10:12]))
+	if len(body) != 12+entityLen+targetLen {
+		return nil, fmt.Errorf("corrupted transition record")
+	}
+	return &domain.ReplayTransition{
+		Action:   int(binary.LittleEndian.Uint32(body[0:4])),
+		ToLevel:  int(int32(binary.LittleEndian.Uint32(body[4:8]))),
+		EntityID: string(body[12 : 12+entityLen]),
+		TargetID: string(body[12+entityLen:]),
+	}, nil
+}
+
+// maxRecordSize защищает от огромной аллокации при битой длине записи
+const maxRecordSize = 64 << 20
+
+// readRecord читает одну запись потока v2
+func readRecord(r io.Reader) (uint8, []byte, error) {
+	var head [5]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.LittleEndian.Uint32(head[1:5])
+	if size > maxRecordSize {
+		return 0, nil, fmt.Errorf("record too large: %d", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return head[0], body, nil
+}
+
+// readActionBody читает действие из записи известной длины: после payload может идти байт контроллера.
+func readActionBody(body []byte) (domain.ReplayAction, error) {
+	r := bytes.NewReader(body)
+	act, err := readAction(r)
+	if err != nil {
+		return act, err
+	}
+	if c, err := r.ReadByte(); err == nil {
+		act.Controller = domain.ControllerType(c)
+	}
+	return act, nil
+}
+
+// readAction читает одно действие (без байта контроллера: в v1 действия идут сплошным потоком).
+func readAction(r io.Reader) (domain.ReplayAction, error) {
+	var ah ActionHeader
+	if err := binary.Read(r, binary.LittleEndian, &ah); err != nil {
+		return domain.ReplayAction{}, err
+	}
+
+	act := domain.ReplayAction{
+		Tick:   int(ah.Tick),
+		Action: domain.ActionType(ah.ActionType),
+	}
+
+	tokenBuf := make([]byte, ah.TokenLen)
+	if _, err := io.ReadFull(r, tokenBuf); err != nil {
+		return domain.ReplayAction{}, err
+	}
+	act.Token = string(tokenBuf)
+
+	if ah.PayloadLen > 0 {
+		act.Payload = make([]byte, ah.PayloadLen)
+		if _, err := io.ReadFull(r, act.Payload); err != nil {
+			return domain.ReplayAction{}, err
+		}
+	} else {
+		act.Payload = json.RawMessage{}
+	}
+
+	return act, nil
 }
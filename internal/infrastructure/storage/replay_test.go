@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"bytes"
+	"cognitive-server/internal/domain"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"reflect"
+	"testing"
+)
+
+func TestReplay_V2RoundTrip(t *testing.T) {
+	session := &domain.ReplaySession{
+		LevelID:     3,
+		Seed:        1234,
+		Recipe:      "dungeon",
+		Timestamp:   99,
+		PlayerState: json.RawMessage(`{"id":"hero"}`),
+		Entities:    json.RawMessage(`[{"id":"e_1"}]`),
+		Controllers: map[string]domain.ControllerType{
+			"hero":   domain.ControllerClient,
+			"hero_2": domain.ControllerAI,
+		},
+		RngDraws:     41,
+		ActiveID:     "hero_2",
+		HashInterval: 2,
+		Checksums: []domain.ReplayChecksum{
+			{Action: 0, Hash: 0xdeadbeef},
+			{Action: 2, Hash: 0xcafe},
+		},
+		Keyframes: []domain.ReplayKeyframe{
+			{
+				Action:   2,
+				Tick:     -3,
+				RngDraws: 17,
+				Queue:    []domain.ReplayQueueEntry{{EntityID: "e_1", Priority: 2}, {EntityID: "hero", Priority: 4}},
+				Entities: json.RawMessage(`[{"id":"e_1"},{"id":"hero"}]`),
+			},
+		},
+		Actions: []domain.ReplayAction{
+			{Tick: 1, Token: "hero", Action: domain.ActionWait, Payload: json.RawMessage{}},
+			{Tick: 2, Token: "hero", Action: domain.ActionMove, Payload: json.RawMessage(`{"dx":1}`)},
+			{Tick: 3, Token: "hero", Action: domain.ActionWait, Payload: json.RawMessage{}, Controller: domain.ControllerTimeout},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeBinary(&buf, session); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	loaded, err := readBinary(&buf)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !reflect.DeepEqual(session, loaded) {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", loaded, session)
+	}
+}
+
+func TestReplay_ReadsV1(t *testing.T) {
+	var buf bytes.Buffer
+	state := []byte(`{"id":"hero"}`)
+	header := ReplayFileHeader{
+		Version:        Version1,
+		Seed:           7,
+		Timestamp:      5,
+		LevelID:        2,
+		PlayerStateLen: uint32(len(state)),
+		ActionCount:    1,
+	}
+	copy(header.Magic[:], MagicHeader)
+	_ = binary.Write(&buf, binary.LittleEndian, &header)
+	buf.Write(state)
+	_ = writeAction(&buf, domain.ReplayAction{Tick: 10, Token: "hero", Action: domain.ActionWait})
+
+	loaded, err := readBinary(&buf)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if loaded.Seed != 7 || loaded.LevelID != 2 || string(loaded.PlayerState) != string(state) {
+		t.Errorf("header not upgraded: %+v", loaded)
+	}
+	if len(loaded.Actions) != 1 || loaded.Actions[0].Tick != 10 {
+		t.Errorf("unexpected actions: %+v", loaded.Actions)
+	}
+	if loaded.Entities != nil || loaded.Checksums != nil {
+		t.Error("v1 replay should have no entity snapshot or checksums")
+	}
+}
+
+func testRun() *domain.RunReplay {
+	return &domain.RunReplay{
+		PlayerID:  "hero",
+		Timestamp: 42,
+		Segments: []*domain.ReplaySession{
+			{
+				LevelID:   0,
+				Seed:      10,
+				Recipe:    "surface",
+				Timestamp: 42,
+				Entities:  json.RawMessage(`[{"id":"hero"}]`),
+				Actions: []domain.ReplayAction{
+					{Tick: 1, Token: "hero", Action: domain.ActionMove, Payload: json.RawMessage(`{"dx":1}`)},
+				},
+				Exit: &domain.ReplayTransition{Action: 0, EntityID: "hero", ToLevel: 1, TargetID: "stairs_up_1"},
+			},
+			{
+				LevelID:   1,
+				Seed:      11,
+				Recipe:    "entry_dungeon",
+				Timestamp: 43,
+				Entities:  json.RawMessage(`[{"id":"hero"},{"id":"goblin_1"}]`),
+				Actions: []domain.ReplayAction{
+					{Tick: 5, Token: "hero", Action: domain.ActionWait, Payload: json.RawMessage{}},
+				},
+			},
+		},
+	}
+
+}
+
+// writeStream пишет забег в потоковом формате. closed=false - как при падении сервера.
+func writeStream(t *testing.T, run *domain.RunReplay, blockSize int, closed bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	rw, err := NewRunWriter(&buf, run.PlayerID, run.Timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw.BlockSize = blockSize
+	for _, segment := range run.Segments {
+		if err := rw.WriteSegment(segment); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	if closed {
+		if err := rw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestReplay_RunRoundTrip(t *testing.T) {
+	run := testRun()
+	loaded, err := readRun(bytes.NewReader(writeStream(t, run, DefaultReplayBlockSize, true)))
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !reflect.DeepEqual(run, loaded) {
+		t.Errorf("round trip mismatch:\n got %+v\nwant %+v", loaded, run)
+	}
+}
+
+func TestReplay_ReadsV3(t *testing.T) {
+	run := testRun()
+
+	var buf bytes.Buffer
+	header := RunHeaderV3{
+		Version:      Version3,
+		Timestamp:    run.Timestamp,
+		SegmentCount: uint16(len(run.Segments)),
+		PlayerLen:    uint16(len(run.PlayerID)),
+	}
+	copy(header.Magic[:], MagicHeader)
+	_ = binary.Write(&buf, binary.LittleEndian, &header)
+	buf.WriteString(run.PlayerID)
+	for _, segment := range run.Segments {
+		if err := writeBinary(&buf, segment); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := readRun(&buf)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !reflect.DeepEqual(run, loaded) {
+		t.Errorf("v3 mismatch:\n got %+v\nwant %+v", loaded, run)
+	}
+}
+
+func TestReplay_StreamRecoversTruncated(t *testing.T) {
+	run := testRun()
+	// Блок на каждую запись: обрыв в любом месте теряет не больше одной записи
+	data := writeStream(t, run, 1, false)
+
+	loaded, err := readRun(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !loaded.Truncated || len(loaded.Segments) != 2 {
+		t.Fatalf("file without index: truncated=%v segments=%d", loaded.Truncated, len(loaded.Segments))
+	}
+
+	// Обрыв посреди последнего блока: второй сегмент теряет End, но действие остается
+	loaded, err = readRun(bytes.NewReader(data[:len(data)-3]))
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !loaded.Truncated || len(loaded.Segments) != 2 || len(loaded.Segments[1].Actions) != 1 {
+		t.Errorf("cut file: truncated=%v segments=%d", loaded.Truncated, len(loaded.Segments))
+	}
+	if !reflect.DeepEqual(run.Segments[0], loaded.Segments[0]) {
+		t.Errorf("first segment damaged:\n got %+v\nwant %+v", loaded.Segments[0], run.Segments[0])
+	}
+}
+
+func TestReplay_StreamDetectsCorruption(t *testing.T) {
+	run := testRun()
+	data := writeStream(t, run, DefaultReplayBlockSize, true)
+
+	// Портим байт в сжатых данных последнего блока (второй сегмент)
+	firstBlock := bytes.Index(data, []byte(blockMagic))
+	lastBlock := bytes.LastIndex(data, []byte(blockMagic))
+	if firstBlock == lastBlock {
+		t.Fatal("expected a block per segment")
+	}
+	data[lastBlock+binary.Size(BlockHeader{})+2] ^= 0xFF
+
+	loaded, err := readRun(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !loaded.Truncated || len(loaded.Segments) != 1 {
+		t.Fatalf("corrupted block: truncated=%v segments=%d", loaded.Truncated, len(loaded.Segments))
+	}
+	if !reflect.DeepEqual(run.Segments[0], loaded.Segments[0]) {
+		t.Errorf("first segment damaged")
+	}
+}
+
+// Индекс с верным CRC, но не с той описью (сегмент короче, чем по индексу) - файл неполный
+func TestReplay_StreamChecksIndex(t *testing.T) {
+	run := testRun()
+	data := writeStream(t, run, DefaultReplayBlockSize, true)
+
+	at := bytes.LastIndex(data, []byte(indexMagic))
+	entriesAt := at + binary.Size(IndexHeader{})
+	entries := make([]SegmentIndexEntry, len(run.Segments))
+	if err := binary.Read(bytes.NewReader(data[entriesAt:]), binary.LittleEndian, entries); err != nil {
+		t.Fatal(err)
+	}
+	entries[1].Actions++
+
+	var index bytes.Buffer
+	_ = binary.Write(&index, binary.LittleEndian, entries)
+	copy(data[entriesAt:], index.Bytes())
+	crcAt := entriesAt + index.Len()
+	binary.LittleEndian.PutUint32(data[crcAt:], crc32.ChecksumIEEE(data[at:crcAt]))
+
+	loaded, err := readRun(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !loaded.Truncated || len(loaded.Segments) != 2 {
+		t.Errorf("index mismatch: truncated=%v segments=%d", loaded.Truncated, len(loaded.Segments))
+	}
+}
+
+func TestReplay_SingleLevelLoadsAsRun(t *testing.T) {
+	session := &domain.ReplaySession{
+		LevelID: 2,
+		Seed:    5,
+		Actions: []domain.ReplayAction{{Tick: 1, Token: "hero", Action: domain.ActionWait, Payload: json.RawMessage{}}},
+	}
+
+	var buf bytes.Buffer
+	if err := writeBinary(&buf, session); err != nil {
+		t.Fatal(err)
+	}
+	run, err := readRun(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.PlayerID != "hero" || len(run.Segments) != 1 || run.Segments[0].LevelID != 2 {
+		t.Errorf("unexpected run: %+v", run)
+	}
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"bufio"
+	"cognitive-server/internal/domain"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Параметры выборки по умолчанию
+const (
+	DefaultRunLimit = 20
+	MaxRunLimit     = 100
+)
+
+// Поля сортировки забегов
+const (
+	RunSortDepth       = "depth"
+	RunSortTicks       = "ticks"
+	RunSortKills       = "kills"
+	RunSortGold        = "gold"
+	RunSortDamageDealt = "damage_dealt"
+	RunSortDamageTaken = "damage_taken"
+	RunSortEndedAt     = "ended_at"
+)
+
+// ErrUnknownRunSort возвращается для неизвестного поля сортировки.
+var ErrUnknownRunSort = errors.New("unknown sort field")
+
+// RunQuery - параметры выборки забегов.
+type RunQuery struct {
+	PlayerID string // Пусто - все игроки
+	SortBy   string // Одно из RunSort*, по умолчанию depth
+	Asc      bool   // По умолчанию по убыванию
+	Offset   int
+	Limit    int // 0 - DefaultRunLimit
+}
+
+// RunPage - страница выборки.
+type RunPage struct {
+	Runs   []*domain.RunSummary `json:"runs"`
+	Total  int                  `json:"total"`
+	Offset int                  `json:"offset"`
+	Limit  int                  `json:"limit"`
+}
+
+// RunStore хранит историю завершенных забегов.
+type RunStore interface {
+	Add(run *domain.RunSummary) error
+	Query(q RunQuery) (RunPage, error)
+}
+
+var runSortKeys = map[string]func(r *domain.RunSummary) int64{
+	RunSortDepth:       func(r *domain.RunSummary) int64 { return int64(r.Depth) },
+	RunSortTicks:       func(r *domain.RunSummary) int64 { return int64(r.Ticks) },
+	RunSortKills:       func(r *domain.RunSummary) int64 { return int64(r.TotalKills) },
+	RunSortGold:        func(r *domain.RunSummary) int64 { return int64(r.Gold) },
+	RunSortDamageDealt: func(r *domain.RunSummary) int64 { return int64(r.DamageDealt) },
+	RunSortDamageTaken: func(r *domain.RunSummary) int64 { return int64(r.DamageTaken) },
+	RunSortEndedAt:     func(r *domain.RunSummary) int64 { return r.EndedAt },
+}
+
+// queryRuns фильтрует, сортирует и режет на страницы. Исходный слайс не меняется.
+func queryRuns(all []*domain.RunSummary, q RunQuery) (RunPage, error) {
+	if q.SortBy == "" {
+		q.SortBy = RunSortDepth
+	}
+	key, ok := runSortKeys[q.SortBy]
+	if !ok {
+		return RunPage{}, ErrUnknownRunSort
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultRunLimit
+	}
+	if q.Limit > MaxRunLimit {
+		q.Limit = MaxRunLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	var runs []*domain.RunSummary
+	for _, r := range all {
+		if q.PlayerID == "" || r.PlayerID == q.PlayerID {
+			runs = append(runs, r)
+		}
+	}
+
+	// При равенстве ключа более ранний забег выше: рекорд принадлежит тому, кто поставил его первым
+	sort.SliceStable(runs, func(a, b int) bool {
+		ka, kb := key(runs[a]), key(runs[b])
+		if ka != kb {
+			if q.Asc {
+				return ka < kb
+			}
+			return ka > kb
+		}
+		return runs[a].EndedAt < runs[b].EndedAt
+	})
+
+	page := RunPage{Runs: []*domain.RunSummary{}, Total: len(runs), Offset: q.Offset, Limit: q.Limit}
+	if q.Offset < len(runs) {
+		end := q.Offset + q.Limit
+		if end > len(runs) {
+			end = len(runs)
+		}
+		page.Runs = runs[q.Offset:end]
+	}
+	return page, nil
+}
+
+// --- MEMORY STORE ---
+
+// MemoryRunStore держит историю в памяти процесса (тесты, запуск без диска).
+type MemoryRunStore struct {
+	mu   sync.RWMutex
+	runs []*domain.RunSummary
+}
+
+func NewMemoryRunStore() *MemoryRunStore {
+	return &MemoryRunStore{}
+}
+
+func (s *MemoryRunStore) Add(run *domain.RunSummary) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs = append(s.runs, run)
+	return nil
+}
+
+func (s *MemoryRunStore) Query(q RunQuery) (RunPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return queryRuns(s.runs, q)
+}
+
+// --- FILE STORE ---
+
+// FileRunStore дописывает забеги в JSON Lines файл и держит индекс в памяти.
+// Записи неизменяемы, поэтому файл только растет.
+type FileRunStore struct {
+	Path string
+
+	mem MemoryRunStore
+	mu  sync.Mutex // Сериализует дозапись файла
+}
+
+func NewFileRunStore(dir string) (*FileRunStore, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		_ = os.MkdirAll(dir, 0755)
+	}
+	s := &FileRunStore{Path: filepath.Join(dir, "runs.jsonl")}
+
+	f, err := os.Open(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var run domain.RunSummary
+		// Битую строку (обрезанная запись при падении) пропускаем
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			continue
+		}
+		s.mem.runs = append(s.mem.runs, &run)
+	}
+	return s, scanner.Err()
+}
+
+func (s *FileRunStore) Add(run *domain.RunSummary) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return s.mem.Add(run)
+}
+
+func (s *FileRunStore) Query(q RunQuery) (RunPage, error) {
+	return s.mem.Query(q)
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"cognitive-server/internal/domain"
+	"testing"
+)
+
+func TestFileRunStore_QueryAfterReload(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileRunStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := []*domain.RunSummary{
+		{ID: "a_1", PlayerID: "a", Depth: 3, Gold: 10, EndedAt: 100},
+		{ID: "b_1", PlayerID: "b", Depth: 5, Gold: 5, EndedAt: 200},
+		{ID: "a_2", PlayerID: "a", Depth: 5, Gold: 70, EndedAt: 300},
+		{ID: "c_1", PlayerID: "c", Depth: 1, Gold: 1, EndedAt: 400},
+	}
+	for _, r := range runs {
+		if err := store.Add(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloaded, err := NewFileRunStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// По глубине: при равенстве выше тот, кто закончил раньше
+	page, err := reloaded.Query(RunQuery{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 4 || len(page.Runs) != 2 {
+		t.Fatalf("unexpected page: total %d, len %d", page.Total, len(page.Runs))
+	}
+	if page.Runs[0].ID != "b_1" || page.Runs[1].ID != "a_2" {
+		t.Errorf("unexpected order: %s, %s", page.Runs[0].ID, page.Runs[1].ID)
+	}
+
+	page, _ = reloaded.Query(RunQuery{PlayerID: "a", SortBy: RunSortGold, Asc: true})
+	if page.Total != 2 || page.Runs[0].ID != "a_1" {
+		t.Errorf("unexpected player page: %+v", page)
+	}
+
+	page, _ = reloaded.Query(RunQuery{Offset: 10})
+	if page.Total != 4 || len(page.Runs) != 0 {
+		t.Errorf("offset past the end should return empty page, got %d runs", len(page.Runs))
+	}
+
+	if _, err := reloaded.Query(RunQuery{SortBy: "hp"}); err != ErrUnknownRunSort {
+		t.Errorf("expected ErrUnknownRunSort, got %v", err)
+	}
+}
@@ -0,0 +1,475 @@
+package storage
+
+import (
+	"bytes"
+	"cognitive-server/internal/domain"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Version4 — потоковая запись забега. Файл пишется по ходу игры:
+//
+//	RunHeaderV4, PlayerLen байт ID игрока
+//	блоки: BlockHeader + DataLen байт deflate (сжатый поток сегментов v2, как в v3)
+//	индекс: IndexHeader + Count × SegmentIndexEntry + IndexTrailer (только у закрытого файла)
+//
+// Блок режется по границе записи и проверяется CRC32 несжатых данных. Если файл оборван
+// (падение сервера) или испорчен, читатель восстанавливает все до последнего целого блока.
+// Индекс - опись сегментов закрытого файла: с ней читатель сверяет то, что собрал из блоков.
+const Version4 uint32 = 4
+
+// DefaultReplayBlockSize - сколько несжатых данных копится до сброса блока на диск
+const DefaultReplayBlockSize = 64 << 10
+
+const (
+	blockMagic   = "CDBK"
+	indexMagic   = "CDIX"
+	trailerMagic = "CDFT"
+)
+
+// RunHeaderV4 — заголовок потоковой записи забега. Число сегментов заранее неизвестно.
+type RunHeaderV4 struct {
+	Magic     [4]byte
+	Version   uint32
+	Timestamp int64
+	PlayerLen uint16
+}
+
+// BlockHeader — заголовок сжатого блока
+type BlockHeader struct {
+	Magic   [4]byte
+	RawLen  uint32 // Размер несжатых данных
+	DataLen uint32 // Размер сжатых данных
+	CRC     uint32 // CRC32 (IEEE) несжатых данных
+}
+
+// IndexHeader — начало индекса в конце файла
+type IndexHeader struct {
+	Magic [4]byte
+	Count uint32
+}
+
+// SegmentIndexEntry — сегмент по описи: уровень и сколько в нем действий
+type SegmentIndexEntry struct {
+	LevelID int32
+	Actions uint32
+}
+
+// IndexTrailer — последние байты файла
+type IndexTrailer struct {
+	CRC   uint32 // CRC32 заголовка индекса и записей
+	Magic [4]byte
+}
+
+// RunWriter дописывает запись забега блоками по мере игры.
+// Сегмент открывается BeginSegment, наполняется действиями, хешами и кадрами и закрывается EndSegment.
+// Вызывается из горутин разных инстансов.
+type RunWriter struct {
+	mu sync.Mutex
+
+	w      io.Writer
+	closer io.Closer // Файл (nil, если пишем не в файл)
+
+	raw       bytes.Buffer // Несжатые данные следующего блока
+	zw        *flate.Writer
+	BlockSize int
+
+	index  []SegmentIndexEntry
+	open   bool // Сегмент начат и еще не закрыт
+	closed bool
+	err    error // Первая ошибка записи; после нее writer перестает писать
+}
+
+// CreateRun создает файл потоковой записи забега
+func (s *ReplayService) CreateRun(playerID string, timestamp int64) (*RunWriter, error) {
+	filename := fmt.Sprintf("run_%s_%d.cdrp", playerID, timestamp)
+	f, err := os.Create(filepath.Join(s.SaveDir, filename))
+	if err != nil {
+		return nil, err
+	}
+
+	rw, err := NewRunWriter(f, playerID, timestamp)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	rw.closer = f
+	return rw, nil
+}
+
+// NewRunWriter пишет заголовок забега в w и возвращает writer для сегментов
+func NewRunWriter(w io.Writer, playerID string, timestamp int64) (*RunWriter, error) {
+	player := []byte(playerID)
+	if len(player) > 65535 {
+		return nil, fmt.Errorf("player id too long: %d", len(player))
+	}
+
+	header := RunHeaderV4{
+		Version:   Version4,
+		Timestamp: timestamp,
+		PlayerLen: uint16(len(player)),
+	}
+	copy(header.Magic[:], MagicHeader)
+
+	var head bytes.Buffer
+	_ = binary.Write(&head, binary.LittleEndian, &header)
+	head.Write(player)
+	if _, err := w.Write(head.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+
+	zw, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+	return &RunWriter{
+		w:         w,
+		zw:        zw,
+		BlockSize: DefaultReplayBlockSize,
+	}, nil
+}
+
+// BeginSegment начинает сегмент: заголовок и снапшоты из s (действия s не пишутся)
+func (rw *RunWriter) BeginSegment(s *domain.ReplaySession) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if err := rw.usable(); err != nil {
+		return err
+	}
+	if rw.open {
+		return errors.New("previous segment is not finished")
+	}
+	rw.index = append(rw.index, SegmentIndexEntry{LevelID: int32(s.LevelID)})
+	rw.open = true
+	return rw.append(func(w io.Writer) error { return writeSegmentStart(w, s) })
+}
+
+// Action дописывает действие в открытый сегмент
+func (rw *RunWriter) Action(act domain.ReplayAction) error {
+	return rw.record(func(w io.Writer) error { return writeActionRecord(w, act) }, true)
+}
+
+// Checksum дописывает хеш состояния перед действием c.Action
+func (rw *RunWriter) Checksum(c domain.ReplayChecksum) error {
+	return rw.record(func(w io.Writer) error { return writeChecksumRecord(w, c) }, false)
+}
+
+// Keyframe дописывает кадр состояния перед действием kf.Action
+func (rw *RunWriter) Keyframe(kf *domain.ReplayKeyframe) error {
+	return rw.record(func(w io.Writer) error { return writeKeyframeRecord(w, kf) }, false)
+}
+
+// EndSegment закрывает сегмент и сбрасывает блок: законченный сегмент не теряется при падении
+func (rw *RunWriter) EndSegment(exit *domain.ReplayTransition) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if err := rw.usable(); err != nil {
+		return err
+	}
+	if !rw.open {
+		return errors.New("no segment is open")
+	}
+	if err := rw.append(func(w io.Writer) error { return writeSegmentEnd(w, exit) }); err != nil {
+		return err
+	}
+	rw.open = false
+	return rw.flushBlock()
+}
+
+// WriteSegment пишет целиком записанный в памяти сегмент
+func (rw *RunWriter) WriteSegment(s *domain.ReplaySession) error {
+	if err := rw.BeginSegment(&domain.ReplaySession{
+		LevelID: s.LevelID, Seed: s.Seed, Recipe: s.Recipe, Timestamp: s.Timestamp,
+		PlayerState: s.PlayerState, Entities: s.Entities, Controllers: s.Controllers,
+		HashInterval: s.HashInterval, RngDraws: s.RngDraws, ActiveID: s.ActiveID,
+	}); err != nil {
+		return err
+	}
+
+	checksums, keyframes := s.Checksums, s.Keyframes
+	for k := 0; k <= len(s.Actions); k++ {
+		for len(checksums) > 0 && checksums[0].Action <= k {
+			if err := rw.Checksum(checksums[0]); err != nil {
+				return err
+			}
+			checksums = checksums[1:]
+		}
+		for len(keyframes) > 0 && keyframes[0].Action <= k {
+			if err := rw.Keyframe(&keyframes[0]); err != nil {
+				return err
+			}
+			keyframes = keyframes[1:]
+		}
+		if k < len(s.Actions) {
+			if err := rw.Action(s.Actions[k]); err != nil {
+				return err
+			}
+		}
+	}
+	return rw.EndSegment(s.Exit)
+}
+
+// Segments - сколько сегментов начато в файле
+func (rw *RunWriter) Segments() int {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return len(rw.index)
+}
+
+// Close сбрасывает последний блок, дописывает индекс и закрывает файл.
+// Незаконченный сегмент закрывается без перехода: забег кончился посреди него.
+func (rw *RunWriter) Close() error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.closed {
+		return nil
+	}
+	rw.closed = true
+
+	err := rw.err
+	if err == nil && rw.open {
+		err = rw.append(func(w io.Writer) error { return writeSegmentEnd(w, nil) })
+		rw.open = false
+	}
+	if err == nil {
+		err = rw.flushBlock()
+	}
+	if err == nil {
+		err = rw.writeIndex()
+	}
+	if rw.closer != nil {
+		if cerr := rw.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (rw *RunWriter) usable() error {
+	if rw.closed {
+		return errors.New("run writer is closed")
+	}
+	return rw.err
+}
+
+// record дописывает запись в открытый сегмент. isAction - считать ли ее действием в индексе.
+func (rw *RunWriter) record(write func(io.Writer) error, isAction bool) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if err := rw.usable(); err != nil {
+		return err
+	}
+	if !rw.open {
+		return errors.New("no segment is open")
+	}
+	if isAction {
+		rw.index[len(rw.index)-1].Actions++
+	}
+	return rw.append(write)
+}
+
+// append кладет запись в буфер блока и сбрасывает блок, когда он набрался
+func (rw *RunWriter) append(write func(io.Writer) error) error {
+	if err := write(&rw.raw); err != nil {
+		return err // Ошибка кодирования (слишком длинные поля) не портит файл
+	}
+	if rw.raw.Len() >= rw.BlockSize {
+		return rw.flushBlock()
+	}
+	return nil
+}
+
+// flushBlock сжимает накопленные данные и пишет их одним блоком
+func (rw *RunWriter) flushBlock() error {
+	if rw.raw.Len() == 0 {
+		return nil
+	}
+
+	var data bytes.Buffer
+	rw.zw.Reset(&data)
+	if _, err := rw.zw.Write(rw.raw.Bytes()); err != nil {
+		return rw.fail(err)
+	}
+	if err := rw.zw.Close(); err != nil {
+		return rw.fail(err)
+	}
+
+	header := BlockHeader{
+		RawLen:  uint32(rw.raw.Len()),
+		DataLen: uint32(data.Len()),
+		CRC:     crc32.ChecksumIEEE(rw.raw.Bytes()),
+	}
+	copy(header.Magic[:], blockMagic)
+
+	var block bytes.Buffer
+	_ = binary.Write(&block, binary.LittleEndian, &header)
+	block.Write(data.Bytes())
+	if _, err := rw.w.Write(block.Bytes()); err != nil {
+		return rw.fail(err)
+	}
+
+	rw.raw.Reset()
+	return nil
+}
+
+// writeIndex дописывает индекс сегментов и трейлер
+func (rw *RunWriter) writeIndex() error {
+	header := IndexHeader{Count: uint32(len(rw.index))}
+	copy(header.Magic[:], indexMagic)
+
+	var index bytes.Buffer
+	_ = binary.Write(&index, binary.LittleEndian, &header)
+	_ = binary.Write(&index, binary.LittleEndian, rw.index)
+
+	trailer := IndexTrailer{CRC: crc32.ChecksumIEEE(index.Bytes())}
+	copy(trailer.Magic[:], trailerMagic)
+	_ = binary.Write(&index, binary.LittleEndian, &trailer)
+
+	if _, err := rw.w.Write(index.Bytes()); err != nil {
+		return rw.fail(err)
+	}
+	return nil
+}
+
+func (rw *RunWriter) fail(err error) error {
+	rw.err = err
+	return err
+}
+
+// --- Чтение ---
+
+// maxBlockSize защищает от огромной аллокации при битом заголовке блока
+const maxBlockSize = maxRecordSize + DefaultReplayBlockSize
+
+// readV4 читает потоковую запись забега. Блоки читаются до первого оборванного или битого;
+// сегменты собираются из всего, что прочиталось. Если чего-то не хватило (или собранное
+// не сходится с индексом), run.Truncated = true.
+func readV4(r io.Reader) (*domain.RunReplay, error) {
+	var header RunHeaderV4
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	player := make([]byte, header.PlayerLen)
+	if _, err := io.ReadFull(r, player); err != nil {
+		return nil, fmt.Errorf("failed to read player id: %w", err)
+	}
+
+	run := &domain.RunReplay{PlayerID: string(player), Timestamp: header.Timestamp}
+	raw, index, ok := readBlocks(r)
+	run.Truncated = !ok
+
+	stream := bytes.NewReader(raw)
+	for stream.Len() > 0 {
+		segment, err := readBinary(stream)
+		if err != nil {
+			// Оборванный сегмент без RecordEnd: берем действия, которые успели записаться
+			if segment != nil && len(segment.Actions) > 0 {
+				run.Segments = append(run.Segments, segment)
+			}
+			run.Truncated = true
+			break
+		}
+		run.Segments = append(run.Segments, segment)
+	}
+	if ok && !matchesIndex(run.Segments, index) {
+		run.Truncated = true
+	}
+	return run, nil
+}
+
+// matchesIndex сверяет собранные сегменты с описью из индекса
+func matchesIndex(segments []*domain.ReplaySession, index []SegmentIndexEntry) bool {
+	if len(segments) != len(index) {
+		return false
+	}
+	for k, entry := range index {
+		if int(entry.LevelID) != segments[k].LevelID || int(entry.Actions) != len(segments[k].Actions) {
+			return false
+		}
+	}
+	return true
+}
+
+// readBlocks читает и распаковывает блоки до индекса.
+// index - опись сегментов, ok - файл прочитан целиком вместе с целым индексом.
+func readBlocks(r io.Reader) (raw []byte, index []SegmentIndexEntry, ok bool) {
+	var out bytes.Buffer
+	zr := flate.NewReader(bytes.NewReader(nil))
+
+	for {
+		var magic [4]byte
+		if _, err := io.ReadFull(r, magic[:]); err != nil {
+			return out.Bytes(), nil, false // Файл оборван до индекса
+		}
+
+		switch string(magic[:]) {
+		case blockMagic:
+			var rest [12]byte
+			if _, err := io.ReadFull(r, rest[:]); err != nil {
+				return out.Bytes(), nil, false
+			}
+			rawLen := binary.LittleEndian.Uint32(rest[0:4])
+			dataLen := binary.LittleEndian.Uint32(rest[4:8])
+			sum := binary.LittleEndian.Uint32(rest[8:12])
+			if rawLen > maxBlockSize || dataLen > maxBlockSize {
+				return out.Bytes(), nil, false
+			}
+
+			data := make([]byte, dataLen)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return out.Bytes(), nil, false
+			}
+			if err := zr.(flate.Resetter).Reset(bytes.NewReader(data), nil); err != nil {
+				return out.Bytes(), nil, false
+			}
+			block := make([]byte, rawLen)
+			if _, err := io.ReadFull(zr, block); err != nil || crc32.ChecksumIEEE(block) != sum {
+				return out.Bytes(), nil, false
+			}
+			out.Write(block)
+
+		case indexMagic:
+			index, valid := readIndex(r, magic)
+			return out.Bytes(), index, valid
+
+		default:
+			return out.Bytes(), nil, false
+		}
+	}
+}
+
+// readIndex читает индекс после его magic и сверяет CRC
+func readIndex(r io.Reader, magic [4]byte) ([]SegmentIndexEntry, bool) {
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, false
+	}
+	if count > maxRecordSize/uint32(binary.Size(SegmentIndexEntry{})) {
+		return nil, false
+	}
+
+	entries := make([]SegmentIndexEntry, count)
+	if err := binary.Read(r, binary.LittleEndian, entries); err != nil {
+		return nil, false
+	}
+	var trailer IndexTrailer
+	if err := binary.Read(r, binary.LittleEndian, &trailer); err != nil || string(trailer.Magic[:]) != trailerMagic {
+		return nil, false
+	}
+
+	var index bytes.Buffer
+	_ = binary.Write(&index, binary.LittleEndian, &IndexHeader{Magic: magic, Count: count})
+	_ = binary.Write(&index, binary.LittleEndian, entries)
+	if crc32.ChecksumIEEE(index.Bytes()) != trailer.CRC {
+		return nil, false
+	}
+	return entries, true
+}
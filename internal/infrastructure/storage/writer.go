@@ -1,20 +1,26 @@
 package storage
 
 import (
+	"bufio"
+	"bytes"
 	"cognitive-server/internal/domain"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 )
 
 const (
 	MagicHeader string = `CDRP` // 4 байта
 	Version1    uint32 = 1      // 4 байта
+	Version2    uint32 = 2
+	Version3    uint32 = 3 // Забег: заголовок RunHeaderV3 и сегменты в формате v2
+	// Version4 - потоковый забег из сжатых блоков, см. stream.go
 )
 
-// ReplayFileHeader — это точное представление заголовка файла в памяти.
+// ReplayFileHeader — это точное представление заголовка файла v1 в памяти.
 // binary.Write умеет писать это целиком, так как тут нет слайсов и строк, только массивы и числа.
 type ReplayFileHeader struct {
 	Magic          [4]byte // 4 байта
@@ -26,6 +32,42 @@ type ReplayFileHeader struct {
 	ActionCount    int32   // 4 байта
 }
 
+// ReplayHeaderV2 — заголовок формата v2.
+// За ним идут RecipeLen байт рецепта уровня и поток записей [tag uint8][len uint32][body],
+// завершающийся RecordEnd. Незнакомые записи читатель пропускает.
+type ReplayHeaderV2 struct {
+	Magic        [4]byte
+	Version      uint32
+	Seed         int64
+	Timestamp    int64
+	LevelID      int32
+	HashInterval uint16
+	RecipeLen    uint16
+}
+
+// RunHeaderV3 — заголовок записи забега.
+// За ним идут PlayerLen байт ID игрока и SegmentCount сегментов, каждый - полноценная запись v2.
+type RunHeaderV3 struct {
+	Magic        [4]byte
+	Version      uint32
+	Timestamp    int64
+	SegmentCount uint16
+	PlayerLen    uint16
+}
+
+// Типы записей потока v2
+const (
+	RecordEnd         uint8 = 0
+	RecordPlayerState uint8 = 1 // JSON снапшот игрока
+	RecordEntities    uint8 = 2 // JSON снапшот всех сущностей на старте записи
+	RecordAction      uint8 = 3 // ActionHeader + токен + payload [+ controller uint8]
+	RecordChecksum    uint8 = 4 // [action uint32][hash uint64]
+	RecordTransition  uint8 = 5 // [action uint32][toLevel int32][entityLen uint16][targetLen uint16][entity][target]
+	RecordControllers uint8 = 6 // Повторяется: [idLen uint16][id][controller uint8]
+	RecordKeyframe    uint8 = 7 // [action uint32][tick int64][rngDraws uint64][queueLen uint32][queue...][entities JSON]
+	RecordStartState  uint8 = 8 // [rngDraws uint64][activeLen uint16][active]
+)
+
 // ActionHeader — заголовок каждой записи действия.
 type ActionHeader struct {
 	Tick       int32  // 4
@@ -39,8 +81,8 @@ type ReplayService struct {
 }
 
 func NewReplayService(dir string) *ReplayService {
-	// Создаем папку если нет
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
+	// Создаем папку если нет (пустой путь - текущая папка)
+	if _, err := os.Stat(dir); dir != "" && os.IsNotExist(err) {
 		_ = os.Mkdir(dir, 0755)
 	}
 	return &ReplayService{SaveDir: dir}
@@ -56,68 +98,290 @@ func (s *ReplayService) Save(session *domain.ReplaySession) error {
 	}
 	defer f.Close()
 
-	// Используем буферизацию для скорости, если записей много,
-	// но для простоты пишем напрямую.
-	return writeBinary(f, session)
+	// Записи мелкие, поэтому буферизуем
+	w := bufio.NewWriter(f)
+	if err := writeBinary(w, session); err != nil {
+		return err
+	}
+	return w.Flush()
 }
 
 func writeBinary(w io.Writer, s *domain.ReplaySession) error {
-	stateBytes := []byte(s.PlayerState)
+	if err := writeSegmentStart(w, s); err != nil {
+		return err
+	}
+
+	// Actions вперемешку с хешами и кадрами: они идут перед действием, к которому относятся
+	checksums := s.Checksums
+	keyframes := s.Keyframes
+	writeCheckpoints := func(index int) error {
+		for len(checksums) > 0 && checksums[0].Action <= index {
+			if err := writeChecksumRecord(w, checksums[0]); err != nil {
+				return err
+			}
+			checksums = checksums[1:]
+		}
+		for len(keyframes) > 0 && keyframes[0].Action <= index {
+			if err := writeKeyframeRecord(w, &keyframes[0]); err != nil {
+				return err
+			}
+			keyframes = keyframes[1:]
+		}
+		return nil
+	}
+
+	for k, act := range s.Actions {
+		if err := writeCheckpoints(k); err != nil {
+			return err
+		}
+		if err := writeActionRecord(w, act); err != nil {
+			return err
+		}
+	}
+	if err := writeCheckpoints(len(s.Actions)); err != nil {
+		return err
+	}
+
+	return writeSegmentEnd(w, s.Exit)
+}
+
+// writeSegmentStart пишет заголовок v2 и снапшоты сегмента: все, что известно до первого действия.
+func writeSegmentStart(w io.Writer, s *domain.ReplaySession) error {
+	recipe := []byte(s.Recipe)
+	if len(recipe) > 65535 {
+		return fmt.Errorf("recipe too long: %d", len(recipe))
+	}
 
 	// 1. Заполняем и пишем заголовок
-	header := ReplayFileHeader{
-		Version:        Version1,
-		Seed:           s.Seed,
-		Timestamp:      s.Timestamp,
-		LevelID:        int32(s.LevelID),
-		PlayerStateLen: uint32(len(stateBytes)),
-		ActionCount:    int32(len(s.Actions)),
+	header := ReplayHeaderV2{
+		Version:      Version2,
+		Seed:         s.Seed,
+		Timestamp:    s.Timestamp,
+		LevelID:      int32(s.LevelID),
+		HashInterval: uint16(s.HashInterval),
+		RecipeLen:    uint16(len(recipe)),
 	}
 	copy(header.Magic[:], MagicHeader)
 
 	if err := binary.Write(w, binary.LittleEndian, &header); err != nil {
 		return fmt.Errorf("failed to write header: %w", err)
 	}
+	if _, err := w.Write(recipe); err != nil {
+		return fmt.Errorf("failed to write recipe: %w", err)
+	}
 
-	// 2. Пишем Player Snapshot (если есть)
-	if len(stateBytes) > 0 {
-		if _, err := w.Write(stateBytes); err != nil {
+	// 2. Снапшоты (если есть)
+	if len(s.PlayerState) > 0 {
+		if err := writeRecord(w, RecordPlayerState, s.PlayerState); err != nil {
 			return fmt.Errorf("failed to write player state: %w", err)
 		}
 	}
-
-	// 3. Пишем Actions
-	for _, act := range s.Actions {
-		tokenBytes := []byte(act.Token)
-		if len(tokenBytes) > 255 {
-			return fmt.Errorf("token too long: %d", len(tokenBytes))
+	if len(s.Entities) > 0 {
+		if err := writeRecord(w, RecordEntities, s.Entities); err != nil {
+			return fmt.Errorf("failed to write entities: %w", err)
+		}
+	}
+	if s.Controllers != nil {
+		var body bytes.Buffer
+		if err := writeControllers(&body, s.Controllers); err != nil {
+			return err
+		}
+		if err := writeRecord(w, RecordControllers, body.Bytes()); err != nil {
+			return fmt.Errorf("failed to write controllers: %w", err)
+		}
+	}
+	if s.RngDraws > 0 || s.ActiveID != "" {
+		body := make([]byte, 10, 10+len(s.ActiveID))
+		binary.LittleEndian.PutUint64(body[0:8], s.RngDraws)
+		binary.LittleEndian.PutUint16(body[8:10], uint16(len(s.ActiveID)))
+		body = append(body, s.ActiveID...)
+		if err := writeRecord(w, RecordStartState, body); err != nil {
+			return fmt.Errorf("failed to write start state: %w", err)
 		}
+	}
+	return nil
+}
 
-		payloadLen := len(act.Payload)
-		if payloadLen > 65535 {
-			return fmt.Errorf("payload too long: %d", payloadLen)
+// writeSegmentEnd закрывает сегмент: переход на другой уровень (если был) и RecordEnd
+func writeSegmentEnd(w io.Writer, exit *domain.ReplayTransition) error {
+	if exit != nil {
+		var body bytes.Buffer
+		if err := writeTransition(&body, exit); err != nil {
+			return err
+		}
+		if err := writeRecord(w, RecordTransition, body.Bytes()); err != nil {
+			return err
 		}
+	}
+	return writeRecord(w, RecordEnd, nil)
+}
+
+func writeActionRecord(w io.Writer, act domain.ReplayAction) error {
+	var body bytes.Buffer
+	if err := writeAction(&body, act); err != nil {
+		return err
+	}
+	return writeRecord(w, RecordAction, body.Bytes())
+}
+
+func writeChecksumRecord(w io.Writer, c domain.ReplayChecksum) error {
+	var buf [12]byte
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(c.Action))
+	binary.LittleEndian.PutUint64(buf[4:12], c.Hash)
+	return writeRecord(w, RecordChecksum, buf[:])
+}
+
+func writeKeyframeRecord(w io.Writer, kf *domain.ReplayKeyframe) error {
+	var body bytes.Buffer
+	if err := writeKeyframe(&body, kf); err != nil {
+		return err
+	}
+	return writeRecord(w, RecordKeyframe, body.Bytes())
+}
 
-		actHeader := ActionHeader{
-			Tick:       int32(act.Tick),
-			ActionType: uint8(act.Action),
-			TokenLen:   uint8(len(tokenBytes)),
-			PayloadLen: uint16(payloadLen),
+// SaveRun сохраняет записанный в памяти забег одним файлом (в потоковом формате)
+func (s *ReplayService) SaveRun(run *domain.RunReplay) error {
+	rw, err := s.CreateRun(run.PlayerID, run.Timestamp)
+	if err != nil {
+		return err
+	}
+	for k, segment := range run.Segments {
+		if err := rw.WriteSegment(segment); err != nil {
+			rw.Close()
+			return fmt.Errorf("segment %d: %w", k, err)
 		}
+	}
+	return rw.Close()
+}
 
-		if err := binary.Write(w, binary.LittleEndian, &actHeader); err != nil {
+// writeKeyframe пишет тело записи RecordKeyframe
+func writeKeyframe(w io.Writer, kf *domain.ReplayKeyframe) error {
+	var head [24]byte
+	binary.LittleEndian.PutUint32(head[0:4], uint32(kf.Action))
+	binary.LittleEndian.PutUint64(head[4:12], uint64(int64(kf.Tick)))
+	binary.LittleEndian.PutUint64(head[12:20], kf.RngDraws)
+	binary.LittleEndian.PutUint32(head[20:24], uint32(len(kf.Queue)))
+	if _, err := w.Write(head[:]); err != nil {
+		return err
+	}
+
+	for _, q := range kf.Queue {
+		if len(q.EntityID) > 65535 {
+			return fmt.Errorf("entity id too long: %d", len(q.EntityID))
+		}
+		var item [10]byte
+		binary.LittleEndian.PutUint16(item[0:2], uint16(len(q.EntityID)))
+		binary.LittleEndian.PutUint64(item[2:10], uint64(int64(q.Priority)))
+		if _, err := w.Write(item[:2]); err != nil {
 			return err
 		}
-
-		if _, err := w.Write(tokenBytes); err != nil {
+		if _, err := io.WriteString(w, q.EntityID); err != nil {
 			return err
 		}
-		if payloadLen > 0 {
-			if _, err := w.Write(act.Payload); err != nil {
-				return err
-			}
+		if _, err := w.Write(item[2:]); err != nil {
+			return err
 		}
 	}
 
+	// Сущности занимают остаток записи
+	_, err := w.Write(kf.Entities)
+	return err
+}
+
+// writeControllers пишет тело записи RecordControllers (по возрастанию ID, чтобы файл был стабильным)
+func writeControllers(w io.Writer, controllers map[string]domain.ControllerType) error {
+	ids := make([]string, 0, len(controllers))
+	for id := range controllers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if len(id) > 65535 {
+			return fmt.Errorf("entity id too long: %d", len(id))
+		}
+		var head [2]byte
+		binary.LittleEndian.PutUint16(head[:], uint16(len(id)))
+		if _, err := w.Write(head[:]); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, id); err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte{uint8(controllers[id])}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// writeTransition пишет тело записи RecordTransition
+func writeTransition(w io.Writer, t *domain.ReplayTransition) error {
+	entity, target := []byte(t.EntityID), []byte(t.TargetID)
+	if len(entity) > 65535 || len(target) > 65535 {
+		return fmt.Errorf("transition ids too long")
+	}
+
+	var head [12]byte
+	binary.LittleEndian.PutUint32(head[0:4], uint32(t.Action))
+	binary.LittleEndian.PutUint32(head[4:8], uint32(int32(t.ToLevel)))
+	binary.LittleEndian.PutUint16(head[8:10], uint16(len(entity)))
+	binary.LittleEndian.PutUint16(head[10:12], uint16(len(target)))
+	if _, err := w.Write(head[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(entity); err != nil {
+		return err
+	}
+	_, err := w.Write(target)
+	return err
+}
+
+// writeRecord пишет одну запись потока v2
+func writeRecord(w io.Writer, tag uint8, body []byte) error {
+	var head [5]byte
+	head[0] = tag
+	binary.LittleEndian.PutUint32(head[1:5], uint32(len(body)))
+	if _, err := w.Write(head[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// writeAction пишет одно действие: ActionHeader, токен, payload и контроллер.
+// Действие всегда лежит в записи известной длины, поэтому байт контроллера в конце
+// не ломает старых читателей, а его отсутствие означает ControllerClient.
+func writeAction(w io.Writer, act domain.ReplayAction) error {
+	tokenBytes := []byte(act.Token)
+	if len(tokenBytes) > 255 {
+		return fmt.Errorf("token too long: %d", len(tokenBytes))
+	}
+
+	payloadLen := len(act.Payload)
+	if payloadLen > 65535 {
+		return fmt.Errorf("payload too long: %d", payloadLen)
+	}
+
+	actHeader := ActionHeader{
+		Tick:       int32(act.Tick),
+		ActionType: uint8(act.Action),
+		TokenLen:   uint8(len(tokenBytes)),
+		PayloadLen: uint16(payloadLen),
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, &actHeader); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(tokenBytes); err != nil {
+		return err
+	}
+	if payloadLen > 0 {
+		if _, err := w.Write(act.Payload); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{uint8(act.Controller)})
+	return err
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"cognitive-server/internal/engine"
+	"cognitive-server/pkg/dungeon"
+	"cognitive-server/pkg/logger"
+	"crypto/subtle"
+	"net/http"
+)
+
+// AdminHandler - служебные операции над работающим сервером
+type AdminHandler struct {
+	Service *engine.GameService
+}
+
+func NewAdminHandler(s *engine.GameService) *AdminHandler {
+	return &AdminHandler{Service: s}
+}
+
+// RegisterRoutes регистрирует admin-эндпоинты, если в конфиге задан AdminToken.
+// Без токена эндпоинтов нет: перезагрузка контента меняет спавны всего сервера.
+func (h *AdminHandler) RegisterRoutes(mux *http.ServeMux) {
+	if h.Service.Config.AdminToken == "" {
+		logger.Log.Info("Admin endpoints disabled (no admin token)")
+		return
+	}
+	mux.HandleFunc("/admin/content/reload", h.requireToken(h.handleReloadContent))
+}
+
+// requireToken пропускает только запросы с Authorization: Bearer <AdminToken>
+func (h *AdminHandler) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	want := []byte("Bearer " + h.Service.Config.AdminToken)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "admin token required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ContentSummary - сколько шаблонов в загруженном контенте
+type ContentSummary struct {
+	Enemies       int `json:"enemies"`
+	NPCs          int `json:"npcs"`
+	Items         int `json:"items"`
+	SentientItems int `json:"sentient_items"`
+}
+
+// POST /admin/content/reload - перечитать паки из ContentDir.
+// Уже заспавненные сущности остаются как были, новые спавны берут новые шаблоны.
+// При ошибке остается прежний контент, а в ответе 422 - список всех проблем.
+func (h *AdminHandler) handleReloadContent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "POST required", http.StatusMethodNotAllowed)
+		return
+	}
+
+	dir := h.Service.Config.ContentDir
+	content, err := dungeon.ReloadContent(dir)
+	if err != nil {
+		logger.Log.Warnf("Content reload from %s failed:\n%v", dir, err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	logger.Log.Infof("📦 Content reloaded from %s", dir)
+	writeJSON(w, ContentSummary{
+		Enemies:       len(content.Enemies),
+		NPCs:          len(content.NPCs),
+		Items:         len(content.Items),
+		SentientItems: len(content.SentientItems),
+	})
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"cognitive-server/internal/engine"
+	"cognitive-server/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func adminStatus(t *testing.T, token, auth string) int {
+	t.Helper()
+	logger.Init()
+	logger.Log.SetLevel(logrus.FatalLevel)
+
+	service := engine.NewPlaybackService()
+	service.Config.AdminToken = token
+	service.Config.ContentDir = t.TempDir() // Пустая папка - только встроенный контент
+	mux := http.NewServeMux()
+	NewAdminHandler(service).RegisterRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/content/reload", nil)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+// Без токена в конфиге эндпоинта нет, с токеном - только с верным заголовком
+func TestAdmin_ReloadRequiresToken(t *testing.T) {
+	cases := []struct {
+		name, token, auth string
+		want              int
+	}{
+		{"disabled", "", "Bearer ", http.StatusNotFound},
+		{"no header", "s3cret", "", http.StatusUnauthorized},
+		{"wrong token", "s3cret", "Bearer nope", http.StatusUnauthorized},
+		{"valid token", "s3cret", "Bearer s3cret", http.StatusOK},
+	}
+	for _, c := range cases {
+		if got := adminStatus(t, c.token, c.auth); got != c.want {
+			t.Errorf("%s: status %d, want %d", c.name, got, c.want)
+		}
+	}
+}
@@ -47,6 +47,10 @@ func NewClient(game *engine.GameService, conn *websocket.Conn) *Client {
 
 // readPump читает команды от клиента
 func (c *Client) readPump() {
+	// Сущность, которой управляет клиент. Держим ссылку сами:
+	// GetEntity не находит игроков на уровнях, созданных на лету.
+	var ent *domain.Entity
+
 	defer func() {
 		c.Game.Hub.Unregister(c.EntityID)
 		if err := c.Conn.Close(); err != nil {
@@ -54,7 +58,7 @@ func (c *Client) readPump() {
 		}
 		// Освобождаем сущность, чтобы AI мог перехватить управление (если захотим)
 		// или просто чтобы пометить, что игрок оффлайн
-		if ent := c.Game.GetEntity(c.EntityID); ent != nil {
+		if ent != nil {
 			ent.ControllerID = ""
 			logger.Log.WithField("entity_id", c.EntityID).Info("Client disconnected")
 			// Сообщаем движку, что игрок ушел, чтобы прервать его ход немедленно
@@ -90,7 +94,7 @@ func (c *Client) readPump() {
 	}
 
 	// 2. ПОИСК ИЛИ СОЗДАНИЕ ИГРОКА
-	ent := c.Game.GetEntity(c.EntityID)
+	ent = c.Game.GetEntity(c.EntityID)
 	if ent == nil {
 		logger.Log.Infof("Player %s not found. Spawning...", c.EntityID)
 		// Сид зависит только от имени игрока.
@@ -101,11 +105,14 @@ func (c *Client) readPump() {
 
 		newPlayer := dungeon.CreatePlayer(c.EntityID, playerRng)
 
+		// Персонаж уже играл раньше: возвращаем его статы, вещи и уровень.
+		// Позицию на уровне проверит движок при добавлении.
+		placed := c.Game.RestoreCharacter(newPlayer)
+
 		// Ищем место для спавна на уровне 0
-		world := c.Game.Worlds[0]
-		placed := false
+		world := c.Game.Levels.World(0)
 		// Сканируем центр карты
-		for y := 10; y < 20; y++ {
+		for y := 10; y < 20 && !placed; y++ {
 			for x := 15; x < 25; x++ {
 				if !world.Map[y][x].IsWall && len(world.GetEntitiesAt(x, y)) == 0 {
 					newPlayer.Pos = domain.Position{X: x, Y: y}
@@ -37,9 +37,9 @@ func (h *DebugHandler) handleListWorlds(w http.ResponseWriter, r *http.Request)
 
 	// Итерируемся по INSTANCES, так как именно они содержат актуальное состояние игры.
 	// Динамически созданные уровни живут здесь.
-	for id, instance := range h.Service.Instances {
+	for _, instance := range h.Service.Levels.All() {
 		summary = append(summary, WorldSummary{
-			LevelID:     id,
+			LevelID:     instance.ID,
 			Width:       instance.World.Width,
 			Height:      instance.World.Height,
 			EntityCount: len(instance.Entities),
@@ -56,7 +56,7 @@ func (h *DebugHandler) handleDumpEntities(w http.ResponseWriter, r *http.Request
 	var levelID int
 	fmt.Sscanf(levelStr, "%d", &levelID)
 
-	instance, ok := h.Service.Instances[levelID]
+	instance, ok := h.Service.Levels.Get(levelID)
 	if !ok {
 		http.Error(w, "Instance not found or not active", http.StatusNotFound)
 		return
@@ -72,7 +72,7 @@ func (h *DebugHandler) handleTurnQueue(w http.ResponseWriter, r *http.Request) {
 	var levelID int
 	fmt.Sscanf(levelStr, "%d", &levelID)
 
-	instance, ok := h.Service.Instances[levelID]
+	instance, ok := h.Service.Levels.Get(levelID)
 	if !ok {
 		http.Error(w, "Instance not found", http.StatusNotFound)
 		return
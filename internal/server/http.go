@@ -27,6 +27,7 @@ func (s *Server) Run() error {
 
 	// Регистрируем роуты
 	mux.HandleFunc("/ws", enableCORS(s.handleWS))
+	mux.HandleFunc("/ws/replay", enableCORS(s.handleReplayWS))
 	mux.HandleFunc("/health", enableCORS(s.handleHealth))
 	mux.HandleFunc("/version", enableCORS(s.handleVersion))
 
@@ -34,6 +35,12 @@ func (s *Server) Run() error {
 	debugHandler := NewDebugHandler(s.Engine)
 	debugHandler.RegisterRoutes(mux)
 
+	statsHandler := NewStatsHandler(s.Engine)
+	statsHandler.RegisterRoutes(mux)
+
+	adminHandler := NewAdminHandler(s.Engine)
+	adminHandler.RegisterRoutes(mux)
+
 	logger.Log.Infof("🛡️  Cognitive Dungeon Server running on :%s", s.Port)
 	return http.ListenAndServe(":"+s.Port, mux)
 }
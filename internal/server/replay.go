@@ -0,0 +1,292 @@
+package server
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/internal/engine"
+	"cognitive-server/pkg/api"
+	"cognitive-server/pkg/logger"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// Настройки проигрывания реплеев для зрителей
+const (
+	replayTickDuration = 2 * time.Millisecond // Реальное время одного тика на скорости 1x (шаг ~200мс)
+	replayMaxDelay     = time.Second          // Дольше между кадрами не ждем (переходы, долгие ожидания)
+	replayMinSpeed     = 0.1
+	replayMaxSpeed     = 64
+)
+
+// Команды зрителя реплея
+const (
+	replayActionPlay  = "PLAY"
+	replayActionPause = "PAUSE"
+	replayActionStep  = "STEP"
+	replayActionSeek  = "SEEK"  // Payload: ReplaySeekPayload
+	replayActionSpeed = "SPEED" // Payload: ReplaySpeedPayload
+	replayActionView  = "VIEW"  // Payload: EntityPayload (пустой targetId - всевидящий)
+)
+
+// ReplayViewer - зритель реплея по WebSocket.
+// Симуляцией и отправкой кадров владеет одна горутина (run), readPump только передает команды.
+type ReplayViewer struct {
+	Conn   *websocket.Conn
+	Cursor *engine.ReplayCursor
+
+	EntityID string // Чьими глазами смотрим ("" - всевидящий)
+	Speed    float64
+	Paused   bool
+
+	commands chan api.ClientCommand
+	done     chan struct{} // Закрывается, когда run завершился
+	timer    *time.Timer
+}
+
+func NewReplayViewer(conn *websocket.Conn, cursor *engine.ReplayCursor) *ReplayViewer {
+	return &ReplayViewer{
+		Conn:     conn,
+		Cursor:   cursor,
+		EntityID: cursor.PlayerID(),
+		Speed:    1,
+		commands: make(chan api.ClientCommand, 16),
+		done:     make(chan struct{}),
+	}
+}
+
+// handleReplayWS - /ws/replay?file=run_hero_1_1700000000.cdrp[&entity=hero_1][&speed=2][&paused=1]
+// Реплей берется только из папки реплеев сервера. Без entity смотрим глазами владельца забега,
+// с пустым entity - всевидящим наблюдателем.
+func (s *Server) handleReplayWS(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	name := query.Get("file")
+	if name == "" || name != filepath.Base(name) || !strings.HasSuffix(name, ".cdrp") {
+		http.Error(w, "file must be a .cdrp file name from the replay directory", http.StatusBadRequest)
+		return
+	}
+
+	cursor, err := engine.OpenReplay(filepath.Join(s.Engine.Config.ReplayDir, name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.Error(w, "replay not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		}
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Log.Error("Upgrade error:", err)
+		return
+	}
+
+	viewer := NewReplayViewer(conn, cursor)
+	if query.Has("entity") {
+		viewer.EntityID = query.Get("entity")
+	}
+	if speed, err := strconv.ParseFloat(query.Get("speed"), 64); err == nil {
+		viewer.setSpeed(speed)
+	}
+	viewer.Paused = query.Get("paused") == "1"
+
+	logger.Log.WithFields(logrus.Fields{
+		"file":   name,
+		"entity": viewer.EntityID,
+	}).Info("Replay viewer connected")
+
+	go viewer.run()
+	go viewer.readPump()
+}
+
+// readPump читает команды управления и передает их в run
+func (v *ReplayViewer) readPump() {
+	defer close(v.commands)
+
+	v.Conn.SetReadLimit(maxMessageSize)
+	if err := v.Conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+		logger.Log.WithError(err).Warn("failed to set read deadline")
+	}
+	v.Conn.SetPongHandler(func(string) error {
+		return v.Conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var cmd api.ClientCommand
+		if err := v.Conn.ReadJSON(&cmd); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				logger.Log.Errorf("Replay WS Error: %v", err)
+			}
+			return
+		}
+		select {
+		case v.commands <- cmd:
+		case <-v.done:
+			return
+		}
+	}
+}
+
+// run проигрывает реплей в темпе реального времени и отправляет кадры
+func (v *ReplayViewer) run() {
+	ticker := time.NewTicker(pingPeriod)
+	v.timer = time.NewTimer(0)
+	defer func() {
+		close(v.done)
+		ticker.Stop()
+		v.timer.Stop()
+		if err := v.Conn.Close(); err != nil {
+			logger.Log.WithError(err).Debug("failed to close replay websocket connection")
+		}
+		logger.Log.Info("Replay viewer disconnected")
+	}()
+
+	// Первый кадр - состояние до первого действия
+	if !v.send() {
+		return
+	}
+
+	for {
+		select {
+		case cmd, ok := <-v.commands:
+			if !ok {
+				return
+			}
+			v.apply(cmd)
+			if !v.send() {
+				return
+			}
+
+		case <-v.timer.C:
+			if v.Paused {
+				continue // Таймер снова заведет PLAY
+			}
+			before := v.Cursor.Position()
+			if !v.Cursor.Next() {
+				v.send() // Последний кадр с отметкой о конце
+				continue
+			}
+			if !v.send() {
+				return
+			}
+			v.timer.Reset(v.delay(before, v.Cursor.Position()))
+
+		case <-ticker.C:
+			if err := v.Conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+				logger.Log.WithError(err).Warn("failed to set ping write deadline")
+			}
+			if err := v.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				logger.Log.WithError(err).Debug("ping failed")
+				return
+			}
+		}
+	}
+}
+
+// apply выполняет команду зрителя
+func (v *ReplayViewer) apply(cmd api.ClientCommand) {
+	switch cmd.Action {
+	case replayActionPlay:
+		v.Paused = false
+		v.timer.Reset(0)
+
+	case replayActionPause:
+		v.Paused = true
+
+	case replayActionStep:
+		v.Paused = true
+		v.Cursor.Next()
+
+	case replayActionSeek:
+		var p api.ReplaySeekPayload
+		if err := json.Unmarshal(cmd.Payload, &p); err != nil {
+			return
+		}
+		var err error
+		if p.Tick > 0 {
+			err = v.Cursor.SeekTick(p.Tick)
+		} else {
+			err = v.Cursor.Seek(p.Step)
+		}
+		if err != nil {
+			logger.Log.WithError(err).Error("Replay seek failed")
+		}
+		// На конце реплея таймер не заведен: без этого перемотка назад не продолжит показ
+		v.resume()
+
+	case replayActionSpeed:
+		var p api.ReplaySpeedPayload
+		if err := json.Unmarshal(cmd.Payload, &p); err != nil {
+			return
+		}
+		v.setSpeed(p.Speed)
+		v.resume() // Отложенный кадр ждет с прежней скоростью
+
+	case replayActionView:
+		var p api.EntityPayload
+		if err := json.Unmarshal(cmd.Payload, &p); err != nil {
+			return
+		}
+		v.EntityID = p.TargetID
+
+	default:
+		logger.Log.WithField("action", cmd.Action).Debug("Unknown replay command")
+	}
+}
+
+// resume заново заводит таймер, если реплей не на паузе
+func (v *ReplayViewer) resume() {
+	if !v.Paused {
+		v.timer.Reset(0)
+	}
+}
+
+func (v *ReplayViewer) setSpeed(speed float64) {
+	v.Speed = max(replayMinSpeed, min(speed, replayMaxSpeed))
+}
+
+// delay - сколько показывать кадр: столько, сколько игровых тиков занял ход, с учетом скорости
+func (v *ReplayViewer) delay(before, after engine.ReplayPosition) time.Duration {
+	ticks := after.Tick - before.Tick
+	if after.Segment != before.Segment || ticks < 0 {
+		ticks = domain.TimeCostMove // Время другого уровня несравнимо: показываем как обычный шаг
+	}
+	d := time.Duration(float64(ticks) * float64(replayTickDuration) / v.Speed)
+	return min(d, replayMaxDelay)
+}
+
+// send отправляет кадр текущего состояния. false - соединение закрыто.
+func (v *ReplayViewer) send() bool {
+	frame := v.Cursor.Frame(v.EntityID)
+	pos := v.Cursor.Position()
+	frame.Replay = &api.ReplayView{
+		PlayerID: v.Cursor.PlayerID(),
+		Step:     pos.Step,
+		Total:    pos.Total,
+		Segment:  pos.Segment,
+		Segments: pos.Segments,
+		Level:    pos.Level,
+		Speed:    v.Speed,
+		Paused:   v.Paused,
+		Ended:    pos.Ended,
+		Desyncs:  len(v.Cursor.Result.Desyncs),
+	}
+
+	if err := v.Conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+		logger.Log.WithError(err).Warn("failed to set write deadline")
+	}
+	if err := v.Conn.WriteJSON(frame); err != nil {
+		logger.Log.WithError(err).Debug("write replay frame failed")
+		return false
+	}
+	return true
+}
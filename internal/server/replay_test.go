@@ -0,0 +1,124 @@
+package server
+
+import (
+	"cognitive-server/internal/engine"
+	"cognitive-server/pkg/api"
+	"cognitive-server/pkg/logger"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// replayClient - зритель /ws/replay поверх золотого реплея
+type replayClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func dialReplay(t *testing.T, query string) *replayClient {
+	t.Helper()
+	logger.Init()
+	logger.Log.SetLevel(logrus.FatalLevel)
+
+	data, err := os.ReadFile(filepath.Join("..", "engine", "testdata", "replays", "solo_descent.cdrp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "solo.cdrp"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	service := engine.NewPlaybackService()
+	service.Config.ReplayDir = dir
+	srv := httptest.NewServer(http.HandlerFunc(New(service, "").handleReplayWS))
+	t.Cleanup(srv.Close)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws/replay?file=solo.cdrp&" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &replayClient{t: t, conn: conn}
+}
+
+func (c *replayClient) send(action string, payload any) {
+	c.t.Helper()
+	cmd := api.ClientCommand{Action: action}
+	if payload != nil {
+		cmd.Payload, _ = json.Marshal(payload)
+	}
+	if err := c.conn.WriteJSON(cmd); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// waitFor читает кадры, пока не придет подходящий
+func (c *replayClient) waitFor(what string, match func(*api.ReplayView) bool) *api.ReplayView {
+	c.t.Helper()
+	if err := c.conn.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		var frame api.ServerResponse
+		if err := c.conn.ReadJSON(&frame); err != nil {
+			c.t.Fatalf("waiting for %s: %v", what, err)
+		}
+		if frame.Replay == nil {
+			c.t.Fatal("replay frame without replay state")
+		}
+		if match(frame.Replay) {
+			return frame.Replay
+		}
+	}
+}
+
+func TestReplayViewer_PlaySeekPause(t *testing.T) {
+	c := dialReplay(t, "paused=1&speed=64")
+
+	first := c.waitFor("first frame", func(r *api.ReplayView) bool { return true })
+	if first.Step != 0 || !first.Paused || first.Total == 0 {
+		t.Fatalf("first frame = %+v", first)
+	}
+
+	// Перемотка на паузе показывает кадр и не запускает показ
+	c.send(replayActionSeek, api.ReplaySeekPayload{Step: 5})
+	if r := c.waitFor("seek on pause", func(r *api.ReplayView) bool { return true }); r.Step != 5 || !r.Paused {
+		t.Fatalf("seek on pause = %+v", r)
+	}
+
+	c.send(replayActionPlay, nil)
+	end := c.waitFor("the end", func(r *api.ReplayView) bool { return r.Ended })
+	if end.Step != end.Total {
+		t.Fatalf("ended at step %d of %d", end.Step, end.Total)
+	}
+
+	// Перемотка назад с конца продолжает показ без повторного PLAY
+	c.send(replayActionSeek, api.ReplaySeekPayload{Step: 10})
+	c.waitFor("seek from the end", func(r *api.ReplayView) bool { return r.Step == 10 && !r.Ended })
+	c.waitFor("playback after seek", func(r *api.ReplayView) bool { return r.Step > 10 })
+
+	c.send(replayActionSpeed, api.ReplaySpeedPayload{Speed: 32})
+	c.waitFor("new speed", func(r *api.ReplayView) bool { return r.Speed == 32 })
+
+	c.send(replayActionPause, nil)
+	paused := c.waitFor("pause", func(r *api.ReplayView) bool { return r.Paused })
+
+	// На паузе кадры не идут
+	if err := c.conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	var frame api.ServerResponse
+	if err := c.conn.ReadJSON(&frame); err == nil {
+		t.Fatalf("frame after pause at step %d: %+v", paused.Step, frame.Replay)
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"cognitive-server/internal/engine"
+	"cognitive-server/internal/infrastructure/storage"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// StatsHandler отдает историю забегов и лидерборды
+type StatsHandler struct {
+	Service *engine.GameService
+}
+
+func NewStatsHandler(s *engine.GameService) *StatsHandler {
+	return &StatsHandler{Service: s}
+}
+
+// RegisterRoutes регистрирует stats-эндпоинты
+func (h *StatsHandler) RegisterRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/stats/leaderboard", h.handleLeaderboard)
+	mux.HandleFunc("/stats/runs/", h.handlePlayerRuns)
+}
+
+// /stats/leaderboard?sort=depth&order=desc&offset=0&limit=20 - лучшие забеги всех игроков
+func (h *StatsHandler) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	q, err := parseRunQuery(r, storage.RunSortDepth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.writePage(w, q)
+}
+
+// /stats/runs/{player}?sort=ended_at&order=desc - история забегов игрока (по умолчанию свежие сверху)
+func (h *StatsHandler) handlePlayerRuns(w http.ResponseWriter, r *http.Request) {
+	playerID := strings.TrimPrefix(r.URL.Path, "/stats/runs/")
+	if playerID == "" || strings.Contains(playerID, "/") {
+		http.Error(w, "Player ID required", http.StatusBadRequest)
+		return
+	}
+
+	q, err := parseRunQuery(r, storage.RunSortEndedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q.PlayerID = playerID
+	h.writePage(w, q)
+}
+
+func (h *StatsHandler) writePage(w http.ResponseWriter, q storage.RunQuery) {
+	page, err := h.Service.Runs.Query(q)
+	if errors.Is(err, storage.ErrUnknownRunSort) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to query runs", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, page)
+}
+
+// parseRunQuery разбирает параметры сортировки и пагинации
+func parseRunQuery(r *http.Request, defaultSort string) (storage.RunQuery, error) {
+	params := r.URL.Query()
+	q := storage.RunQuery{SortBy: params.Get("sort")}
+	if q.SortBy == "" {
+		q.SortBy = defaultSort
+	}
+
+	switch params.Get("order") {
+	case "", "desc":
+	case "asc":
+		q.Asc = true
+	default:
+		return q, errors.New("order must be asc or desc")
+	}
+
+	var err error
+	if v := params.Get("offset"); v != "" {
+		if q.Offset, err = strconv.Atoi(v); err != nil || q.Offset < 0 {
+			return q, errors.New("invalid offset")
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 0 {
+			return q, errors.New("invalid limit")
+		}
+	}
+	return q, nil
+}
@@ -75,6 +75,24 @@ func ComputeNPCAction(npc *domain.Entity, player *domain.Entity, w *domain.GameW
 	return domain.ActionMove, nil, moveDx, moveDy
 }
 
+// WanderIdleChance - как часто (%) бродящий NPC стоит на месте
+const WanderIdleChance = 50
+
+var wanderSteps = []domain.Position{{X: 0, Y: -1}, {X: 1, Y: 0}, {X: 0, Y: 1}, {X: -1, Y: 0}}
+
+// ComputeWanderStep выбирает случайный шаг для мирного NPC. В стены и в других существ
+// он не шагает, поэтому никого не атакует. (0, 0) - стоять на месте.
+func ComputeWanderStep(npc *domain.Entity, w *domain.GameWorld, rng *rand.Rand) (dx, dy int) {
+	if rng.Intn(100) < WanderIdleChance {
+		return 0, 0
+	}
+	step := wanderSteps[rng.Intn(len(wanderSteps))]
+	if !checkMove(npc, step.X, step.Y, w) {
+		return 0, 0
+	}
+	return step.X, step.Y
+}
+
 // Внутренние утилиты (приватные для пакета systems)
 
 func calculateSmartMove(npc, target *domain.Entity, w *domain.GameWorld) (int, int) {
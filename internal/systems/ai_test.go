@@ -2,12 +2,14 @@ package systems
 
 import (
 	"cognitive-server/internal/domain"
+	"math/rand"
 	"testing"
 )
 
 func TestComputeNPCAction(t *testing.T) {
 	// Common Setup
 	world := createTestWorld(10, 10)
+	rng := rand.New(rand.NewSource(1))
 
 	basePlayer := &domain.Entity{
 		ID:   "player",
@@ -53,7 +55,7 @@ func TestComputeNPCAction(t *testing.T) {
 		npc, player := setup()
 		npc.Stats.IsDead = true
 
-		act, _, _, _ := ComputeNPCAction(npc, player, world)
+		act, _, _, _ := ComputeNPCAction(npc, player, world, rng)
 		if act != domain.ActionWait {
 			t.Errorf("Dead NPC should WAIT, got %v", act)
 		}
@@ -64,7 +66,7 @@ func TestComputeNPCAction(t *testing.T) {
 		npc.Pos = domain.Position{X: 0, Y: 0}
 		player.Pos = domain.Position{X: 9, Y: 9} // Dist ~12.7
 
-		act, _, _, _ := ComputeNPCAction(npc, player, world)
+		act, _, _, _ := ComputeNPCAction(npc, player, world, rng)
 		if act != domain.ActionWait {
 			t.Errorf("NPC too far should WAIT, got %v", act)
 		}
@@ -75,7 +77,7 @@ func TestComputeNPCAction(t *testing.T) {
 		player.Pos = domain.Position{X: 5, Y: 5}
 		npc.Pos = domain.Position{X: 5, Y: 4} // Distance 1.0
 
-		act, target, _, _ := ComputeNPCAction(npc, player, world)
+		act, target, _, _ := ComputeNPCAction(npc, player, world, rng)
 		if act != domain.ActionAttack {
 			t.Errorf("NPC in melee range should ATTACK, got %v", act)
 		}
@@ -89,7 +91,7 @@ func TestComputeNPCAction(t *testing.T) {
 		player.Pos = domain.Position{X: 5, Y: 5}
 		npc.Pos = domain.Position{X: 5, Y: 3} // Distance 2.0
 
-		act, _, dx, dy := ComputeNPCAction(npc, player, world)
+		act, _, dx, dy := ComputeNPCAction(npc, player, world, rng)
 		if act != domain.ActionMove {
 			t.Errorf("NPC in aggro range should MOVE, got %v", act)
 		}
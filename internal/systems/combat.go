@@ -38,12 +38,11 @@ func ApplyAttack(attacker, target *domain.Entity, rng *rand.Rand) string {
 		baseDamage = attacker.Stats.Strength
 	}
 
-	// Бонус от экипированного оружия
-	weaponDamage := 0
-	if attacker.Equipment != nil && attacker.Equipment.Weapon != nil {
-		if attacker.Equipment.Weapon.Item != nil {
-			weaponDamage = attacker.Equipment.Weapon.Item.Damage
-		}
+	// Бонус от экипированного оружия (аффиксы уже вписаны в его характеристики)
+	weaponDamage, lifeOnHit := 0, 0
+	if weapon := equippedWeapon(attacker); weapon != nil {
+		weaponDamage = weapon.Damage
+		lifeOnHit = weapon.LifeOnHit
 	}
 
 	totalDamage := baseDamage + weaponDamage
@@ -67,12 +66,24 @@ func ApplyAttack(attacker, target *domain.Entity, rng *rand.Rand) string {
 	died := target.Stats.TakeDamage(finalDamage)
 	hpAfter := target.Stats.HP
 
+	// Статистика забегов (урон, убийства, причина смерти)
+	domain.RecordHit(attacker, target, finalDamage, died)
+
+	// Вампиризм: не больше нанесенного урона
+	healed := 0
+	if lifeOnHit > 0 && attacker.Stats != nil && !attacker.Stats.IsDead {
+		hpBefore := attacker.Stats.HP
+		attacker.Stats.Heal(min(lifeOnHit, finalDamage))
+		healed = attacker.Stats.HP - hpBefore
+	}
+
 	// Логируем событие
 	combatLogger.WithFields(logrus.Fields{
 		"base_damage":   baseDamage,
 		"weapon_damage": weaponDamage,
 		"defense":       defense,
 		"final_damage":  finalDamage,
+		"healed":        healed,
 		"hp_before":     hpBefore,
 		"hp_after":      hpAfter,
 		"target_died":   died,
@@ -81,6 +92,9 @@ func ApplyAttack(attacker, target *domain.Entity, rng *rand.Rand) string {
 	// --- Формируем сообщение для клиента ---
 
 	logMsg := fmt.Sprintf("%s наносит %d урона по %s.", attacker.Name, finalDamage, target.Name)
+	if healed > 0 {
+		logMsg += fmt.Sprintf(" %s восстанавливает %d HP.", attacker.Name, healed)
+	}
 
 	// TODO: Генерация событий для живых предметов
 	// Если у атакующего экипировано живое оружие (IsSentient=true),
@@ -107,6 +121,22 @@ func ApplyAttack(attacker, target *domain.Entity, rng *rand.Rand) string {
 	return logMsg
 }
 
+// AttackCost - время удара в тиках с модификатором скорости оружия (не меньше половины базового)
+func AttackCost(attacker *domain.Entity) int {
+	cost := domain.TimeCostAttackLight
+	if weapon := equippedWeapon(attacker); weapon != nil {
+		cost += weapon.AttackSpeed
+	}
+	return max(cost, domain.TimeCostAttackLight/2)
+}
+
+func equippedWeapon(e *domain.Entity) *domain.ItemComponent {
+	if e.Equipment == nil || e.Equipment.Weapon == nil {
+		return nil
+	}
+	return e.Equipment.Weapon.Item
+}
+
 // CreateLootBag создаёт "мешок с лутом" из инвентаря мёртвой сущности
 func CreateLootBag(deadEntity *domain.Entity) *domain.Entity {
 	// Если у сущности нет инвентаря или он пустой, не создаём мешок
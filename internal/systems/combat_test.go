@@ -2,10 +2,13 @@ package systems
 
 import (
 	"cognitive-server/internal/domain"
+	"math/rand"
 	"testing"
 )
 
 func TestApplyAttack(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
 	attacker := &domain.Entity{
 		Name: "Hero",
 		Stats: &domain.StatsComponent{
@@ -22,7 +25,7 @@ func TestApplyAttack(t *testing.T) {
 	}
 
 	// Attack logic: damage = max(1, attacker.Str)
-	msg := ApplyAttack(attacker, target)
+	msg := ApplyAttack(attacker, target, rng)
 
 	if target.Stats.HP != 15 {
 		t.Errorf("Expected target HP to be 15, got %d", target.Stats.HP)
@@ -34,7 +37,7 @@ func TestApplyAttack(t *testing.T) {
 
 	// Kill shot
 	attacker.Stats.Strength = 100
-	ApplyAttack(attacker, target)
+	ApplyAttack(attacker, target, rng)
 
 	if target.Stats.HP > 0 {
 		t.Errorf("Expected target to be dead (HP <= 0), got %d", target.Stats.HP)
@@ -43,3 +46,68 @@ func TestApplyAttack(t *testing.T) {
 		t.Error("Expected IsDead flag to be true")
 	}
 }
+
+func TestApplyAttack_RecordsRunStats(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	hero := &domain.Entity{
+		Name:  "Hero",
+		Stats: &domain.StatsComponent{HP: 10, MaxHP: 10, Strength: 20},
+		Run:   domain.NewRunStats(0, 1),
+	}
+	goblin := &domain.Entity{
+		Name:       "Goblin",
+		TemplateID: "goblin",
+		Stats:      &domain.StatsComponent{HP: 15, MaxHP: 15, Strength: 4},
+	}
+
+	ApplyAttack(goblin, hero, rng)
+	ApplyAttack(hero, goblin, rng)
+
+	if hero.Run.DamageTaken != 4 || hero.Run.DamageDealt != 20 {
+		t.Errorf("unexpected damage stats: dealt %d, taken %d", hero.Run.DamageDealt, hero.Run.DamageTaken)
+	}
+	if hero.Run.Kills["goblin"] != 1 {
+		t.Errorf("expected goblin kill to be recorded, got %v", hero.Run.Kills)
+	}
+
+	goblin.Stats.IsDead = false
+	goblin.Stats.HP = 15
+	goblin.Stats.Strength = 50
+	ApplyAttack(goblin, hero, rng)
+
+	if hero.Run.CauseOfDeath == "" {
+		t.Error("expected cause of death to be recorded")
+	}
+}
+
+func TestApplyAttack_WeaponAffixes(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	sword := &domain.Entity{
+		Name: "Железный меч вампира",
+		Item: &domain.ItemComponent{Category: domain.ItemCategoryWeapon, Damage: 5, AttackSpeed: -20, LifeOnHit: 3},
+	}
+	hero := &domain.Entity{
+		Name:      "Hero",
+		Stats:     &domain.StatsComponent{HP: 5, MaxHP: 10, Strength: 1},
+		Equipment: &domain.EquipmentComponent{Weapon: sword},
+	}
+	goblin := &domain.Entity{Name: "Goblin", Stats: &domain.StatsComponent{HP: 20, MaxHP: 20}}
+
+	ApplyAttack(hero, goblin, rng)
+	if goblin.Stats.HP != 14 {
+		t.Errorf("goblin HP = %d, want 14", goblin.Stats.HP)
+	}
+	if hero.Stats.HP != 8 {
+		t.Errorf("hero HP = %d, want 8 after life on hit", hero.Stats.HP)
+	}
+	if got := AttackCost(hero); got != domain.TimeCostAttackLight-20 {
+		t.Errorf("attack cost = %d", got)
+	}
+
+	sword.Item.AttackSpeed = -1000
+	if got := AttackCost(hero); got != domain.TimeCostAttackLight/2 {
+		t.Errorf("attack cost floor = %d", got)
+	}
+}
@@ -148,7 +148,6 @@ func isBlocking(w *domain.GameWorld, x, y int) bool {
 	if x < 0 || y < 0 || x >= w.Width || y >= w.Height {
 		return true
 	}
-	// Проверяем стену
-	// В будущем здесь можно проверять компонент Physics.IsTransparent
-	return w.Map[y][x].IsWall
+	// Стены и закрытые двери (см. реестр типов клеток)
+	return w.Map[y][x].BlocksSight()
 }
@@ -4,6 +4,7 @@ import (
 	"cognitive-server/internal/domain"
 	"cognitive-server/pkg/utils"
 	"fmt"
+	"math/rand"
 )
 
 // --- PICKUP ---
@@ -29,7 +30,7 @@ func TryPickup(actor *domain.Entity, item *domain.Entity, world *domain.GameWorl
 
 // --- DROP ---
 
-func TryDrop(actor *domain.Entity, itemID string, count int, world *domain.GameWorld) (string, error) {
+func TryDrop(actor *domain.Entity, itemID string, count int, world *domain.GameWorld, rng *rand.Rand) (string, error) {
 	if actor.Inventory == nil {
 		return "", fmt.Errorf("нет инвентаря")
 	}
@@ -47,7 +48,7 @@ func TryDrop(actor *domain.Entity, itemID string, count int, world *domain.GameW
 		// Создаем копию для выброса
 		droppedItem := *item // Shallow copy структуры Entity
 		// Глубокая копия компонентов, которые меняются
-		droppedItem.ID = utils.GenerateID()
+		droppedItem.ID = utils.GenerateDeterministicID(rng, "ei_") // ID из RNG уровня, чтобы реплей совпал
 		droppedItem.Item = &domain.ItemComponent{}
 		*droppedItem.Item = *item.Item // Copy Item data
 		droppedItem.Item.StackSize = count
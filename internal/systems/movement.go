@@ -12,6 +12,7 @@ type MovementResult struct {
 	HasMoved   bool
 	BlockedBy  *domain.Entity // Если врезались в кого-то (для атаки)
 	IsWall     bool           // Если врезались в стену
+	IsDoor     bool           // Стена - закрытая дверь (открывается через INTERACT)
 }
 
 func CalculateMove(e *domain.Entity, dx, dy int, w *domain.GameWorld) MovementResult {
@@ -37,12 +38,22 @@ func CalculateMove(e *domain.Entity, dx, dy int, w *domain.GameWorld) MovementRe
 	}
 
 	// 2. Проверка стен
-	if w.Map[targetPos.Y][targetPos.X].IsWall {
+	tile := w.Map[targetPos.Y][targetPos.X]
+	if tile.IsWall {
 		res.IsWall = true
+		res.IsDoor = tile.Type == domain.TileDoorClosed
 		moveLogger.Debug("Move blocked by WALL.")
 		return res
 	}
 
+	// Монстры и NPC в видимые опасности (лава, пропасть) не заходят - для них это стена.
+	// Скрытые ловушки они не видят так же, как игрок.
+	if e.Type != domain.EntityTypePlayer && tile.Type.Def().AIAvoids {
+		res.IsWall = true
+		moveLogger.WithField("tile", tile.Type.String()).Debug("AI avoids HAZARD.")
+		return res
+	}
+
 	// 3. Проверка сущностей
 	entitiesAtTarget := w.GetEntitiesAt(targetPos.X, targetPos.Y)
 	for _, other := range entitiesAtTarget {
@@ -50,4 +50,15 @@ func TestCalculateMove(t *testing.T) {
 	if res.HasMoved {
 		t.Error("Expected move to fail (OOB)")
 	}
+
+	// Test 4: Monster avoids visible lava but walks onto a hidden trap like onto floor
+	world.Map[2][3].SetType(domain.TileLava)
+	world.Map[2][1].SetType(domain.TileTrap)
+	monster := &domain.Entity{Type: domain.EntityTypeEnemy, Pos: domain.Position{X: 2, Y: 2}}
+	if res = CalculateMove(monster, 1, 0, world); res.HasMoved || !res.IsWall {
+		t.Error("Expected monster to avoid lava")
+	}
+	if res = CalculateMove(monster, -1, 0, world); !res.HasMoved {
+		t.Error("Expected monster to step onto the hidden trap")
+	}
 }
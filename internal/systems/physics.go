@@ -51,8 +51,8 @@ func HasLineOfSight(w *domain.GameWorld, p1, p2 domain.Position) bool {
 					Debug("Check finished: Line is blocked by map BOUNDS. Result: false")
 				return false
 			}
-			// 2. Проверка стены
-			if w.Map[y0][x0].IsWall {
+			// 2. Проверка стены (и всего, что закрывает обзор)
+			if w.Map[y0][x0].BlocksSight() {
 				losLogger.WithField("blocking_point", map[string]int{"x": x0, "y": y0}).
 					Debug("Check finished: Line is blocked by WALL. Result: false")
 				return false
@@ -0,0 +1,103 @@
+package systems
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/pkg/logger"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TileEffectResult - последствия входа на клетку
+type TileEffectResult struct {
+	Msg   string
+	Event json.RawMessage // LEVEL_TRANSITION при падении в пропасть
+}
+
+// ApplyTileEffects применяет эффект клетки, на которую только что встала сущность:
+// лава обжигает, ловушка срабатывает и становится видимой, пропасть роняет на уровень ниже.
+func ApplyTileEffects(e *domain.Entity, w *domain.GameWorld) TileEffectResult {
+	tile := &w.Map[e.Pos.Y][e.Pos.X]
+	def := tile.Type.Def()
+	if def.OnEnter == domain.TileEffectNone || e.Stats == nil || e.Stats.IsDead {
+		return TileEffectResult{}
+	}
+
+	var res TileEffectResult
+	var cause string
+	switch def.OnEnter {
+	case domain.TileEffectBurn:
+		res.Msg = fmt.Sprintf("%s обжигается о лаву: %d урона.", e.Name, def.Damage)
+		cause = "сгорел в лаве"
+	case domain.TileEffectTrap:
+		tile.SetType(def.Sprung)
+		res.Msg = fmt.Sprintf("%s наступает на ловушку: %d урона.", e.Name, def.Damage)
+		cause = "погиб в ловушке"
+	case domain.TileEffectFall:
+		// С поверхности падать некуда: пропасть там просто ранит
+		res.Msg = fmt.Sprintf("%s срывается в пропасть: %d урона.", e.Name, def.Damage)
+		cause = "разбился, упав в пропасть"
+		if w.Level > 0 {
+			res.Event, _ = json.Marshal(map[string]interface{}{
+				"event":       "LEVEL_TRANSITION",
+				"targetLevel": w.Level + 1,
+				"targetPosId": fmt.Sprintf("exit_up_from_%d", w.Level+1),
+			})
+		}
+	}
+
+	died := e.Stats.TakeDamage(def.Damage)
+	domain.RecordHazard(e, def.Damage, died, cause)
+	if died {
+		res.Msg += fmt.Sprintf(" %s погибает.", e.Name)
+		res.Event = nil
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"component": "tile_system",
+		"entity_id": e.ID,
+		"tile":      tile.Type.String(),
+		"damage":    def.Damage,
+		"died":      died,
+	}).Info("Tile effect applied.")
+	return res
+}
+
+// ToggleTile открывает или закрывает дверь в соседней с актором клетке pos
+func ToggleTile(actor *domain.Entity, pos domain.Position, w *domain.GameWorld) (string, error) {
+	if pos.X < 0 || pos.X >= w.Width || pos.Y < 0 || pos.Y >= w.Height {
+		return "", fmt.Errorf("там ничего нет")
+	}
+	if !actor.Pos.IsAdjacent(pos) {
+		return "", fmt.Errorf("дверь слишком далеко")
+	}
+
+	tile := &w.Map[pos.Y][pos.X]
+	def := tile.Type.Def()
+	if !def.Toggles {
+		return "", fmt.Errorf("здесь нечего открывать")
+	}
+	// Закрыть дверь, в проеме которой кто-то стоит, нельзя
+	if def.Walkable {
+		for _, other := range w.GetEntitiesAt(pos.X, pos.Y) {
+			if other.Stats != nil && !other.Stats.IsDead || other.Item != nil {
+				return "", fmt.Errorf("проем чем-то загорожен")
+			}
+		}
+	}
+
+	tile.SetType(def.ToggleTo)
+
+	// Дверь меняет обзор всем, кто стоит на уровне
+	for _, e := range w.EntityRegistry {
+		if e.Vision != nil {
+			e.Vision.IsDirty = true
+		}
+	}
+
+	if def.Walkable {
+		return fmt.Sprintf("%s закрывает дверь.", actor.Name), nil
+	}
+	return fmt.Sprintf("%s открывает дверь.", actor.Name), nil
+}
@@ -0,0 +1,102 @@
+package systems
+
+import (
+	"cognitive-server/internal/domain"
+	"encoding/json"
+	"testing"
+)
+
+func tileWorld(level int) *domain.GameWorld {
+	world := &domain.GameWorld{
+		Width:          5,
+		Height:         5,
+		Level:          level,
+		Map:            make([][]domain.Tile, 5),
+		SpatialHash:    make(map[int][]*domain.Entity),
+		EntityRegistry: make(map[string]*domain.Entity),
+	}
+	for y := range 5 {
+		world.Map[y] = make([]domain.Tile, 5)
+		for x := range 5 {
+			world.Map[y][x] = domain.Tile{X: x, Y: y}
+		}
+	}
+	return world
+}
+
+func TestApplyTileEffects(t *testing.T) {
+	world := tileWorld(2)
+	world.Map[2][2].SetType(domain.TileTrap)
+	world.Map[2][3].SetType(domain.TileChasm)
+	hero := &domain.Entity{
+		ID:    "hero",
+		Name:  "Герой",
+		Pos:   domain.Position{X: 2, Y: 2},
+		Stats: &domain.StatsComponent{HP: 20, MaxHP: 20},
+		Run:   domain.NewRunStats(0, 2),
+	}
+
+	// Ловушка ранит один раз и становится видимой
+	ApplyTileEffects(hero, world)
+	trap := domain.TileTrap.Def()
+	if hero.Stats.HP != 20-trap.Damage || hero.Run.DamageTaken != trap.Damage {
+		t.Errorf("trap: hp %d, damage taken %d", hero.Stats.HP, hero.Run.DamageTaken)
+	}
+	if world.Map[2][2].Type != domain.TileTrapSprung {
+		t.Errorf("trap not sprung: %s", world.Map[2][2].Type)
+	}
+	ApplyTileEffects(hero, world)
+	if hero.Stats.HP != 20-trap.Damage {
+		t.Errorf("sprung trap hurts again: hp %d", hero.Stats.HP)
+	}
+
+	// Пропасть роняет на уровень ниже
+	hero.Pos = domain.Position{X: 3, Y: 2}
+	res := ApplyTileEffects(hero, world)
+	var event struct {
+		Event       string `json:"event"`
+		TargetLevel int    `json:"targetLevel"`
+		TargetPosID string `json:"targetPosId"`
+	}
+	if err := json.Unmarshal(res.Event, &event); err != nil {
+		t.Fatalf("chasm event: %v", err)
+	}
+	if event.Event != "LEVEL_TRANSITION" || event.TargetLevel != 3 || event.TargetPosID != "exit_up_from_3" {
+		t.Errorf("chasm event: %+v", event)
+	}
+}
+
+func TestToggleTile_Door(t *testing.T) {
+	world := tileWorld(0)
+	world.Map[2][3].SetType(domain.TileDoorClosed)
+	hero := &domain.Entity{ID: "hero", Name: "Герой", Pos: domain.Position{X: 2, Y: 2}, Vision: &domain.VisionComponent{}}
+	world.AddEntity(hero)
+	world.RegisterEntity(hero)
+
+	if res := CalculateMove(hero, 1, 0, world); res.HasMoved || !res.IsDoor {
+		t.Fatalf("closed door must block: %+v", res)
+	}
+	if _, err := ToggleTile(hero, domain.Position{X: 3, Y: 2}, world); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if !hero.Vision.IsDirty {
+		t.Error("vision not invalidated")
+	}
+	if res := CalculateMove(hero, 1, 0, world); !res.HasMoved {
+		t.Fatalf("open door must let through: %+v", res)
+	}
+
+	// В проеме стоят - закрыть нельзя
+	villager := &domain.Entity{ID: "villager", Pos: domain.Position{X: 3, Y: 2}, Stats: &domain.StatsComponent{HP: 5}}
+	world.AddEntity(villager)
+	if _, err := ToggleTile(hero, domain.Position{X: 3, Y: 2}, world); err == nil {
+		t.Error("closed a door on someone standing in it")
+	}
+	world.RemoveEntity(villager)
+	if _, err := ToggleTile(hero, domain.Position{X: 4, Y: 4}, world); err == nil {
+		t.Error("toggled a far tile")
+	}
+	if _, err := ToggleTile(hero, domain.Position{X: 3, Y: 2}, world); err != nil || world.Map[2][3].Type != domain.TileDoorClosed {
+		t.Errorf("close: %v, tile %s", err, world.Map[2][3].Type)
+	}
+}
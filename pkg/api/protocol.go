@@ -35,6 +35,23 @@ type ServerResponse struct {
 
 	// Logs срез новых сообщений, сгенерированных с прошлого хода.
 	Logs []LogEntry `json:"logs,omitempty"`
+
+	// Replay состояние проигрывания. Есть только в кадрах реплея (/ws/replay).
+	Replay *ReplayView `json:"replay,omitempty"`
+}
+
+// ReplayView описывает, где находится проигрывание реплея и как оно идет.
+type ReplayView struct {
+	PlayerID string  `json:"playerId"`          // Чей это забег
+	Step     int     `json:"step"`              // Выполнено записанных действий
+	Total    int     `json:"total"`             // Всего записанных действий
+	Segment  int     `json:"segment"`           // Текущий сегмент (уровень забега)
+	Segments int     `json:"segments"`          // Всего сегментов
+	Level    int     `json:"level"`             // Уровень текущего сегмента
+	Speed    float64 `json:"speed"`             // Множитель скорости
+	Paused   bool    `json:"paused"`            // Проигрывание на паузе
+	Ended    bool    `json:"ended"`             // Реплей закончился
+	Desyncs  int     `json:"desyncs,omitempty"` // Найдено расхождений с записью
 }
 
 // GridMeta содержит общие размеры карты, чтобы клиент знал,
@@ -50,6 +67,9 @@ type TileView struct {
 	X int `json:"x"`
 	Y int `json:"y"`
 
+	// Type - тип тайла из реестра (floor, stone, door_closed, water, lava, chasm...).
+	Type string `json:"type"`
+
 	// Symbol и Color - визуальное представление тайла (e.g. "#" для стены).
 	Symbol string `json:"symbol"`
 	Color  string `json:"color"`
@@ -114,18 +134,22 @@ type LogEntry struct {
 
 // ItemView представляет предмет для клиента
 type ItemView struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Symbol      string `json:"symbol"`
-	Color       string `json:"color"`
-	Category    string `json:"category"`
-	IsStackable bool   `json:"isStackable,omitempty"`
-	StackSize   int    `json:"stackSize,omitempty"`
-	Damage      int    `json:"damage,omitempty"`
-	Defense     int    `json:"defense,omitempty"`
-	Weight      uint   `json:"weight,omitempty"`
-	Price       uint   `json:"price,omitempty"`
-	IsSentient  bool   `json:"isSentient,omitempty"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Symbol      string   `json:"symbol"`
+	Color       string   `json:"color"`
+	Category    string   `json:"category"`
+	Rarity      string   `json:"rarity"`
+	Affixes     []string `json:"affixes,omitempty"`
+	IsStackable bool     `json:"isStackable,omitempty"`
+	StackSize   int      `json:"stackSize,omitempty"`
+	Damage      int      `json:"damage,omitempty"`
+	AttackSpeed int      `json:"attackSpeed,omitempty"`
+	LifeOnHit   int      `json:"lifeOnHit,omitempty"`
+	Defense     int      `json:"defense,omitempty"`
+	Weight      uint     `json:"weight,omitempty"`
+	Price       uint     `json:"price,omitempty"`
+	IsSentient  bool     `json:"isSentient,omitempty"`
 }
 
 // InventoryView представляет инвентарь для клиента
@@ -170,12 +194,31 @@ type EntityPayload struct {
 	TargetID string `json:"targetId"`
 }
 
+// InteractPayload используется для INTERACT: либо сущность (рычаг, лестница, NPC),
+// либо соседняя клетка (дверь открывается и закрывается).
+type InteractPayload struct {
+	TargetID string           `json:"targetId,omitempty"`
+	Tile     *PositionPayload `json:"tile,omitempty"`
+}
+
 // PositionPayload используется для действий, нацеленных на точку на карте (e.g. TELEPORT).
 type PositionPayload struct {
 	X int `json:"x"`
 	Y int `json:"y"`
 }
 
+// ReplaySeekPayload используется для перемотки реплея (SEEK): номер записанного действия
+// или игровое время (если задано, step игнорируется).
+type ReplaySeekPayload struct {
+	Step int `json:"step"`
+	Tick int `json:"tick,omitempty"`
+}
+
+// ReplaySpeedPayload используется для смены скорости реплея (SPEED).
+type ReplaySpeedPayload struct {
+	Speed float64 `json:"speed"`
+}
+
 // ItemPayload используется для действий с предметами (PICKUP, DROP, USE, EQUIP, UNEQUIP).
 type ItemPayload struct {
 	ItemID string `json:"itemId"`
@@ -0,0 +1,206 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Шанс аффикса на каждый слот (префикс и суффикс бросаются отдельно) растет с глубиной
+const (
+	AffixChancePerDepth = 6  // %, за каждый уровень глубины
+	AffixChanceMax      = 40 // %, потолок
+)
+
+// Места аффикса в названии предмета
+const (
+	AffixPrefix = "prefix"
+	AffixSuffix = "suffix"
+)
+
+// Характеристики, которые меняют аффиксы
+const (
+	AffixStatDamage    = "damage"
+	AffixStatDefense   = "defense"
+	AffixStatSpeed     = "speed" // Ускоряет удар: AttackSpeed уменьшается на значение
+	AffixStatLifeOnHit = "lifeOnHit"
+)
+
+// Affix - аффикс предмета из раздела affixes файла контента.
+// Name - слово в названии, "{value}" заменяется выпавшим значением.
+type Affix struct {
+	ID         string
+	Kind       string
+	Name       string
+	Categories []domain.ItemCategory
+	MinDepth   int
+	MaxDepth   int // 0 - без ограничения
+	Weight     int
+	Stat       string
+	Value      Amount // Растет с глубиной через DepthStep
+}
+
+// AllowedAt - может ли аффикс выпасть на глубине depth
+func (a Affix) AllowedAt(depth int) bool {
+	return depth >= a.MinDepth && (a.MaxDepth == 0 || depth <= a.MaxDepth)
+}
+
+// affixChance - шанс аффикса на слот на глубине depth, %
+func affixChance(depth int) int {
+	return min(depth*AffixChancePerDepth, AffixChanceMax)
+}
+
+// rollAffixes бросает префикс и суффикс для предмета на глубине level и вписывает их
+// бонусы в характеристики. Аффиксы шаблона отсортированы по ключу, поэтому при том же сиде
+// выпадает то же самое.
+func (t ItemTemplate) rollAffixes(entity *domain.Entity, level int, rng *rand.Rand) {
+	prefix, suffix := "", ""
+	for _, kind := range []string{AffixPrefix, AffixSuffix} {
+		var candidates []Affix
+		total := 0
+		for _, a := range t.Affixes {
+			if a.Kind == kind && a.AllowedAt(level) {
+				candidates = append(candidates, a)
+				total += a.Weight
+			}
+		}
+		if len(candidates) == 0 || rng.Intn(100) >= affixChance(level) {
+			continue
+		}
+
+		roll := rng.Intn(total)
+		for _, a := range candidates {
+			if roll >= a.Weight {
+				roll -= a.Weight
+				continue
+			}
+			value := a.apply(entity.Item, level, rng)
+			word := strings.ReplaceAll(a.Name, "{value}", strconv.Itoa(value))
+			if kind == AffixPrefix {
+				prefix = word + " "
+			} else {
+				suffix = " " + word
+			}
+			entity.Item.Affixes = append(entity.Item.Affixes, a.ID)
+			break
+		}
+	}
+
+	if len(entity.Item.Affixes) > 0 {
+		entity.Name = prefix + entity.Name + suffix
+		entity.Item.Rarity = max(entity.Item.Rarity, domain.ItemRarityRare)
+	}
+}
+
+// apply бросает значение аффикса и прибавляет его к характеристике предмета
+func (a Affix) apply(item *domain.ItemComponent, level int, rng *rand.Rand) int {
+	value := a.Value.Roll(level, rng)
+	switch a.Stat {
+	case AffixStatDamage:
+		item.Damage += value
+	case AffixStatDefense:
+		item.Defense += value
+	case AffixStatSpeed:
+		item.AttackSpeed -= value
+	case AffixStatLifeOnHit:
+		item.LifeOnHit += value
+	}
+	return value
+}
+
+// --- Загрузка из файлов контента ---
+
+// affixDef - аффикс в файле контента
+type affixDef struct {
+	Extends    string   `json:"extends,omitempty"`
+	Kind       string   `json:"kind"`
+	Name       string   `json:"name"`
+	Categories []string `json:"categories"`
+	MinDepth   int      `json:"minDepth"`
+	MaxDepth   int      `json:"maxDepth"`
+	Weight     int      `json:"weight"`
+	Stat       string   `json:"stat"`
+	Value      Amount   `json:"value"`
+}
+
+// affixes собирает аффиксы и раздает их шаблонам обычных предметов подходящих категорий
+func (l *contentLoader) affixes(c *Content) map[string]Affix {
+	result := make(map[string]Affix)
+	for _, key := range l.keys(SectionAffixes) {
+		var def affixDef
+		if !l.decode(SectionAffixes, key, &def) {
+			continue
+		}
+
+		var errs []string
+		if def.Kind != AffixPrefix && def.Kind != AffixSuffix {
+			errs = append(errs, fmt.Sprintf("unknown kind %q (expected %s or %s)", def.Kind, AffixPrefix, AffixSuffix))
+		}
+		if strings.TrimSpace(def.Name) == "" {
+			errs = append(errs, "name must not be empty")
+		}
+		var categories []domain.ItemCategory
+		for _, name := range def.Categories {
+			category := domain.ParseItemCategory(name)
+			if category == domain.ItemCategoryUnknown {
+				errs = append(errs, fmt.Sprintf("unknown category %q", name))
+			}
+			categories = append(categories, category)
+		}
+		if len(def.Categories) == 0 {
+			errs = append(errs, "categories must not be empty")
+		}
+		if def.MinDepth < 1 {
+			errs = append(errs, "minDepth must be at least 1")
+		}
+		if def.MaxDepth != 0 && def.MaxDepth < def.MinDepth {
+			errs = append(errs, "maxDepth must be 0 (no limit) or not less than minDepth")
+		}
+		if def.Weight <= 0 {
+			errs = append(errs, "weight must be positive")
+		}
+		switch def.Stat {
+		case AffixStatDamage, AffixStatDefense, AffixStatSpeed, AffixStatLifeOnHit:
+		default:
+			errs = append(errs, fmt.Sprintf("unknown stat %q (expected %s, %s, %s or %s)",
+				def.Stat, AffixStatDamage, AffixStatDefense, AffixStatSpeed, AffixStatLifeOnHit))
+		}
+		errs = append(errs, checkAmount("value", def.Value)...)
+		if def.Value.Min <= 0 {
+			errs = append(errs, "value.min must be positive")
+		}
+
+		if l.report(l.where(SectionAffixes, key), errs) {
+			continue
+		}
+		result[key] = Affix{
+			ID:         key,
+			Kind:       def.Kind,
+			Name:       def.Name,
+			Categories: categories,
+			MinDepth:   def.MinDepth,
+			MaxDepth:   def.MaxDepth,
+			Weight:     def.Weight,
+			Stat:       def.Stat,
+			Value:      def.Value,
+		}
+	}
+
+	// Живым предметам аффиксы не достаются: у них свои имена и характер
+	for _, key := range l.keys(SectionAffixes) {
+		affix, ok := result[key]
+		if !ok {
+			continue
+		}
+		for id, tmpl := range c.Items {
+			if slices.Contains(affix.Categories, tmpl.Properties.Category) {
+				tmpl.Affixes = append(tmpl.Affixes, affix)
+				c.Items[id] = tmpl
+			}
+		}
+	}
+	return result
+}
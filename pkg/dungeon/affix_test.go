@@ -0,0 +1,60 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSpawnItem_RollsAffixes(t *testing.T) {
+	sword := Templates().Items["iron_sword"]
+	base := sword.Properties
+
+	affixed := 0
+	for seed := int64(1); seed <= 300; seed++ {
+		item := sword.SpawnItem(domain.Position{}, 6, rand.New(rand.NewSource(seed)))
+		again := sword.SpawnItem(domain.Position{}, 6, rand.New(rand.NewSource(seed)))
+		if item.Name != again.Name || item.Item.Damage != again.Item.Damage {
+			t.Fatalf("seed %d: %q and %q differ", seed, item.Name, again.Name)
+		}
+		if len(item.Item.Affixes) == 0 {
+			if item.Name != sword.Name || item.Item.Damage != base.Damage {
+				t.Errorf("seed %d: plain sword changed: %q, damage %d", seed, item.Name, item.Item.Damage)
+			}
+			continue
+		}
+		affixed++
+
+		if item.Item.Rarity != domain.ItemRarityRare {
+			t.Errorf("seed %d: affixed sword rarity %s", seed, item.Item.Rarity)
+		}
+		if slices.Contains(item.Item.Affixes, "keen") && (item.Item.Damage <= base.Damage || !strings.HasPrefix(item.Name, "+")) {
+			t.Errorf("seed %d: keen sword %q has damage %d", seed, item.Name, item.Item.Damage)
+		}
+		if slices.Contains(item.Item.Affixes, "swiftness") && (item.Item.AttackSpeed >= 0 || !strings.HasSuffix(item.Name, "быстроты")) {
+			t.Errorf("seed %d: swift sword %q has attackSpeed %d", seed, item.Name, item.Item.AttackSpeed)
+		}
+		if slices.Contains(item.Item.Affixes, "vampire") && item.Item.LifeOnHit <= 0 {
+			t.Errorf("seed %d: vampire sword %q has no life on hit", seed, item.Name)
+		}
+	}
+	// На глубине 6 шанс 36% на слот: хотя бы один аффикс примерно у 60% мечей
+	if affixed < 120 || affixed > 240 {
+		t.Errorf("%d of 300 swords affixed", affixed)
+	}
+}
+
+func TestSpawnItem_NoAffixesOnSurfaceOrSentient(t *testing.T) {
+	content := Templates()
+	for seed := int64(1); seed <= 50; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		if item := content.Items["iron_sword"].SpawnItem(domain.Position{}, 0, rng); len(item.Item.Affixes) > 0 {
+			t.Fatalf("seed %d: surface sword got %v", seed, item.Item.Affixes)
+		}
+		if item := content.SentientItems["bloodthirsty_sword"].SpawnItem(domain.Position{}, 9, rng); len(item.Item.Affixes) > 0 {
+			t.Fatalf("seed %d: sentient sword got %v", seed, item.Item.Affixes)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+)
+
+// BSPMinLeaf - минимальная сторона области, которую еще можно делить пополам
+const BSPMinLeaf = 8
+
+// bspNode - узел дерева разбиения. У листа есть комната, у внутреннего узла - две половины.
+type bspNode struct {
+	area        Rect
+	left, right *bspNode
+	room        int // Индекс комнаты в b.rooms (только у листа)
+}
+
+// WithBSP генерирует комнаты рекурсивным делением карты (BSP).
+// Соседние половины соединяются коридорами, поэтому все комнаты связаны деревом.
+// extraLoops добавляет коридоры между ближайшими несвязанными комнатами, чтобы появились кольца.
+// На входах коридоров в комнаты ставятся двери.
+func (b *LevelBuilder) WithBSP(extraLoops int) *LevelBuilder {
+	return b.apply(func() { b.withBSP(extraLoops) })
+}
+
+func (b *LevelBuilder) withBSP(extraLoops int) {
+	b.fillWalls()
+	b.rooms = make([]Rect, 0)
+
+	root := &bspNode{area: Rect{X: 0, Y: 0, W: b.width - 1, H: b.height - 1}}
+	b.splitBSP(root)
+	b.placeBSPRooms(root)
+
+	linked := make(map[[2]int]bool)
+	b.connectBSP(root, linked)
+	b.addLoops(extraLoops, linked)
+
+	for _, room := range b.rooms {
+		b.placeDoors(room)
+	}
+}
+
+// fillWalls заполняет карту сплошным камнем
+func (b *LevelBuilder) fillWalls() {
+	b.gameMap = make([][]domain.Tile, b.height)
+	for y := 0; y < b.height; y++ {
+		row := make([]domain.Tile, b.width)
+		for x := 0; x < b.width; x++ {
+			row[x] = domain.Tile{
+				X: x, Y: y, IsWall: true, Type: domain.TileStone,
+			}
+		}
+		b.gameMap[y] = row
+	}
+}
+
+// splitBSP делит область, пока обе половины не меньше BSPMinLeaf.
+// Вытянутые области режутся поперек, квадратные - в случайном направлении.
+func (b *LevelBuilder) splitBSP(node *bspNode) {
+	area := node.area
+	canSplitW := area.W >= 2*BSPMinLeaf
+	canSplitH := area.H >= 2*BSPMinLeaf
+	if !canSplitW && !canSplitH {
+		return
+	}
+
+	vertical := canSplitW
+	if canSplitW && canSplitH {
+		switch {
+		case area.W*4 > area.H*5:
+			vertical = true
+		case area.H*4 > area.W*5:
+			vertical = false
+		default:
+			vertical = b.rng.Intn(2) == 0
+		}
+	}
+
+	if vertical {
+		cut := b.randRange(BSPMinLeaf, area.W-BSPMinLeaf)
+		node.left = &bspNode{area: Rect{X: area.X, Y: area.Y, W: cut, H: area.H}}
+		node.right = &bspNode{area: Rect{X: area.X + cut, Y: area.Y, W: area.W - cut, H: area.H}}
+	} else {
+		cut := b.randRange(BSPMinLeaf, area.H-BSPMinLeaf)
+		node.left = &bspNode{area: Rect{X: area.X, Y: area.Y, W: area.W, H: cut}}
+		node.right = &bspNode{area: Rect{X: area.X, Y: area.Y + cut, W: area.W, H: area.H - cut}}
+	}
+	b.splitBSP(node.left)
+	b.splitBSP(node.right)
+}
+
+// placeBSPRooms вырезает по комнате в каждом листе. Порядок комнат - обход слева направо,
+// поэтому первая и последняя (лестницы) оказываются в разных концах карты.
+func (b *LevelBuilder) placeBSPRooms(node *bspNode) {
+	if node.left != nil {
+		b.placeBSPRooms(node.left)
+		b.placeBSPRooms(node.right)
+		return
+	}
+
+	// Стены комнаты лежат на ее границе, внутри листа. Лист бывает меньше MinSize,
+	// только если вся карта такая (WithSize): тогда комната занимает его целиком.
+	area := node.area
+	w := b.randRange(min(MinSize, area.W), min(MaxSize, area.W))
+	h := b.randRange(min(MinSize, area.H), min(MaxSize, area.H))
+	room := Rect{
+		X: area.X + b.rng.Intn(area.W-w+1),
+		Y: area.Y + b.rng.Intn(area.H-h+1),
+		W: w,
+		H: h,
+	}
+	createRoom(b.gameMap, room)
+	node.room = len(b.rooms)
+	b.rooms = append(b.rooms, room)
+}
+
+// connectBSP соединяет половины каждого узла: ближайшие друг к другу комнаты левой и правой частей.
+func (b *LevelBuilder) connectBSP(node *bspNode, linked map[[2]int]bool) {
+	if node.left == nil {
+		return
+	}
+	b.connectBSP(node.left, linked)
+	b.connectBSP(node.right, linked)
+
+	from, to := -1, -1
+	best := 0
+	for _, a := range node.left.rooms() {
+		for _, c := range node.right.rooms() {
+			if d := roomDistance(b.rooms[a], b.rooms[c]); from < 0 || d < best {
+				from, to, best = a, c, d
+			}
+		}
+	}
+	b.linkRooms(from, to, linked)
+}
+
+// addLoops добавляет count коридоров от случайных комнат к ближайшим, с которыми они еще не связаны
+func (b *LevelBuilder) addLoops(count int, linked map[[2]int]bool) {
+	for i := 0; i < count && len(b.rooms) > 2; i++ {
+		from := b.rng.Intn(len(b.rooms))
+		to, best := -1, 0
+		for k := range b.rooms {
+			if k == from || linked[roomPair(from, k)] {
+				continue
+			}
+			if d := roomDistance(b.rooms[from], b.rooms[k]); to < 0 || d < best {
+				to, best = k, d
+			}
+		}
+		if to >= 0 {
+			b.linkRooms(from, to, linked)
+		}
+	}
+}
+
+// linkRooms прокладывает Г-образный коридор между центрами комнат
+func (b *LevelBuilder) linkRooms(from, to int, linked map[[2]int]bool) {
+	linked[roomPair(from, to)] = true
+	x1, y1 := b.rooms[from].Center()
+	x2, y2 := b.rooms[to].Center()
+	if b.rng.Intn(2) == 0 {
+		createHCorridor(b.gameMap, x1, x2, y1)
+		createVCorridor(b.gameMap, y1, y2, x2)
+	} else {
+		createVCorridor(b.gameMap, y1, y2, x1)
+		createHCorridor(b.gameMap, x1, x2, y2)
+	}
+}
+
+// placeDoors ставит двери там, где коридор шириной в клетку пробил стену комнаты
+func (b *LevelBuilder) placeDoors(room Rect) {
+	// Углы пропускаем: через них коридор в комнату не входит
+	for x := room.X + 1; x < room.X+room.W; x++ {
+		b.tryDoor(x, room.Y, true)
+		b.tryDoor(x, room.Y+room.H, true)
+	}
+	for y := room.Y + 1; y < room.Y+room.H; y++ {
+		b.tryDoor(room.X, y, false)
+		b.tryDoor(room.X+room.W, y, false)
+	}
+}
+
+// tryDoor превращает пробитую клетку стены в дверь, если по обе стороны вдоль стены камень,
+// а поперек - проход (horizontal - стена идет по горизонтали)
+func (b *LevelBuilder) tryDoor(x, y int, horizontal bool) {
+	if x <= 0 || y <= 0 || x >= b.width-1 || y >= b.height-1 {
+		return
+	}
+	tile := &b.gameMap[y][x]
+	if tile.IsWall || tile.Type == domain.TileDoorOpen {
+		return
+	}
+
+	dx, dy := 1, 0
+	if !horizontal {
+		dx, dy = 0, 1
+	}
+	if !b.gameMap[y-dy][x-dx].IsWall || !b.gameMap[y+dy][x+dx].IsWall {
+		return
+	}
+	if b.gameMap[y-dx][x-dy].IsWall || b.gameMap[y+dx][x+dy].IsWall {
+		return
+	}
+	// Стены соседних комнат вплотную: хватит одной двери на проход
+	if b.gameMap[y-dx][x-dy].Type == domain.TileDoorOpen || b.gameMap[y+dx][x+dy].Type == domain.TileDoorOpen {
+		return
+	}
+	tile.SetType(domain.TileDoorOpen)
+}
+
+// rooms возвращает индексы комнат поддерева
+func (n *bspNode) rooms() []int {
+	if n.left == nil {
+		return []int{n.room}
+	}
+	return append(n.left.rooms(), n.right.rooms()...)
+}
+
+// roomDistance - манхэттенское расстояние между центрами комнат
+func roomDistance(a, c Rect) int {
+	ax, ay := a.Center()
+	cx, cy := c.Center()
+	return abs(ax-cx) + abs(ay-cy)
+}
+
+// roomPair - ключ пары комнат без учета порядка
+func roomPair(a, c int) [2]int {
+	return [2]int{min(a, c), max(a, c)}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,94 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestWithBSP_Deterministic(t *testing.T) {
+	a := NewLevel(2, rand.New(rand.NewSource(42))).WithBSP(3)
+	b := NewLevel(2, rand.New(rand.NewSource(42))).WithBSP(3)
+	if !reflect.DeepEqual(a.gameMap, b.gameMap) || !reflect.DeepEqual(a.rooms, b.rooms) {
+		t.Fatal("same seed produced different maps")
+	}
+}
+
+func TestWithBSP_Connected(t *testing.T) {
+	for seed := int64(1); seed <= 200; seed++ {
+		b := NewLevel(2, rand.New(rand.NewSource(seed))).WithBSP(2)
+		if len(b.rooms) < 2 {
+			t.Fatalf("seed %d: expected several rooms, got %d", seed, len(b.rooms))
+		}
+
+		// Заливка от центра первой комнаты должна дойти до центров всех остальных
+		start := b.GetStartPos()
+		reached := floodFill(b.gameMap, start)
+		for k, room := range b.rooms {
+			cx, cy := room.Center()
+			if !reached[cy][cx] {
+				t.Fatalf("seed %d: room %d is not reachable", seed, k)
+			}
+		}
+
+		doors := 0
+		for y := range b.gameMap {
+			for x := range b.gameMap[y] {
+				tile := b.gameMap[y][x]
+				if tile.Type == domain.TileDoorOpen {
+					doors++
+					if tile.IsWall {
+						t.Fatalf("seed %d: door at %d,%d is a wall", seed, x, y)
+					}
+				}
+				// Край карты всегда остается стеной
+				if (x == 0 || y == 0 || x == b.width-1 || y == b.height-1) && !tile.IsWall {
+					t.Fatalf("seed %d: border tile %d,%d is open", seed, x, y)
+				}
+			}
+		}
+		if doors == 0 {
+			t.Errorf("seed %d: no doors placed", seed)
+		}
+	}
+}
+
+func floodFill(gameMap [][]domain.Tile, start domain.Position) [][]bool {
+	reached := make([][]bool, len(gameMap))
+	for y := range gameMap {
+		reached[y] = make([]bool, len(gameMap[y]))
+	}
+	stack := []domain.Position{start}
+	reached[start.Y][start.X] = true
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			x, y := p.X+d[0], p.Y+d[1]
+			if y < 0 || y >= len(gameMap) || x < 0 || x >= len(gameMap[y]) {
+				continue
+			}
+			if reached[y][x] || gameMap[y][x].IsWall {
+				continue
+			}
+			reached[y][x] = true
+			stack = append(stack, domain.Position{X: x, Y: y})
+		}
+	}
+	return reached
+}
+
+// Карта уже минимальной комнаты не ломает разбиение: комната ужимается до листа
+func TestWithBSP_SmallMap(t *testing.T) {
+	for size := 3; size <= 2*BSPMinLeaf; size++ {
+		for seed := int64(1); seed <= 20; seed++ {
+			b := NewLevel(2, rand.New(rand.NewSource(seed))).WithSize(size, size+1).WithBSP(1)
+			for k, room := range b.rooms {
+				if room.X < 0 || room.Y < 0 || room.X+room.W > size-1 || room.Y+room.H > size {
+					t.Fatalf("size %d seed %d: room %d %+v is outside the map", size, seed, k, room)
+				}
+			}
+		}
+	}
+}
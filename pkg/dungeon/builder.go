@@ -2,6 +2,7 @@ package dungeon
 
 import (
 	"cognitive-server/internal/domain"
+	"cognitive-server/pkg/logger"
 	"encoding/json"
 	"fmt"
 	"math/rand"
@@ -24,8 +25,7 @@ func (r Rect) Intersects(other Rect) bool {
 func createRoom(gameMap [][]domain.Tile, room Rect) {
 	for y := room.Y + 1; y < room.Y+room.H; y++ {
 		for x := room.X + 1; x < room.X+room.W; x++ {
-			gameMap[y][x].IsWall = false
-			gameMap[y][x].Env = "floor"
+			gameMap[y][x].SetType(domain.TileFloor)
 		}
 	}
 }
@@ -34,8 +34,7 @@ func createHCorridor(gameMap [][]domain.Tile, x1, x2, y int) {
 	start := min(x1, x2)
 	end := max(x1, x2)
 	for x := start; x <= end; x++ {
-		gameMap[y][x].IsWall = false
-		gameMap[y][x].Env = "floor"
+		gameMap[y][x].SetType(domain.TileFloor)
 	}
 }
 
@@ -43,8 +42,7 @@ func createVCorridor(gameMap [][]domain.Tile, y1, y2, x int) {
 	start := min(y1, y2)
 	end := max(y1, y2)
 	for y := start; y <= end; y++ {
-		gameMap[y][x].IsWall = false
-		gameMap[y][x].Env = "floor"
+		gameMap[y][x].SetType(domain.TileFloor)
 	}
 }
 
@@ -61,6 +59,24 @@ type LevelBuilder struct {
 	gameMap  [][]domain.Tile
 	entities []domain.Entity
 	rng      *rand.Rand
+	content  *Content // Шаблоны на момент создания builder: перезагрузка не должна менять уровень посреди сборки
+
+	// Пещеры (WithCaves): проходимые клетки, старт, самая дальняя точка и шаги до каждой клетки
+	floor    []domain.Position
+	start    domain.Position
+	far      domain.Position
+	distance [][]int
+
+	// Вырезанные хранилища (WithVaults): коридоры и другие хранилища их обходят
+	vaults []Rect
+
+	// Лестницы, которые требует рецепт: Validate проверяет, что они стоят, fallback ставит их сам
+	exits []exitSpec
+
+	// Шаги рецепта: при неудачной проверке Build повторяет их с производным сидом
+	steps    []func()
+	attempts int
+	failures []error
 }
 
 // NewLevel создает новый builder для уровня
@@ -71,29 +87,26 @@ func NewLevel(level int, rng *rand.Rand) *LevelBuilder {
 		height:   MapHeight,
 		entities: make([]domain.Entity, 0),
 		rng:      rng,
+		content:  Templates(),
 	}
 }
 
 // WithSize устанавливает размер карты
 func (b *LevelBuilder) WithSize(width, height int) *LevelBuilder {
-	b.width = width
-	b.height = height
-	return b
+	return b.apply(func() {
+		b.width = width
+		b.height = height
+	})
 }
 
 // WithRooms генерирует комнаты и коридоры
 func (b *LevelBuilder) WithRooms(maxRooms int) *LevelBuilder {
+	return b.apply(func() { b.withRooms(maxRooms) })
+}
+
+func (b *LevelBuilder) withRooms(maxRooms int) {
 	// Инициализируем карту стенами
-	b.gameMap = make([][]domain.Tile, b.height)
-	for y := 0; y < b.height; y++ {
-		row := make([]domain.Tile, b.width)
-		for x := 0; x < b.width; x++ {
-			row[x] = domain.Tile{
-				X: x, Y: y, IsWall: true, Env: "stone",
-			}
-		}
-		b.gameMap[y] = row
-	}
+	b.fillWalls()
 
 	// Генерируем комнаты
 	b.rooms = make([]Rect, 0, maxRooms)
@@ -133,45 +146,97 @@ func (b *LevelBuilder) WithRooms(maxRooms int) *LevelBuilder {
 			b.rooms = append(b.rooms, newRoom)
 		}
 	}
-
-	return b
 }
 
 // SpawnEnemy спавнит врага из шаблона
 func (b *LevelBuilder) SpawnEnemy(templateName string, count int) *LevelBuilder {
-	template, ok := EnemyTemplates[templateName]
+	return b.apply(func() { b.spawnEnemy(templateName, count) })
+}
+
+func (b *LevelBuilder) spawnEnemy(templateName string, count int) {
+	template, ok := b.content.Enemies[templateName]
 	if !ok {
-		return b
+		return
 	}
 
-	// Спавним в случайных комнатах (кроме первой)
+	// В пещерах комнат нет: враги встают на случайные клетки подальше от старта
+	if len(b.rooms) == 0 {
+		for i := 0; i < count; i++ {
+			if pos, ok := b.randomFloor(CaveSpawnDistance); ok {
+				b.spawnEnemyAt(template, pos)
+			}
+		}
+		return
+	}
+
+	// Спавним в случайных комнатах (кроме первой) на свободные клетки
 	for i := 0; i < count && len(b.rooms) > 1; i++ {
 		roomIdx := b.rng.Intn(len(b.rooms)-1) + 1 // Не в первой комнате
-		room := b.rooms[roomIdx]
-		cx, cy := room.Center()
+		if pos, ok := b.freeTileIn(b.rooms[roomIdx]); ok {
+			b.spawnEnemyAt(template, pos)
+		}
+	}
+}
 
+// spawnEnemyAt создает врага из шаблона со статами, масштабированными по уровню
+func (b *LevelBuilder) spawnEnemyAt(template EntityTemplate, pos domain.Position) {
+	scaledTemplate := template
+	scaledTemplate.Stats.HP += int(b.level * 2)
+	scaledTemplate.Stats.Strength += int(b.level / 2)
+
+	enemy := scaledTemplate.SpawnEntity(pos, b.level, b.rng)
+	b.entities = append(b.entities, enemy)
+}
+
+// freeTileIn ищет внутри комнаты проходимую клетку, на которой никто не стоит (макс 20 попыток)
+func (b *LevelBuilder) freeTileIn(room Rect) (domain.Position, bool) {
+	for attempt := 0; attempt < 20; attempt++ {
 		pos := domain.Position{
-			X: cx + b.randRange(-1, 1),
-			Y: cy + b.randRange(-1, 1),
+			X: room.X + 1 + b.rng.Intn(room.W-1),
+			Y: room.Y + 1 + b.rng.Intn(room.H-1),
+		}
+		if b.spawnable(pos) {
+			return pos, true
 		}
+	}
+	return domain.Position{}, false
+}
 
-		// Масштабируем статы по уровню
-		scaledTemplate := template
-		scaledTemplate.Stats.HP += int(b.level * 2)
-		scaledTemplate.Stats.Strength += int(b.level / 2)
+// spawnable - на клетку можно поставить сущность: проходима, не опасна, не дверь и свободна
+func (b *LevelBuilder) spawnable(pos domain.Position) bool {
+	def := b.gameMap[pos.Y][pos.X].Type.Def()
+	return def.Walkable && !def.Hazard && !def.Toggles && !b.occupied(pos)
+}
 
-		enemy := scaledTemplate.SpawnEntity(pos, b.level, b.rng)
-		b.entities = append(b.entities, enemy)
+// occupied - стоит ли на клетке уже какая-то сущность
+func (b *LevelBuilder) occupied(pos domain.Position) bool {
+	for _, e := range b.entities {
+		if e.Pos == pos {
+			return true
+		}
 	}
-
-	return b
+	return false
 }
 
 // SpawnItem спавнит предметы из шаблона
 func (b *LevelBuilder) SpawnItem(templateName string, count int) *LevelBuilder {
-	template, ok := ItemTemplates[templateName]
+	return b.apply(func() { b.spawnItem(templateName, count) })
+}
+
+func (b *LevelBuilder) spawnItem(templateName string, count int) {
+	template, ok := b.content.Items[templateName]
 	if !ok {
-		return b
+		return
+	}
+
+	if len(b.rooms) == 0 {
+		for i := 0; i < count; i++ {
+			if pos, ok := b.randomFloor(0); ok {
+				item := template.SpawnItem(pos, b.level, b.rng)
+				b.entities = append(b.entities, *item)
+			}
+		}
+		return
 	}
 
 	// Спавним в случайных комнатах
@@ -186,9 +251,9 @@ func (b *LevelBuilder) SpawnItem(templateName string, count int) *LevelBuilder {
 			x = room.X + b.rng.Intn(room.W)
 			y = room.Y + b.rng.Intn(room.H)
 
-			// Проверяем, что клетка не стена
+			// Проверяем, что клетка не стена и не занята
 			if y >= 0 && y < len(b.gameMap) && x >= 0 && x < len(b.gameMap[y]) {
-				if !b.gameMap[y][x].IsWall {
+				if b.spawnable(domain.Position{X: x, Y: y}) {
 					found = true
 					break
 				}
@@ -203,35 +268,49 @@ func (b *LevelBuilder) SpawnItem(templateName string, count int) *LevelBuilder {
 		item := template.SpawnItem(pos, b.level, b.rng)
 		b.entities = append(b.entities, *item)
 	}
+}
 
-	return b
+// exitSpec - лестница рецепта: направление ("up", "down") и уровень, куда она ведет
+type exitSpec struct {
+	direction string
+	target    int
 }
 
-// PlaceExit размещает лестницу
+// PlaceExit размещает лестницу. Уровень без нее не проходит проверку.
 func (b *LevelBuilder) PlaceExit(direction string, targetLevel int) *LevelBuilder {
-	if len(b.rooms) == 0 {
-		return b
+	b.exits = append(b.exits, exitSpec{direction: direction, target: targetLevel})
+	return b.apply(func() { b.placeExit(direction, targetLevel) })
+}
+
+func (b *LevelBuilder) placeExit(direction string, targetLevel int) {
+	if len(b.rooms) == 0 && len(b.floor) == 0 {
+		return
 	}
 
-	var room Rect
+	var pos domain.Position
 	var symbol byte
 	var name string
 	var description string
 
+	// В пещере комнат нет: подъем ставим на старте, спуск - в самой дальней от него точке
 	if direction == "up" {
-		room = b.rooms[0] // Первая комната
+		pos = b.start
+		if len(b.rooms) > 0 {
+			pos = roomCenter(b.rooms[0]) // Первая комната
+		}
 		symbol = '<'
 		name = "Лестница вверх"
 		description = "Старая каменная лестница, ведущая на поверхность."
 	} else {
-		room = b.rooms[len(b.rooms)-1] // Последняя комната
+		pos = b.far
+		if len(b.rooms) > 0 {
+			pos = roomCenter(b.rooms[len(b.rooms)-1]) // Последняя комната
+		}
 		symbol = '>'
 		name = "Лестница вниз"
 		description = "Темный проход, ведущий вглубь подземелья."
 	}
 
-	cx, cy := room.Center()
-
 	eventPayload, _ := json.Marshal(map[string]interface{}{
 		"event":       "LEVEL_TRANSITION",
 		"targetLevel": targetLevel,
@@ -242,7 +321,7 @@ func (b *LevelBuilder) PlaceExit(direction string, targetLevel int) *LevelBuilde
 		ID:    fmt.Sprintf("exit_%s_from_%d", direction, b.level),
 		Type:  domain.EntityTypeExit,
 		Name:  name,
-		Pos:   domain.Position{X: cx, Y: cy},
+		Pos:   pos,
 		Level: b.level,
 		Render: &domain.RenderComponent{
 			Symbol: symbol,
@@ -257,20 +336,40 @@ func (b *LevelBuilder) PlaceExit(direction string, targetLevel int) *LevelBuilde
 	}
 
 	b.entities = append(b.entities, exit)
-	return b
 }
 
-// GetStartPos возвращает стартовую позицию (центр первой комнаты)
+// GetStartPos возвращает стартовую позицию (центр первой комнаты или старт пещеры)
 func (b *LevelBuilder) GetStartPos() domain.Position {
 	if len(b.rooms) > 0 {
-		cx, cy := b.rooms[0].Center()
-		return domain.Position{X: cx, Y: cy}
+		return roomCenter(b.rooms[0])
+	}
+	if len(b.floor) > 0 {
+		return b.start
 	}
 	return domain.Position{X: b.width / 2, Y: b.height / 2}
 }
 
-// Build собирает и возвращает готовый мир
+// Build проверяет уровень и возвращает готовый мир.
+// Если проверка не прошла, рецепт повторяется с сидом, производным от текущего ГСЧ,
+// поэтому результат по-прежнему однозначно определяется исходным сидом.
+// Если рецепт не собрался за MaxBuildAttempts попыток, вместо него строится запасной уровень.
 func (b *LevelBuilder) Build() (*domain.GameWorld, []domain.Entity, domain.Position) {
+	b.attempts = 1
+	for err := b.Validate(); err != nil; err = b.Validate() {
+		b.failures = append(b.failures, err)
+		if b.attempts >= MaxBuildAttempts {
+			logger.Log.WithField("level", b.level).
+				Warnf("[Level Generator] : Level is still invalid after %d attempts, using fallback layout: %v", b.attempts, err)
+			b.fallback()
+			if err := b.Validate(); err != nil {
+				logger.Log.WithField("level", b.level).Errorf("[Level Generator] : Fallback layout is invalid: %v", err)
+			}
+			break
+		}
+		b.attempts++
+		b.regenerate()
+	}
+
 	world := &domain.GameWorld{
 		Map:            b.gameMap,
 		Width:          b.width,
@@ -283,8 +382,60 @@ func (b *LevelBuilder) Build() (*domain.GameWorld, []domain.Entity, domain.Posit
 	return world, b.entities, b.GetStartPos()
 }
 
+// apply выполняет шаг рецепта и запоминает его для повторной генерации
+func (b *LevelBuilder) apply(step func()) *LevelBuilder {
+	b.steps = append(b.steps, step)
+	step()
+	return b
+}
+
+// regenerate сбрасывает уровень и заново проходит рецепт с новым ГСЧ
+func (b *LevelBuilder) regenerate() {
+	b.rng = rand.New(rand.NewSource(b.rng.Int63()))
+	b.reset()
+	for _, step := range b.steps {
+		step()
+	}
+}
+
+// reset очищает собранный уровень. Шаги рецепта и требуемые лестницы остаются.
+func (b *LevelBuilder) reset() {
+	b.rooms, b.vaults = nil, nil
+	b.gameMap = nil
+	b.entities = make([]domain.Entity, 0)
+	b.floor, b.distance = nil, nil
+	b.start, b.far = domain.Position{}, domain.Position{}
+}
+
+// fallback строит заведомо правильный уровень: две комнаты в противоположных углах,
+// коридор между ними и лестницы рецепта, без врагов и лута. ГСЧ не используется.
+func (b *LevelBuilder) fallback() {
+	b.reset()
+	b.fillWalls()
+	b.rooms = []Rect{
+		{X: 1, Y: 1, W: MinSize + 2, H: MinSize + 2},
+		{X: b.width - MinSize - 4, Y: b.height - MinSize - 4, W: MinSize + 2, H: MinSize + 2},
+	}
+	for _, room := range b.rooms {
+		createRoom(b.gameMap, room)
+	}
+	x1, y1 := b.rooms[0].Center()
+	x2, y2 := b.rooms[1].Center()
+	createHCorridor(b.gameMap, x1, x2, y1)
+	createVCorridor(b.gameMap, y1, y2, x2)
+
+	for _, exit := range b.exits {
+		b.placeExit(exit.direction, exit.target)
+	}
+}
+
 // --- Helper functions ---
 
+func roomCenter(room Rect) domain.Position {
+	cx, cy := room.Center()
+	return domain.Position{X: cx, Y: cy}
+}
+
 func oppositeDirection(dir string) string {
 	if dir == "up" {
 		return "down"
@@ -0,0 +1,201 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"sort"
+)
+
+// Параметры пещер
+const (
+	CaveFillPercent = 45 // Доля камня при начальном заполнении
+	CaveSmoothSteps = 4
+	CaveMinRegion   = 12 // Области меньше этого засыпаются, остальные соединяются туннелями
+
+	CaveSpawnDistance = 6 // Ближе этого (в шагах) к старту враги не появляются
+)
+
+// WithCaves генерирует пещеры клеточным автоматом: случайный шум сглаживается steps раз,
+// затем мелкие области засыпаются, а крупные соединяются с самой большой туннелями.
+// Комнат у пещер нет: спавн и выходы берут клетки из b.floor.
+func (b *LevelBuilder) WithCaves(fillPercent, steps int) *LevelBuilder {
+	return b.apply(func() { b.withCaves(fillPercent, steps) })
+}
+
+func (b *LevelBuilder) withCaves(fillPercent, steps int) {
+	b.fillWalls()
+	b.rooms = nil
+
+	for y := 1; y < b.height-1; y++ {
+		for x := 1; x < b.width-1; x++ {
+			if b.rng.Intn(100) >= fillPercent {
+				b.setFloor(x, y)
+			}
+		}
+	}
+	for i := 0; i < steps; i++ {
+		b.smoothCaves()
+	}
+
+	b.connectCaves()
+	b.collectFloor()
+}
+
+// smoothCaves - один шаг автомата: клетка становится камнем среди 5+ соседей-стен
+// и проходом среди 3 и меньше. Край карты всегда камень.
+func (b *LevelBuilder) smoothCaves() {
+	walls := make([][]bool, b.height)
+	for y := range walls {
+		walls[y] = make([]bool, b.width)
+		for x := range walls[y] {
+			walls[y][x] = b.gameMap[y][x].IsWall
+		}
+	}
+
+	for y := 1; y < b.height-1; y++ {
+		for x := 1; x < b.width-1; x++ {
+			n := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if (dx != 0 || dy != 0) && walls[y+dy][x+dx] {
+						n++
+					}
+				}
+			}
+			switch {
+			case n >= 5:
+				b.gameMap[y][x].SetType(domain.TileStone)
+			case n <= 3:
+				b.setFloor(x, y)
+			}
+		}
+	}
+}
+
+// connectCaves находит связные области заливкой. Мелкие засыпаются, остальные по очереди
+// (от крупных к мелким) соединяются туннелем с уже связанной частью через ближайшую пару клеток.
+func (b *LevelBuilder) connectCaves() {
+	regions := b.floodRegions()
+
+	var kept [][]domain.Position
+	for _, region := range regions {
+		if len(region) >= CaveMinRegion {
+			kept = append(kept, region)
+			continue
+		}
+		for _, p := range region {
+			b.gameMap[p.Y][p.X].SetType(domain.TileStone)
+		}
+	}
+
+	// Совсем пустая пещера: оставляем хотя бы зал в центре
+	if len(kept) == 0 {
+		createRoom(b.gameMap, Rect{X: b.width/2 - MinSize, Y: b.height/2 - MinSize/2, W: 2 * MinSize, H: MinSize})
+		return
+	}
+
+	// Области уже отсортированы по размеру: первая - основная пещера
+	connected := append([]domain.Position(nil), kept[0]...)
+	for _, region := range kept[1:] {
+		from, to := closestPair(region, connected)
+		if b.rng.Intn(2) == 0 {
+			createHCorridor(b.gameMap, from.X, to.X, from.Y)
+			createVCorridor(b.gameMap, from.Y, to.Y, to.X)
+		} else {
+			createVCorridor(b.gameMap, from.Y, to.Y, from.X)
+			createHCorridor(b.gameMap, from.X, to.X, to.Y)
+		}
+		connected = append(connected, region...)
+	}
+}
+
+// floodRegions возвращает связные области прохода, от большей к меньшей.
+// Обход построчный, поэтому порядок областей одного размера детерминирован.
+func (b *LevelBuilder) floodRegions() [][]domain.Position {
+	seen := make([][]bool, b.height)
+	for y := range seen {
+		seen[y] = make([]bool, b.width)
+	}
+
+	var regions [][]domain.Position
+	for y := 0; y < b.height; y++ {
+		for x := 0; x < b.width; x++ {
+			if seen[y][x] || b.gameMap[y][x].IsWall {
+				continue
+			}
+			region := []domain.Position{{X: x, Y: y}}
+			seen[y][x] = true
+			for k := 0; k < len(region); k++ {
+				p := region[k]
+				for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+					nx, ny := p.X+d[0], p.Y+d[1]
+					if nx < 0 || ny < 0 || nx >= b.width || ny >= b.height {
+						continue
+					}
+					if seen[ny][nx] || b.gameMap[ny][nx].IsWall {
+						continue
+					}
+					seen[ny][nx] = true
+					region = append(region, domain.Position{X: nx, Y: ny})
+				}
+			}
+			regions = append(regions, region)
+		}
+	}
+
+	// Устойчивая сортировка: порядок равных по размеру областей должен сохраниться
+	sort.SliceStable(regions, func(i, j int) bool { return len(regions[i]) > len(regions[j]) })
+	return regions
+}
+
+// collectFloor запоминает проходимые клетки, старт и самую дальнюю от него точку (для спуска)
+func (b *LevelBuilder) collectFloor() {
+	b.floor = b.floor[:0]
+	for y := 0; y < b.height; y++ {
+		for x := 0; x < b.width; x++ {
+			if !b.gameMap[y][x].IsWall {
+				b.floor = append(b.floor, domain.Position{X: x, Y: y})
+			}
+		}
+	}
+	if len(b.floor) == 0 {
+		return
+	}
+
+	b.start = b.floor[b.rng.Intn(len(b.floor))]
+	b.distance = b.distancesFrom(b.start)
+	b.far = b.start
+	for _, p := range b.floor {
+		if b.distance[p.Y][p.X] > b.distance[b.far.Y][b.far.X] {
+			b.far = p
+		}
+	}
+}
+
+// randomFloor выбирает свободную клетку пещеры не ближе minDistance шагов от старта
+func (b *LevelBuilder) randomFloor(minDistance int) (domain.Position, bool) {
+	for attempt := 0; attempt < 20 && len(b.floor) > 0; attempt++ {
+		p := b.floor[b.rng.Intn(len(b.floor))]
+		if b.distance[p.Y][p.X] >= minDistance && b.spawnable(p) {
+			return p, true
+		}
+	}
+	return domain.Position{}, false
+}
+
+func (b *LevelBuilder) setFloor(x, y int) {
+	b.gameMap[y][x].SetType(domain.TileFloor)
+}
+
+// closestPair - ближайшие (по манхэттену) клетки двух областей
+func closestPair(a, c []domain.Position) (domain.Position, domain.Position) {
+	from, to := a[0], c[0]
+	best := -1
+	for _, p := range a {
+		for _, q := range c {
+			if d := abs(p.X-q.X) + abs(p.Y-q.Y); best < 0 || d < best {
+				from, to, best = p, q, d
+			}
+		}
+	}
+	return from, to
+}
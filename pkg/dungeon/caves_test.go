@@ -0,0 +1,56 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestWithCaves_Deterministic(t *testing.T) {
+	depth := depthWith(t, GeneratorCaves)
+	a, ae, _ := Generate(depth, rand.New(rand.NewSource(7)))
+	b, be, _ := Generate(depth, rand.New(rand.NewSource(7)))
+	if !reflect.DeepEqual(a.Map, b.Map) || !reflect.DeepEqual(ae, be) {
+		t.Fatal("same seed produced different caves")
+	}
+}
+
+func TestWithCaves_ConnectedAndPopulated(t *testing.T) {
+	depth := depthWith(t, GeneratorCaves) + 1
+	for seed := int64(1); seed <= 200; seed++ {
+		world, entities, start := Generate(depth, rand.New(rand.NewSource(seed)))
+		if world.Map[start.Y][start.X].IsWall {
+			t.Fatalf("seed %d: start %v is inside a wall", seed, start)
+		}
+
+		// Все проходимые клетки - одна связная пещера
+		reached := floodFill(world.Map, start)
+		for y := range world.Map {
+			for x := range world.Map[y] {
+				if !world.Map[y][x].IsWall && !reached[y][x] {
+					t.Fatalf("seed %d: tile %d,%d is cut off from the start", seed, x, y)
+				}
+			}
+		}
+
+		exits, enemies := 0, 0
+		for _, e := range entities {
+			if world.Map[e.Pos.Y][e.Pos.X].IsWall {
+				t.Fatalf("seed %d: %s spawned inside a wall at %v", seed, e.ID, e.Pos)
+			}
+			switch e.Type {
+			case domain.EntityTypeExit:
+				exits++
+			case domain.EntityTypeEnemy:
+				enemies++
+			}
+		}
+		if exits != 2 {
+			t.Errorf("seed %d: expected 2 exits, got %d", seed, exits)
+		}
+		if enemies == 0 {
+			t.Errorf("seed %d: no enemies in the cave", seed)
+		}
+	}
+}
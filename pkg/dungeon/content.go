@@ -0,0 +1,467 @@
+package dungeon
+
+import (
+	"bytes"
+	"cognitive-server/internal/domain"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Базовый пак контента вшит в бинарник: без него сервер не может создать ни героя, ни уровень.
+//
+//go:embed content/*.json
+var baseContent embed.FS
+
+// Разделы файла контента
+const (
+	SectionEnemies       = "enemies"
+	SectionNPCs          = "npcs"
+	SectionItems         = "items"
+	SectionSentientItems = "sentient_items"
+	SectionVaults        = "vaults"
+	SectionLevels        = "levels"
+	SectionLoot          = "loot"
+	SectionAffixes       = "affixes"
+)
+
+var contentSections = []string{
+	SectionEnemies, SectionNPCs, SectionItems, SectionSentientItems, SectionVaults, SectionLevels, SectionLoot, SectionAffixes,
+}
+
+// knownEffects - эффекты, которые умеет применять systems.UseItem
+var knownEffects = map[string]bool{
+	"heal":            true,
+	"buff_strength":   true,
+	"restore_stamina": true,
+}
+
+var colorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// Content - набор шаблонов, из которых спавнятся сущности. После загрузки не меняется:
+// перезагрузка собирает новый набор и подменяет указатель целиком.
+type Content struct {
+	Enemies       map[string]EntityTemplate
+	NPCs          map[string]EntityTemplate
+	Items         map[string]ItemTemplate
+	SentientItems map[string]ItemTemplate
+	Vaults        map[string]Vault
+	Levels        []LevelRecipe // По возрастанию глубины
+	Loot          []LootTable   // По возрастанию глубины
+	Affixes       map[string]Affix
+
+	// Fingerprint - хеш загруженных паков. Входит в имя рецепта в заголовке реплея:
+	// запись, сделанная на другом контенте, не проигрывается, как и запись другой версии генератора.
+	Fingerprint string
+}
+
+var currentContent atomic.Pointer[Content]
+
+func init() {
+	content, err := LoadContent("")
+	if err != nil {
+		panic(fmt.Sprintf("built-in content is invalid: %v", err))
+	}
+	currentContent.Store(content)
+}
+
+// Templates возвращает текущий набор шаблонов. Набор неизменяемый, его можно читать из любой горутины.
+func Templates() *Content {
+	return currentContent.Load()
+}
+
+// ReloadContent загружает паки из dir поверх базового и делает их текущими.
+// При ошибке остается прежний набор. Уже заспавненные сущности не меняются, новые берут новые шаблоны.
+func ReloadContent(dir string) (*Content, error) {
+	content, err := LoadContent(dir)
+	if err != nil {
+		return nil, err
+	}
+	currentContent.Store(content)
+	return content, nil
+}
+
+// LoadContent читает базовый пак и паки *.json из dir (по алфавиту), проверяет и собирает шаблоны.
+// Шаблон с тем же ключом в следующем паке заменяет прежний целиком. Пустой dir - только базовый пак,
+// отсутствующая папка - тоже (паков просто нет).
+func LoadContent(dir string) (*Content, error) {
+	loader := newContentLoader()
+	digest := sha256.New()
+
+	base, err := fs.Glob(baseContent, "content/*.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range base {
+		data, err := baseContent.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		loader.addPack("builtin:"+name, data)
+		hashPack(digest, data)
+	}
+
+	if dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		for _, path := range files {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				loader.errs = append(loader.errs, err)
+				continue
+			}
+			loader.addPack(path, data)
+			hashPack(digest, data)
+		}
+	}
+
+	content, err := loader.build()
+	if err != nil {
+		return nil, err
+	}
+	content.Fingerprint = hex.EncodeToString(digest.Sum(nil)[:6])
+	return content, nil
+}
+
+// hashPack добавляет пак в хеш контента. Длина впереди, чтобы границы паков не сливались.
+func hashPack(h hash.Hash, data []byte) {
+	fmt.Fprintf(h, "%d:", len(data))
+	h.Write(data)
+}
+
+// creatureDef - шаблон существа (врага или NPC) в файле контента
+type creatureDef struct {
+	Extends     string `json:"extends,omitempty"`
+	Name        string `json:"name"`
+	Symbol      string `json:"symbol"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+	Stats       struct {
+		HP       int `json:"hp"`
+		Strength int `json:"strength"`
+		Gold     int `json:"gold"`
+	} `json:"stats"`
+	AI struct {
+		Hostile     bool   `json:"hostile"`
+		Personality string `json:"personality"`
+		Wander      bool   `json:"wander,omitempty"` // Бродит без цели (только мирные)
+	} `json:"ai"`
+}
+
+// itemDef - шаблон предмета в файле контента
+type itemDef struct {
+	Extends      string `json:"extends,omitempty"`
+	Name         string `json:"name"`
+	Symbol       string `json:"symbol"`
+	Color        string `json:"color"`
+	Description  string `json:"description"`
+	Category     string `json:"category"`
+	Rarity       string `json:"rarity,omitempty"` // common (по умолчанию), rare, epic
+	Damage       int    `json:"damage,omitempty"`
+	AttackSpeed  int    `json:"attackSpeed,omitempty"`
+	Defense      int    `json:"defense,omitempty"`
+	EffectType   string `json:"effectType,omitempty"`
+	EffectValue  int    `json:"effectValue,omitempty"`
+	IsConsumable bool   `json:"isConsumable,omitempty"`
+	IsStackable  bool   `json:"isStackable,omitempty"`
+	Weight       int    `json:"weight"`
+	Price        int    `json:"price"`
+	Personality  string `json:"personality,omitempty"`
+	Chattiness   int    `json:"chattiness,omitempty"`
+}
+
+// rawTemplate - шаблон до разрешения наследования
+type rawTemplate struct {
+	source string // Файл, из которого пришел шаблон (для сообщений об ошибках)
+	fields map[string]json.RawMessage
+}
+
+// contentLoader копит паки и ошибки. Ошибки собираются все сразу, чтобы дизайнер
+// видел полный список, а не чинил файл по одной строке.
+type contentLoader struct {
+	sections map[string]map[string]rawTemplate
+	errs     []error
+}
+
+func newContentLoader() *contentLoader {
+	l := &contentLoader{sections: make(map[string]map[string]rawTemplate)}
+	for _, section := range contentSections {
+		l.sections[section] = make(map[string]rawTemplate)
+	}
+	return l
+}
+
+func (l *contentLoader) addPack(source string, data []byte) {
+	var pack map[string]map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(data, &pack); err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %w", source, err))
+		return
+	}
+	for section, templates := range pack {
+		known, ok := l.sections[section]
+		if !ok {
+			l.errs = append(l.errs, fmt.Errorf("%s: unknown section %q (expected one of %s)",
+				source, section, strings.Join(contentSections, ", ")))
+			continue
+		}
+		for key, fields := range templates {
+			known[key] = rawTemplate{source: source, fields: fields}
+		}
+	}
+}
+
+func (l *contentLoader) build() (*Content, error) {
+	content := &Content{
+		Enemies:       l.creatures(SectionEnemies, domain.EntityTypeEnemy),
+		NPCs:          l.creatures(SectionNPCs, domain.EntityTypeNPC),
+		Items:         l.items(SectionItems, false),
+		SentientItems: l.items(SectionSentientItems, true),
+	}
+	content.Affixes = l.affixes(content)
+	content.Vaults = l.vaults(content)
+	content.Levels = l.levels(content)
+	content.Loot = l.loot(content)
+
+	for _, key := range StartingGear {
+		if _, ok := content.Items[key]; !ok {
+			l.errs = append(l.errs, fmt.Errorf("%s.%s: starting gear template is missing", SectionItems, key))
+		}
+	}
+
+	if len(l.errs) > 0 {
+		return nil, errors.Join(l.errs...)
+	}
+	return content, nil
+}
+
+func (l *contentLoader) creatures(section string, entityType domain.EntityType) map[string]EntityTemplate {
+	result := make(map[string]EntityTemplate)
+	for _, key := range l.keys(section) {
+		var def creatureDef
+		if !l.decode(section, key, &def) {
+			continue
+		}
+
+		where := l.where(section, key)
+		errs := checkCommon(def.Name, def.Symbol, def.Color)
+		if def.Stats.HP <= 0 {
+			errs = append(errs, "stats.hp must be positive")
+		}
+		if def.Stats.Strength < 0 || def.Stats.Gold < 0 {
+			errs = append(errs, "stats must not be negative")
+		}
+		state := domain.AIStateIdle
+		if def.AI.Wander {
+			if def.AI.Hostile {
+				errs = append(errs, "ai.wander is only for peaceful creatures")
+			}
+			state = domain.AIStateWander
+		}
+		if l.report(where, errs) {
+			continue
+		}
+
+		result[key] = EntityTemplate{
+			ID:        key,
+			Name:      def.Name,
+			Type:      entityType,
+			Render:    domain.RenderComponent{Symbol: def.Symbol[0], Color: def.Color},
+			Narrative: domain.NarrativeComponent{Description: def.Description},
+			Stats:     domain.StatsComponent{HP: def.Stats.HP, Strength: def.Stats.Strength, Gold: def.Stats.Gold},
+			AI:        domain.AIComponent{IsHostile: def.AI.Hostile, Personality: def.AI.Personality, State: state},
+		}
+	}
+	return result
+}
+
+func (l *contentLoader) items(section string, sentient bool) map[string]ItemTemplate {
+	result := make(map[string]ItemTemplate)
+	for _, key := range l.keys(section) {
+		var def itemDef
+		if !l.decode(section, key, &def) {
+			continue
+		}
+
+		where := l.where(section, key)
+		errs := checkCommon(def.Name, def.Symbol, def.Color)
+		category := domain.ParseItemCategory(def.Category)
+		if category == domain.ItemCategoryUnknown {
+			errs = append(errs, fmt.Sprintf("unknown category %q", def.Category))
+		}
+		rarity := domain.ItemRarityCommon
+		if def.Rarity != "" {
+			var ok bool
+			if rarity, ok = domain.ParseItemRarity(def.Rarity); !ok {
+				errs = append(errs, fmt.Sprintf("unknown rarity %q (expected common, rare or epic)", def.Rarity))
+			}
+		}
+		if def.EffectType != "" && !knownEffects[def.EffectType] {
+			errs = append(errs, fmt.Sprintf("unknown effectType %q", def.EffectType))
+		}
+		if def.Damage < 0 || def.Defense < 0 || def.EffectValue < 0 || def.Weight < 0 || def.Price < 0 {
+			errs = append(errs, "damage, defense, effectValue, weight and price must not be negative")
+		}
+		if def.Chattiness < 0 || def.Chattiness > 10 {
+			errs = append(errs, "chattiness must be within 0..10")
+		}
+		if sentient && def.Personality == "" {
+			errs = append(errs, "sentient item needs a personality")
+		}
+		if l.report(where, errs) {
+			continue
+		}
+
+		result[key] = ItemTemplate{
+			Name:      def.Name,
+			Render:    domain.RenderComponent{Symbol: def.Symbol[0], Color: def.Color},
+			Narrative: domain.NarrativeComponent{Description: def.Description},
+			Properties: domain.ItemComponent{
+				Category:     category,
+				Rarity:       rarity,
+				Damage:       def.Damage,
+				AttackSpeed:  def.AttackSpeed,
+				Defense:      def.Defense,
+				EffectType:   def.EffectType,
+				EffectValue:  def.EffectValue,
+				IsConsumable: def.IsConsumable,
+				IsStackable:  def.IsStackable,
+				Weight:       uint(def.Weight),
+				Price:        uint(def.Price),
+				IsSentient:   sentient,
+				Personality:  def.Personality,
+				Chattiness:   def.Chattiness,
+			},
+		}
+	}
+	return result
+}
+
+// decode разрешает наследование и раскладывает шаблон в def, запрещая неизвестные поля
+func (l *contentLoader) decode(section, key string, def any) bool {
+	fields, err := l.resolve(section, key, nil)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %w", l.where(section, key), err))
+		return false
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %w", l.where(section, key), err))
+		return false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(def); err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %w", l.where(section, key), err))
+		return false
+	}
+	return true
+}
+
+// resolve возвращает поля шаблона с учетом extends: поля потомка перекрывают поля предка,
+// вложенные объекты (stats, ai) сливаются по полям. chain - цепочка для поиска циклов.
+func (l *contentLoader) resolve(section, key string, chain []string) (map[string]json.RawMessage, error) {
+	for _, seen := range chain {
+		if seen == key {
+			return nil, fmt.Errorf("inheritance cycle %s", strings.Join(append(chain, key), " -> "))
+		}
+	}
+	raw, ok := l.sections[section][key]
+	if !ok {
+		return nil, fmt.Errorf("extends unknown template %q", key)
+	}
+
+	var parentKey string
+	if ext, ok := raw.fields["extends"]; ok {
+		if err := json.Unmarshal(ext, &parentKey); err != nil || parentKey == "" {
+			return nil, fmt.Errorf("extends must be a template key")
+		}
+	}
+	if parentKey == "" {
+		return raw.fields, nil
+	}
+
+	parent, err := l.resolve(section, parentKey, append(chain, key))
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]json.RawMessage, len(parent)+len(raw.fields))
+	for k, v := range parent {
+		merged[k] = v
+	}
+	for k, v := range raw.fields {
+		if k == "extends" {
+			continue
+		}
+		merged[k] = mergeRaw(merged[k], v)
+	}
+	delete(merged, "extends")
+	return merged, nil
+}
+
+// mergeRaw сливает два JSON-объекта по полям. Все остальное потомок просто заменяет.
+func mergeRaw(parent, child json.RawMessage) json.RawMessage {
+	var p, c map[string]json.RawMessage
+	if json.Unmarshal(parent, &p) != nil || json.Unmarshal(child, &c) != nil {
+		return child
+	}
+	for k, v := range c {
+		p[k] = v
+	}
+	merged, err := json.Marshal(p)
+	if err != nil {
+		return child
+	}
+	return merged
+}
+
+// keys - ключи раздела по алфавиту, чтобы ошибки шли в стабильном порядке
+func (l *contentLoader) keys(section string) []string {
+	keys := make([]string, 0, len(l.sections[section]))
+	for key := range l.sections[section] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// where - адрес шаблона для сообщения об ошибке: файл и путь внутри него
+func (l *contentLoader) where(section, key string) string {
+	return fmt.Sprintf("%s: %s.%s", l.sections[section][key].source, section, key)
+}
+
+func (l *contentLoader) report(where string, errs []string) bool {
+	for _, e := range errs {
+		l.errs = append(l.errs, fmt.Errorf("%s: %s", where, e))
+	}
+	return len(errs) > 0
+}
+
+// checkCommon проверяет поля, общие для всех шаблонов
+func checkCommon(name, symbol, color string) []string {
+	var errs []string
+	if name == "" {
+		errs = append(errs, "name is required")
+	}
+	if len(symbol) != 1 || symbol[0] < '!' || symbol[0] > '~' {
+		errs = append(errs, fmt.Sprintf("symbol must be a single printable ASCII character, got %q", symbol))
+	}
+	if !colorPattern.MatchString(color) {
+		errs = append(errs, fmt.Sprintf("color must look like #RRGGBB, got %q", color))
+	}
+	return errs
+}
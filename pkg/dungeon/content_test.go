@@ -0,0 +1,151 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePack(t *testing.T, dir, name, data string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadContent_Builtin(t *testing.T) {
+	content, err := LoadContent("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content.Enemies) != 3 || len(content.NPCs) != 3 || len(content.Items) != 13 || len(content.SentientItems) != 4 {
+		t.Errorf("unexpected counts: %d enemies, %d npcs, %d items, %d sentient",
+			len(content.Enemies), len(content.NPCs), len(content.Items), len(content.SentientItems))
+	}
+	if got := content.Items["steel_dagger"].Properties.AttackSpeed; got != -20 {
+		t.Errorf("steel_dagger attackSpeed = %d, want -20", got)
+	}
+	if !content.SentientItems["greedy_ring"].Properties.IsSentient {
+		t.Error("sentient_items must be marked sentient")
+	}
+	if got := content.Items["plate_armor"].Properties.Rarity; got != domain.ItemRarityEpic {
+		t.Errorf("plate_armor rarity = %s, want EPIC", got)
+	}
+}
+
+func TestLoadContent_Extends(t *testing.T) {
+	dir := t.TempDir()
+	writePack(t, dir, "goblins.json", `{
+		"enemies": {
+			"goblin_archer": {"extends": "goblin", "name": "Гоблин-лучник", "stats": {"strength": 4}},
+			"goblin_chief": {"extends": "goblin_archer", "symbol": "G", "ai": {"personality": "Aggressive"}}
+		}
+	}`)
+
+	content, err := LoadContent(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goblin := content.Enemies["goblin"]
+	chief := content.Enemies["goblin_chief"]
+	if chief.ID != "goblin_chief" || chief.Name != "Гоблин-лучник" || chief.Render.Symbol != 'G' {
+		t.Errorf("chief header not inherited: %+v", chief)
+	}
+	// Вложенные объекты сливаются по полям: hp и gold от гоблина, сила от лучника
+	if chief.Stats.HP != goblin.Stats.HP || chief.Stats.Gold != goblin.Stats.Gold || chief.Stats.Strength != 4 {
+		t.Errorf("chief stats = %+v", chief.Stats)
+	}
+	if !chief.AI.IsHostile || chief.AI.Personality != "Aggressive" {
+		t.Errorf("chief ai = %+v", chief.AI)
+	}
+}
+
+func TestLoadContent_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writePack(t, dir, "broken.json", `{
+		"enemies": {
+			"ghost": {"name": "Призрак", "symbol": "gg", "color": "red", "stats": {"hp": 0}},
+			"loop_a": {"extends": "loop_b"},
+			"loop_b": {"extends": "loop_a"},
+			"orphan": {"extends": "nobody"},
+			"typo": {"extends": "goblin", "stast": {"hp": 5}}
+		},
+		"items": {
+			"wand": {"name": "Палочка", "symbol": "/", "color": "#FFFFFF", "category": "staff", "effectType": "fireball"}
+		},
+		"spells": {}
+	}`)
+
+	_, err := LoadContent(dir)
+	if err == nil {
+		t.Fatal("broken pack accepted")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		`unknown section "spells"`,
+		"enemies.ghost: symbol must be a single printable ASCII character",
+		"enemies.ghost: color must look like #RRGGBB",
+		"enemies.ghost: stats.hp must be positive",
+		"inheritance cycle loop_a -> loop_b -> loop_a",
+		`enemies.orphan: extends unknown template "nobody"`,
+		`enemies.typo: json: unknown field "stast"`,
+		`items.wand: unknown category "staff"`,
+		`items.wand: unknown effectType "fireball"`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error does not mention %q:\n%s", want, msg)
+		}
+	}
+	if !strings.Contains(msg, filepath.Join(dir, "broken.json")) {
+		t.Errorf("error does not name the file:\n%s", msg)
+	}
+}
+
+func TestReloadContent_KeepsOldOnError(t *testing.T) {
+	defer currentContent.Store(Templates())
+
+	dir := t.TempDir()
+	writePack(t, dir, "orc.json", `{"enemies": {"orc": {"extends": "troll", "name": "Орк-переросток"}}}`)
+	if _, err := ReloadContent(dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := Templates().Enemies["orc"].Name; got != "Орк-переросток" {
+		t.Fatalf("orc not overridden: %q", got)
+	}
+
+	writePack(t, dir, "zz_broken.json", `{"items": {"iron_sword": {"weight": -1}}}`)
+	if _, err := ReloadContent(dir); err == nil {
+		t.Fatal("broken pack accepted")
+	}
+	if got := Templates().Enemies["orc"].Name; got != "Орк-переросток" {
+		t.Errorf("failed reload replaced content: orc is %q", got)
+	}
+}
+
+func TestLoadContent_Fingerprint(t *testing.T) {
+	base, err := LoadContent("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if empty, err := LoadContent(dir); err != nil || empty.Fingerprint != base.Fingerprint {
+		t.Fatalf("empty content dir changed the fingerprint: %v", err)
+	}
+
+	writePack(t, dir, "orc.json", `{"enemies": {"orc": {"extends": "troll", "name": "Орк-переросток"}}}`)
+	modded, err := LoadContent(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modded.Fingerprint == base.Fingerprint {
+		t.Error("pack did not change the fingerprint")
+	}
+
+	defer currentContent.Store(Templates())
+	currentContent.Store(modded)
+	if !strings.HasSuffix(RecipeName(1), "/"+modded.Fingerprint) || !strings.HasSuffix(SurfaceRecipeName(), "/"+modded.Fingerprint) {
+		t.Errorf("recipe names do not carry the content fingerprint: %q, %q", RecipeName(1), SurfaceRecipeName())
+	}
+}
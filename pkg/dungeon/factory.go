@@ -5,6 +5,10 @@ import (
 	"math/rand"
 )
 
+// StartingGear - ключи предметов, которые герой получает при создании.
+// LoadContent не примет паки, в которых этих шаблонов нет.
+var StartingGear = []string{"iron_sword", "health_potion"}
+
 // CreatePlayer generates a new player entity with default starting gear
 func CreatePlayer(id string, rng *rand.Rand) *domain.Entity {
 	// Создаем героя на основе шаблона
@@ -31,8 +35,10 @@ func CreatePlayer(id string, rng *rand.Rand) *domain.Entity {
 	p.Equipment = &domain.EquipmentComponent{}
 
 	// Даем стартовое снаряжение
-	p.Inventory.AddItem(IronSword.SpawnItem(domain.Position{}, 0, rng))
-	p.Inventory.AddItem(HealthPotion.SpawnItem(domain.Position{}, 0, rng))
+	items := Templates().Items
+	for _, key := range StartingGear {
+		p.Inventory.AddItem(items[key].SpawnItem(domain.Position{}, 0, rng))
+	}
 
 	return &p
 }
@@ -7,6 +7,10 @@ import (
 	"time"
 )
 
+// GeneratorVersion меняется, когда при тех же сидах генератор строит другие уровни
+// (входит в имя рецепта в заголовке реплея)
+const GeneratorVersion = 8
+
 // Константы генерации
 const (
 	MapWidth  = 40
@@ -16,8 +20,8 @@ const (
 	MaxSize   = 10
 )
 
-// Generate создает новый уровень, используя LevelBuilder.
-// Теперь это высокоуровневая функция-директор, определяющая "рецепт" уровня.
+// Generate создает уровень подземелья по рецепту его глубины (раздел levels в контенте).
+// Единая точка генерации: стартовый мир, ленивые уровни и реплеи строятся одинаково.
 func Generate(level int, r *rand.Rand) (*domain.GameWorld, []domain.Entity, domain.Position) {
 	// Если рандом не передан, создаем свой (хотя лучше передавать извне)
 	if r == nil {
@@ -25,47 +29,17 @@ func Generate(level int, r *rand.Rand) (*domain.GameWorld, []domain.Entity, doma
 		r = rand.New(rand.NewSource(time.Now().UnixNano()))
 	}
 
-	// 1. Инициализируем билдер
-	builder := NewLevel(level, r).
-		WithSize(MapWidth, MapHeight).
-		WithRooms(MaxRooms)
-
-	// 2. Размещаем выходы
-	// Логика внутри PlaceExit сама свяжет ID выходов
-	builder.PlaceExit("up", level-1)
-	builder.PlaceExit("down", level+1)
-
-	// 3. Настраиваем сложность (Количество врагов)
-	// Чем глубже, тем больше врагов
-	baseMobCount := 3 + level/2
-
-	// Спавним Гоблинов (есть всегда)
-	builder.SpawnEnemy("goblin", baseMobCount)
-
-	// Спавним Орков (начиная со 2-го уровня)
-	if level >= 2 {
-		orcCount := 1 + level/3
-		builder.SpawnEnemy("orc", orcCount)
-	}
-
-	// Спавним Троллей (начиная с 5-го уровня, редко)
-	if level >= 5 && r.Float32() > 0.7 {
-		builder.SpawnEnemy("troll", 1)
-	}
-
-	// 4. Разбрасываем лут
-	// От 3 до 6 предметов на уровень
-	itemCount := 3 + r.Intn(4)
+	return dungeonRecipe(level, r).Build()
+}
 
-	// Если таблица лута пуста (например, шаблоны не загрузились), пропускаем
-	if len(LootTable) > 0 {
-		for i := 0; i < itemCount; i++ {
-			// Берем случайный ключ из глобальной таблицы
-			randomItemKey := LootTable[r.Intn(len(LootTable))]
-			builder.SpawnItem(randomItemKey, 1)
-		}
+// dungeonRecipe готовит builder уровня по рецепту. Build вызывает вызывающий.
+func dungeonRecipe(level int, r *rand.Rand) *LevelBuilder {
+	content := Templates()
+	recipe, ok := content.RecipeFor(level)
+	if !ok {
+		// Загрузка контента такого не пропускает, но уровень все равно нужен
+		logger.Log.WithField("level", level).Warn("[Level Generator] : No recipe for depth, using plain rooms")
+		recipe = LevelRecipe{ID: "fallback", Generator: GeneratorRooms, Rooms: MaxRooms, Exits: []string{"up", "down"}}
 	}
-
-	// 5. Собираем уровень
-	return builder.Build()
+	return recipeBuilder(content, recipe, level, r)
 }
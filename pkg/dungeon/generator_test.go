@@ -1,6 +1,7 @@
 package dungeon
 
 import (
+	"cognitive-server/internal/domain"
 	"math/rand"
 	"testing"
 	"time"
@@ -36,7 +37,7 @@ func TestGenerate(t *testing.T) {
 
 	hasExitDown := false
 	for _, e := range entities {
-		if e.Type == "EXIT" && e.Render.Label == ">" {
+		if e.Type == domain.EntityTypeExit && e.Render.Symbol == '>' {
 			hasExitDown = true
 			break
 		}
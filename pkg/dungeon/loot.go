@@ -0,0 +1,194 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// LootTable - таблица лута диапазона глубин из раздела loot файла контента.
+// Бросок сначала выбирает редкость по весам Tiers, затем предмет этой редкости по весам Entries.
+// Порядок записей сохраняется из файла, поэтому один и тот же сид дает одни и те же предметы.
+type LootTable struct {
+	ID         string
+	MinDepth   int
+	MaxDepth   int // 0 - без ограничения
+	Tiers      map[domain.ItemRarity]int
+	Entries    []LootEntry
+	Guaranteed []LootDrop // Ставятся на уровень всегда, до случайных бросков
+
+	byTier map[domain.ItemRarity][]LootEntry
+}
+
+// LootEntry - предмет в таблице лута. Вес по умолчанию 1.
+type LootEntry struct {
+	Item   string `json:"item"`
+	Weight int    `json:"weight,omitempty"`
+}
+
+// LootDrop - гарантированный предмет
+type LootDrop struct {
+	Item  string `json:"item"`
+	Count int    `json:"count"`
+}
+
+// AllowedAt - покрывает ли таблица глубину depth
+func (t LootTable) AllowedAt(depth int) bool {
+	return depth >= t.MinDepth && (t.MaxDepth == 0 || depth <= t.MaxDepth)
+}
+
+// LootFor возвращает таблицу лута для глубины depth. Диапазоны проверяются при загрузке так же, как у рецептов.
+func (c *Content) LootFor(depth int) (LootTable, bool) {
+	for _, t := range c.Loot {
+		if t.AllowedAt(depth) {
+			return t, true
+		}
+	}
+	return LootTable{}, false
+}
+
+// Roll выбирает ключ шаблона предмета. Редкости без предметов в таблице не выпадают.
+func (t LootTable) Roll(rng *rand.Rand) string {
+	total := 0
+	for _, rarity := range domain.ItemRarities {
+		if len(t.byTier[rarity]) > 0 {
+			total += t.Tiers[rarity]
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+
+	roll := rng.Intn(total)
+	for _, rarity := range domain.ItemRarities {
+		if len(t.byTier[rarity]) == 0 {
+			continue
+		}
+		if roll < t.Tiers[rarity] {
+			return pickLoot(t.byTier[rarity], rng)
+		}
+		roll -= t.Tiers[rarity]
+	}
+	return ""
+}
+
+func pickLoot(entries []LootEntry, rng *rand.Rand) string {
+	total := 0
+	for _, e := range entries {
+		total += e.Weight
+	}
+	roll := rng.Intn(total)
+	for _, e := range entries {
+		if roll < e.Weight {
+			return e.Item
+		}
+		roll -= e.Weight
+	}
+	return ""
+}
+
+// --- Загрузка из файлов контента ---
+
+// lootDef - таблица лута в файле контента
+type lootDef struct {
+	Extends    string         `json:"extends,omitempty"`
+	MinDepth   int            `json:"minDepth"`
+	MaxDepth   int            `json:"maxDepth"`
+	Tiers      map[string]int `json:"tiers"`
+	Entries    []LootEntry    `json:"entries"`
+	Guaranteed []LootDrop     `json:"guaranteed"`
+}
+
+// loot собирает таблицы лута (по возрастанию глубины) и проверяет, что их диапазоны стыкуются
+func (l *contentLoader) loot(c *Content) []LootTable {
+	var result []LootTable
+	for _, key := range l.keys(SectionLoot) {
+		var def lootDef
+		if !l.decode(SectionLoot, key, &def) {
+			continue
+		}
+
+		var errs []string
+		if def.MinDepth < 1 {
+			errs = append(errs, "minDepth must be at least 1")
+		}
+		if def.MaxDepth != 0 && def.MaxDepth < def.MinDepth {
+			errs = append(errs, "maxDepth must be 0 (no limit) or not less than minDepth")
+		}
+
+		tiers := make(map[domain.ItemRarity]int)
+		// Ключи по алфавиту, чтобы ошибки шли в одном порядке
+		names := make([]string, 0, len(def.Tiers))
+		for name := range def.Tiers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			rarity, ok := domain.ParseItemRarity(name)
+			switch {
+			case !ok:
+				errs = append(errs, fmt.Sprintf("tiers: unknown rarity %q (expected common, rare or epic)", name))
+			case def.Tiers[name] < 0:
+				errs = append(errs, fmt.Sprintf("tiers.%s must not be negative", name))
+			default:
+				tiers[rarity] = def.Tiers[name]
+			}
+		}
+
+		byTier := make(map[domain.ItemRarity][]LootEntry)
+		entries := make([]LootEntry, 0, len(def.Entries))
+		for _, e := range def.Entries {
+			if e.Weight == 0 {
+				e.Weight = 1
+			}
+			tmpl, ok := c.Items[e.Item]
+			switch {
+			case !ok:
+				errs = append(errs, fmt.Sprintf("entries: unknown item %q", e.Item))
+			case e.Weight < 0:
+				errs = append(errs, fmt.Sprintf("entries %q: weight must be positive", e.Item))
+			default:
+				rarity := tmpl.Properties.Rarity
+				if tiers[rarity] == 0 {
+					errs = append(errs, fmt.Sprintf("entries %q: tier %s has no weight", e.Item, strings.ToLower(rarity.String())))
+				}
+				byTier[rarity] = append(byTier[rarity], e)
+				entries = append(entries, e)
+			}
+		}
+		if len(def.Entries) == 0 {
+			errs = append(errs, "entries must not be empty")
+		}
+		for _, g := range def.Guaranteed {
+			if _, ok := c.Items[g.Item]; !ok {
+				errs = append(errs, fmt.Sprintf("guaranteed: unknown item %q", g.Item))
+			}
+			if g.Count <= 0 {
+				errs = append(errs, fmt.Sprintf("guaranteed %q: count must be positive", g.Item))
+			}
+		}
+
+		if l.report(l.where(SectionLoot, key), errs) {
+			continue
+		}
+		result = append(result, LootTable{
+			ID:         key,
+			MinDepth:   def.MinDepth,
+			MaxDepth:   def.MaxDepth,
+			Tiers:      tiers,
+			Entries:    entries,
+			Guaranteed: def.Guaranteed,
+			byTier:     byTier,
+		})
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].MinDepth < result[j].MinDepth })
+	bands := make([]depthBand, len(result))
+	for i, t := range result {
+		bands[i] = depthBand{t.ID, t.MinDepth, t.MaxDepth}
+	}
+	l.checkBands(SectionLoot, "loot table", bands)
+	return result
+}
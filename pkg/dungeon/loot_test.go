@@ -0,0 +1,92 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestLootTable_DeterministicAcrossLoads(t *testing.T) {
+	first, err := LoadContent("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := LoadContent("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for depth := 1; depth <= 8; depth++ {
+		a, _ := first.LootFor(depth)
+		b, _ := second.LootFor(depth)
+		rngA, rngB := rand.New(rand.NewSource(int64(depth))), rand.New(rand.NewSource(int64(depth)))
+		for i := range 200 {
+			if x, y := a.Roll(rngA), b.Roll(rngB); x != y {
+				t.Fatalf("depth %d roll %d: %q vs %q", depth, i, x, y)
+			}
+		}
+	}
+}
+
+func TestLootTable_TierWeights(t *testing.T) {
+	content := Templates()
+	rng := rand.New(rand.NewSource(1))
+	for _, c := range []struct {
+		depth      int
+		rare, epic float64 // Доли из весов tiers в base.json
+	}{{1, 0.14, 0.01}, {6, 0.32, 0.08}} {
+		loot, ok := content.LootFor(c.depth)
+		if !ok {
+			t.Fatalf("no loot table for depth %d", c.depth)
+		}
+		counts := make(map[domain.ItemRarity]int)
+		const rolls = 20000
+		for range rolls {
+			counts[content.Items[loot.Roll(rng)].Properties.Rarity]++
+		}
+		for rarity, want := range map[domain.ItemRarity]float64{domain.ItemRarityRare: c.rare, domain.ItemRarityEpic: c.epic} {
+			if got := float64(counts[rarity]) / rolls; got < want*0.8 || got > want*1.2 {
+				t.Errorf("depth %d: %s share %.3f, want about %.2f", c.depth, rarity, got, want)
+			}
+		}
+	}
+}
+
+func TestLootTable_GuaranteedDrop(t *testing.T) {
+	potion := Templates().Items["health_potion"].Name
+	for seed := int64(1); seed <= 20; seed++ {
+		_, entities, _ := Generate(2, rand.New(rand.NewSource(seed)))
+		potions := 0
+		for _, e := range entities {
+			if e.Type == domain.EntityTypeItem && e.Name == potion {
+				potions++
+			}
+		}
+		if potions == 0 {
+			t.Errorf("seed %d: no guaranteed health potion on depth 2", seed)
+		}
+	}
+}
+
+func TestLootTable_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writePack(t, dir, "loot.json", `{"loot": {
+		"deep": {"minDepth": 3, "tiers": {"common": 1, "legendary": 5}, "entries": [{"item": "plate_armor"}, {"item": "cake"}]},
+		"shallow": {"minDepth": 1, "maxDepth": 1, "tiers": {"common": 1}, "entries": [{"item": "bread"}], "guaranteed": [{"item": "bread"}]}
+	}}`)
+	_, err := LoadContent(dir)
+	if err == nil {
+		t.Fatal("broken loot accepted")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		`loot.deep: tiers: unknown rarity "legendary"`,
+		`loot.deep: entries "plate_armor": tier epic has no weight`,
+		`loot.deep: entries: unknown item "cake"`,
+		`loot.shallow: guaranteed "bread": count must be positive`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error does not mention %q:\n%s", want, msg)
+		}
+	}
+}
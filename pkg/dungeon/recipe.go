@@ -0,0 +1,346 @@
+package dungeon
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Генераторы карты, из которых выбирает рецепт уровня
+const (
+	GeneratorRooms = "rooms" // Случайные комнаты, соединенные по цепочке
+	GeneratorBSP   = "bsp"   // Рекурсивное деление, кольца и двери
+	GeneratorCaves = "caves" // Клеточный автомат
+)
+
+// LevelRecipe - рецепт уровней диапазона глубин из раздела levels файла контента.
+// Ключ рецепта пишется в заголовок реплея (см. RecipeName).
+type LevelRecipe struct {
+	ID        string
+	MinDepth  int
+	MaxDepth  int // 0 - без ограничения
+	Generator string
+
+	Rooms  int // rooms: сколько комнат пробовать разместить
+	Loops  int // bsp: лишние коридоры между соседними комнатами
+	Fill   int // caves: доля камня при заполнении, %
+	Smooth int // caves: шагов сглаживания
+
+	Vaults  Amount
+	Terrain Terrain
+	Exits   []string // "up", "down"
+	Enemies SpawnTable
+	Items   SpawnTable // Пустая таблица - таблица лута глубины (см. LootFor)
+}
+
+// Amount - сколько чего-то будет на уровне: случайно от Min до Max
+// плюс по одному на каждые DepthStep уровней глубины (0 - без роста)
+type Amount struct {
+	Min       int `json:"min"`
+	Max       int `json:"max"`
+	DepthStep int `json:"depthStep"`
+}
+
+// Roll бросает количество для глубины depth
+func (a Amount) Roll(depth int, rng *rand.Rand) int {
+	n := a.Min
+	if a.Max > a.Min {
+		n += rng.Intn(a.Max - a.Min + 1)
+	}
+	if a.DepthStep > 0 {
+		n += depth / a.DepthStep
+	}
+	return n
+}
+
+// SpawnTable - что спавнится на уровне: Fixed ставится всегда, затем Count раз
+// шаблон выбирается из Table по весу
+type SpawnTable struct {
+	Count Amount       `json:"count"`
+	Fixed []SpawnEntry `json:"fixed"`
+	Table []SpawnEntry `json:"table"`
+}
+
+// SpawnEntry - строка таблицы спавна. В Fixed задается Count, в Table - Weight.
+type SpawnEntry struct {
+	Template string `json:"template"`
+	Count    int    `json:"count,omitempty"`
+	Weight   int    `json:"weight,omitempty"`
+}
+
+// pick выбирает шаблон по весу
+func (t SpawnTable) pick(rng *rand.Rand) string {
+	total := 0
+	for _, e := range t.Table {
+		total += e.Weight
+	}
+	roll := rng.Intn(total)
+	for _, e := range t.Table {
+		if roll < e.Weight {
+			return e.Template
+		}
+		roll -= e.Weight
+	}
+	return ""
+}
+
+// AllowedAt - покрывает ли рецепт глубину depth
+func (r LevelRecipe) AllowedAt(depth int) bool {
+	return depth >= r.MinDepth && (r.MaxDepth == 0 || depth <= r.MaxDepth)
+}
+
+// RecipeFor возвращает рецепт для глубины depth. Диапазоны рецептов не пересекаются
+// и покрывают все глубины с первой, это проверяется при загрузке.
+func (c *Content) RecipeFor(depth int) (LevelRecipe, bool) {
+	for _, r := range c.Levels {
+		if r.AllowedAt(depth) {
+			return r, true
+		}
+	}
+	return LevelRecipe{}, false
+}
+
+// RecipeName - имя рецепта уровня для заголовка реплея: ключ рецепта, версия генератора
+// и отпечаток контента. После смены версии или контента старые записи уровней не проигрываются.
+func RecipeName(depth int) string {
+	content := Templates()
+	r, ok := content.RecipeFor(depth)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s/v%d/%s", r.ID, GeneratorVersion, content.Fingerprint)
+}
+
+// recipeBuilder собирает шаги LevelBuilder по рецепту. Build вызывает вызывающий.
+// Порядок важен: хранилища до местности, местность до выходов и спавна, выходы до спавна,
+// чтобы случайные враги и лут не заняли их клетки.
+// Все броски (число хранилищ, врагов и предметов, выбор шаблонов) делаются внутри шагов из ГСЧ
+// builder: повторная попытка после неудачной проверки бросает их заново.
+func recipeBuilder(content *Content, r LevelRecipe, depth int, rng *rand.Rand) *LevelBuilder {
+	b := NewLevel(depth, rng)
+	b.content = content
+	b.WithSize(MapWidth, MapHeight)
+
+	switch r.Generator {
+	case GeneratorBSP:
+		b.WithBSP(r.Loops)
+	case GeneratorCaves:
+		b.WithCaves(r.Fill, r.Smooth)
+	default:
+		b.WithRooms(r.Rooms)
+	}
+
+	b.apply(func() { b.withVaults(r.Vaults.Roll(depth, b.rng)) })
+	b.WithTerrain(r.Terrain)
+
+	for _, dir := range r.Exits {
+		target := depth + 1
+		if dir == "up" {
+			target = depth - 1
+		}
+		b.PlaceExit(dir, target)
+	}
+
+	for _, e := range r.Enemies.Fixed {
+		b.SpawnEnemy(e.Template, e.Count)
+	}
+	if len(r.Enemies.Table) > 0 {
+		b.apply(func() {
+			for range r.Enemies.Count.Roll(depth, b.rng) {
+				b.spawnEnemy(r.Enemies.pick(b.rng), 1)
+			}
+		})
+	}
+
+	for _, e := range r.Items.Fixed {
+		b.SpawnItem(e.Template, e.Count)
+	}
+	switch {
+	case len(r.Items.Table) > 0:
+		b.apply(func() {
+			for range r.Items.Count.Roll(depth, b.rng) {
+				b.spawnItem(r.Items.pick(b.rng), 1)
+			}
+		})
+	case r.Items.Count != (Amount{}):
+		// Случайные предметы без своей таблицы - из таблицы лута глубины, вместе с гарантированными
+		if loot, ok := content.LootFor(depth); ok {
+			b.apply(func() {
+				for _, g := range loot.Guaranteed {
+					b.spawnItem(g.Item, g.Count)
+				}
+				for range r.Items.Count.Roll(depth, b.rng) {
+					b.spawnItem(loot.Roll(b.rng), 1)
+				}
+			})
+		}
+	}
+	return b
+}
+
+// --- Загрузка из файлов контента ---
+
+// recipeDef - рецепт уровня в файле контента
+type recipeDef struct {
+	Extends   string     `json:"extends,omitempty"`
+	MinDepth  int        `json:"minDepth"`
+	MaxDepth  int        `json:"maxDepth"`
+	Generator string     `json:"generator"`
+	Rooms     int        `json:"rooms"`
+	Loops     int        `json:"loops"`
+	Fill      int        `json:"fill"`
+	Smooth    int        `json:"smooth"`
+	Vaults    Amount     `json:"vaults"`
+	Terrain   Terrain    `json:"terrain"`
+	Exits     []string   `json:"exits"`
+	Enemies   SpawnTable `json:"enemies"`
+	Items     SpawnTable `json:"items"`
+}
+
+// levels собирает рецепты уровней (по возрастанию глубины) и проверяет,
+// что их диапазоны стыкуются без дыр и пересечений
+func (l *contentLoader) levels(c *Content) []LevelRecipe {
+	var result []LevelRecipe
+	for _, key := range l.keys(SectionLevels) {
+		var def recipeDef
+		if !l.decode(SectionLevels, key, &def) {
+			continue
+		}
+
+		var errs []string
+		if def.MinDepth < 1 {
+			errs = append(errs, "minDepth must be at least 1")
+		}
+		if def.MaxDepth != 0 && def.MaxDepth < def.MinDepth {
+			errs = append(errs, "maxDepth must be 0 (no limit) or not less than minDepth")
+		}
+		switch def.Generator {
+		case GeneratorRooms:
+			if def.Rooms <= 0 {
+				errs = append(errs, "rooms must be positive")
+			}
+		case GeneratorBSP:
+			if def.Loops < 0 {
+				errs = append(errs, "loops must not be negative")
+			}
+		case GeneratorCaves:
+			if def.Fill <= 0 || def.Fill >= 100 {
+				errs = append(errs, "fill must be within 1..99")
+			}
+			if def.Smooth < 0 {
+				errs = append(errs, "smooth must not be negative")
+			}
+		default:
+			errs = append(errs, fmt.Sprintf("unknown generator %q (expected %s, %s or %s)",
+				def.Generator, GeneratorRooms, GeneratorBSP, GeneratorCaves))
+		}
+		for _, dir := range def.Exits {
+			if dir != "up" && dir != "down" {
+				errs = append(errs, fmt.Sprintf("unknown exit %q (expected up or down)", dir))
+			}
+		}
+		errs = append(errs, checkAmount("vaults", def.Vaults)...)
+		errs = append(errs, checkAmount("terrain.water", def.Terrain.Water)...)
+		errs = append(errs, checkAmount("terrain.lava", def.Terrain.Lava)...)
+		errs = append(errs, checkAmount("terrain.chasms", def.Terrain.Chasms)...)
+		errs = append(errs, checkAmount("terrain.traps", def.Terrain.Traps)...)
+		errs = append(errs, checkSpawnTable(SectionEnemies, def.Enemies, c.Enemies, true)...)
+		errs = append(errs, checkSpawnTable(SectionItems, def.Items, c.Items, false)...)
+
+		if l.report(l.where(SectionLevels, key), errs) {
+			continue
+		}
+		result = append(result, LevelRecipe{
+			ID:        key,
+			MinDepth:  def.MinDepth,
+			MaxDepth:  def.MaxDepth,
+			Generator: def.Generator,
+			Rooms:     def.Rooms,
+			Loops:     def.Loops,
+			Fill:      def.Fill,
+			Smooth:    def.Smooth,
+			Vaults:    def.Vaults,
+			Terrain:   def.Terrain,
+			Exits:     def.Exits,
+			Enemies:   def.Enemies,
+			Items:     def.Items,
+		})
+	}
+
+	// Ключи уже по алфавиту, устойчивая сортировка сохраняет этот порядок при равной глубине
+	sort.SliceStable(result, func(i, j int) bool { return result[i].MinDepth < result[j].MinDepth })
+	bands := make([]depthBand, len(result))
+	for i, r := range result {
+		bands[i] = depthBand{r.ID, r.MinDepth, r.MaxDepth}
+	}
+	l.checkBands(SectionLevels, "recipe", bands)
+	return result
+}
+
+// depthBand - диапазон глубин записи раздела (MaxDepth 0 - без ограничения)
+type depthBand struct {
+	ID                 string
+	MinDepth, MaxDepth int
+}
+
+// checkBands проверяет, что отсортированные по MinDepth диапазоны стыкуются
+// без дыр и пересечений и покрывают все глубины с первой
+func (l *contentLoader) checkBands(section, what string, bands []depthBand) {
+	next := 1 // Первая глубина, еще не покрытая диапазонами (0 - покрыты все)
+	for i, b := range bands {
+		switch {
+		case next == 0 || b.MinDepth < next:
+			l.errs = append(l.errs, fmt.Errorf("%s: %s and %s overlap at depth %d",
+				section, bands[i-1].ID, b.ID, b.MinDepth))
+		case b.MinDepth > next:
+			l.errs = append(l.errs, fmt.Errorf("%s: no %s for depths %d..%d", section, what, next, b.MinDepth-1))
+		}
+		if next != 0 {
+			next = b.MaxDepth + 1
+			if b.MaxDepth == 0 {
+				next = 0
+			}
+		}
+	}
+	// Если все записи раздела отброшены из-за ошибок, о дырах не сообщаем - причина уже в ошибках
+	if next != 0 && (len(bands) > 0 || len(l.sections[section]) == 0) {
+		l.errs = append(l.errs, fmt.Errorf("%s: no %s for depths from %d on (the deepest %s needs maxDepth 0)",
+			section, what, next, what))
+	}
+}
+
+func checkAmount(name string, a Amount) []string {
+	if a.Min < 0 || a.Max < 0 || a.DepthStep < 0 {
+		return []string{name + " must not be negative"}
+	}
+	if a.Max != 0 && a.Max < a.Min {
+		return []string{name + ".max must be 0 or not less than min"}
+	}
+	return nil
+}
+
+// checkSpawnTable проверяет таблицу спавна. Без таблицы врагов случайных врагов взять неоткуда,
+// случайные предметы без таблицы берутся из таблицы лута глубины.
+func checkSpawnTable[T any](section string, t SpawnTable, templates map[string]T, needTable bool) []string {
+	errs := checkAmount(section+".count", t.Count)
+	for _, e := range t.Fixed {
+		if _, ok := templates[e.Template]; !ok {
+			errs = append(errs, fmt.Sprintf("%s.fixed: unknown template %q", section, e.Template))
+		}
+		if e.Count <= 0 {
+			errs = append(errs, fmt.Sprintf("%s.fixed %q: count must be positive", section, e.Template))
+		}
+	}
+	for _, e := range t.Table {
+		if _, ok := templates[e.Template]; !ok {
+			errs = append(errs, fmt.Sprintf("%s.table: unknown template %q", section, e.Template))
+		}
+		if e.Weight <= 0 {
+			errs = append(errs, fmt.Sprintf("%s.table %q: weight must be positive", section, e.Template))
+		}
+	}
+	if needTable && len(t.Table) == 0 && (t.Count.Min > 0 || t.Count.Max > 0 || t.Count.DepthStep > 0) {
+		errs = append(errs, section+".count is set but the table is empty")
+	}
+	return errs
+}
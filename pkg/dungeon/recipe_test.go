@@ -0,0 +1,101 @@
+package dungeon
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// depthWith - первая глубина, которую базовый контент строит генератором generator
+func depthWith(t *testing.T, generator string) int {
+	t.Helper()
+	for _, r := range Templates().Levels {
+		if r.Generator == generator {
+			return r.MinDepth
+		}
+	}
+	t.Fatalf("no built-in recipe uses %s", generator)
+	return 0
+}
+
+func TestRecipeFor_CoversAllDepths(t *testing.T) {
+	content := Templates()
+	for depth := 1; depth <= 50; depth++ {
+		if _, ok := content.RecipeFor(depth); !ok {
+			t.Fatalf("no recipe for depth %d", depth)
+		}
+	}
+	if got := RecipeName(1); got != "entry_dungeon/v8/"+content.Fingerprint {
+		t.Errorf("RecipeName(1) = %q", got)
+	}
+}
+
+func TestRecipe_EntryLevelSpawnsFixedSet(t *testing.T) {
+	_, entities, _ := Generate(1, rand.New(rand.NewSource(42)))
+	counts := make(map[string]int)
+	for _, e := range entities {
+		counts[e.Name]++
+	}
+	items := Templates().Items
+	enemies := Templates().Enemies
+	want := map[string]int{
+		enemies["goblin"].Name:      3,
+		enemies["orc"].Name:         1,
+		items["health_potion"].Name: 2,
+		items["bread"].Name:         3,
+		items["leather_armor"].Name: 1,
+		items["steel_dagger"].Name:  1,
+	}
+	for name, n := range want {
+		if counts[name] != n {
+			t.Errorf("%s: %d spawned, want %d", name, counts[name], n)
+		}
+	}
+}
+
+func TestLoadContent_RecipeErrors(t *testing.T) {
+	dir := t.TempDir()
+	writePack(t, dir, "levels.json", `{"levels": {
+		"dungeon": {"maxDepth": 2},
+		"mines": {"minDepth": 3, "maxDepth": 3, "generator": "mines", "exits": ["sideways"],
+			"enemies": {"count": {"min": 2}, "fixed": [{"template": "dragon", "count": 1}]},
+			"items": {"table": [{"template": "bread"}]}},
+		"abyss": {"minDepth": 6, "generator": "caves", "fill": 100, "smooth": 4}
+	}}`)
+
+	_, err := LoadContent(dir)
+	if err == nil {
+		t.Fatal("broken recipes accepted")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		`levels.mines: unknown generator "mines"`,
+		`levels.mines: unknown exit "sideways"`,
+		`levels.mines: enemies.fixed: unknown template "dragon"`,
+		"levels.mines: enemies.count is set but the table is empty",
+		`levels.mines: items.table "bread": weight must be positive`,
+		"levels.abyss: fill must be within 1..99",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error does not mention %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestLoadContent_RecipeGaps(t *testing.T) {
+	dir := t.TempDir()
+	writePack(t, dir, "levels.json", `{"levels": {
+		"dungeon": {"extends": "entry_dungeon", "minDepth": 3, "maxDepth": 3},
+		"caves": {"extends": "dungeon", "minDepth": 3, "maxDepth": 0}
+	}}`)
+
+	_, err := LoadContent(dir)
+	if err == nil {
+		t.Fatal("gap and overlap accepted")
+	}
+	for _, want := range []string{"no recipe for depths 2..2", "overlap at depth 3"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q:\n%s", want, err)
+		}
+	}
+}
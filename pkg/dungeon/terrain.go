@@ -0,0 +1,135 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+)
+
+// Размер пятна воды, лавы или пропасти (клеток)
+const (
+	TerrainPoolMin = 3
+	TerrainPoolMax = 9
+)
+
+// Terrain - особая местность уровня из рецепта: сколько пятен каждого типа
+// и сколько одиночных скрытых ловушек
+type Terrain struct {
+	Water  Amount `json:"water"`
+	Lava   Amount `json:"lava"`
+	Chasms Amount `json:"chasms"`
+	Traps  Amount `json:"traps"`
+}
+
+// WithTerrain разливает воду, лаву и пропасти пятнами по полу и прячет ловушки.
+// Старт, место спуска и хранилища не трогаются. Пятно, которое отрезало бы часть уровня
+// от старта, не ставится. Шаг идет после хранилищ и до выходов и спавна.
+func (b *LevelBuilder) WithTerrain(t Terrain) *LevelBuilder {
+	return b.apply(func() { b.withTerrain(t) })
+}
+
+func (b *LevelBuilder) withTerrain(t Terrain) {
+	if b.gameMap == nil {
+		return
+	}
+	for _, layer := range []struct {
+		tile   domain.TileType
+		amount Amount
+	}{
+		{domain.TileWater, t.Water},
+		{domain.TileLava, t.Lava},
+		{domain.TileChasm, t.Chasms},
+	} {
+		for range layer.amount.Roll(b.level, b.rng) {
+			b.placePool(layer.tile)
+		}
+	}
+	for range t.Traps.Roll(b.level, b.rng) {
+		if pos, ok := b.terrainSpot(); ok {
+			b.gameMap[pos.Y][pos.X].SetType(domain.TileTrap)
+		}
+	}
+}
+
+// placePool выращивает пятно из случайной клетки пола. Непроходимое пятно (лава, пропасть)
+// откатывается, если после него от старта достижимо меньше клеток, чем должно.
+func (b *LevelBuilder) placePool(tile domain.TileType) {
+	seed, ok := b.terrainSpot()
+	if !ok {
+		return
+	}
+
+	reachable := 0
+	if !pathable(domain.Tile{Type: tile}) {
+		reachable = b.reachableCount()
+	}
+
+	size := b.randRange(TerrainPoolMin, TerrainPoolMax)
+	pool := []domain.Position{seed}
+	in := map[domain.Position]bool{seed: true}
+	for k := 0; len(pool) < size && k < size*4; k++ {
+		from := pool[b.rng.Intn(len(pool))]
+		d := [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}[b.rng.Intn(4)]
+		next := from.Shift(d[0], d[1])
+		if !in[next] && b.terrainAllowed(next) {
+			in[next] = true
+			pool = append(pool, next)
+		}
+	}
+
+	for _, p := range pool {
+		b.gameMap[p.Y][p.X].SetType(tile)
+	}
+	if reachable > 0 && b.reachableCount() < reachable-len(pool) {
+		for _, p := range pool {
+			b.gameMap[p.Y][p.X].SetType(domain.TileFloor)
+		}
+	}
+}
+
+// terrainSpot - случайная клетка, подходящая под местность (макс 20 попыток)
+func (b *LevelBuilder) terrainSpot() (domain.Position, bool) {
+	for range 20 {
+		pos := domain.Position{X: b.randRange(1, b.width-2), Y: b.randRange(1, b.height-2)}
+		if b.terrainAllowed(pos) {
+			return pos, true
+		}
+	}
+	return domain.Position{}, false
+}
+
+// terrainAllowed - обычный свободный пол вне хранилищ и не рядом со стартом и спуском
+func (b *LevelBuilder) terrainAllowed(pos domain.Position) bool {
+	if pos.X < 1 || pos.Y < 1 || pos.X >= b.width-1 || pos.Y >= b.height-1 {
+		return false
+	}
+	if b.gameMap[pos.Y][pos.X].Type != domain.TileFloor || b.occupied(pos) {
+		return false
+	}
+	for _, v := range b.vaults {
+		if pos.X >= v.X && pos.X < v.X+v.W && pos.Y >= v.Y && pos.Y < v.Y+v.H {
+			return false
+		}
+	}
+	keep := []domain.Position{b.GetStartPos(), b.far}
+	if len(b.rooms) > 0 {
+		keep = append(keep, roomCenter(b.rooms[len(b.rooms)-1]))
+	}
+	for _, k := range keep {
+		if abs(pos.X-k.X) <= 1 && abs(pos.Y-k.Y) <= 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// reachableCount - сколько клеток достижимо от старта
+func (b *LevelBuilder) reachableCount() int {
+	n := 0
+	for _, row := range b.distancesFrom(b.GetStartPos()) {
+		for _, d := range row {
+			if d >= 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
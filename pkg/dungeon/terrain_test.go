@@ -0,0 +1,38 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"math/rand"
+	"testing"
+)
+
+// Лава и пропасти не должны отрезать части уровня от старта
+func TestWithTerrain_KeepsLevelConnected(t *testing.T) {
+	placed := 0
+	for seed := int64(1); seed <= 100; seed++ {
+		b := NewLevel(3, rand.New(rand.NewSource(seed)))
+		b.WithSize(MapWidth, MapHeight).WithRooms(MaxRooms)
+		before := b.distancesFrom(b.GetStartPos())
+
+		b.WithTerrain(Terrain{Lava: Amount{Min: 10}, Chasms: Amount{Min: 10}, Traps: Amount{Min: 5}})
+		after := b.distancesFrom(b.GetStartPos())
+		for y := range b.height {
+			for x := range b.width {
+				tile := b.gameMap[y][x]
+				if tile.Type == domain.TileLava || tile.Type == domain.TileChasm {
+					placed++
+					continue
+				}
+				if before[y][x] >= 0 && after[y][x] < 0 {
+					t.Fatalf("seed %d: %d,%d (%s) cut off from start", seed, x, y, tile.Type)
+				}
+			}
+		}
+		if start := b.GetStartPos(); b.gameMap[start.Y][start.X].Type != domain.TileFloor {
+			t.Errorf("seed %d: start covered by %s", seed, b.gameMap[start.Y][start.X].Type)
+		}
+	}
+	if placed == 0 {
+		t.Error("no hazardous terrain placed at all")
+	}
+}
@@ -0,0 +1,217 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"encoding/json"
+	"fmt"
+)
+
+// Параметры города на поверхности
+const (
+	TownStreetWidth = 2
+	TownMinHouse    = 5 // Сторона дома вместе со стенами
+	TownMaxHouse    = 9
+	TownVillagers   = 4
+)
+
+// Назначения домов. Остальные дома жилые.
+const (
+	townShop = iota
+	townHealer
+	townCrypt // Склеп со спуском в подземелье
+	townRoles
+)
+
+// townHouse - дом города: стены по краю прямоугольника, дверь смотрит на главную улицу
+type townHouse struct {
+	X, Y, W, H int // Включая стены
+	Door       domain.Position
+}
+
+func (h townHouse) center() domain.Position {
+	return domain.Position{X: h.X + h.W/2, Y: h.Y + h.H/2}
+}
+
+// WithTown строит город на поверхности: главная улица и поперечная, между ними кварталы
+// с домами (дверь каждого выходит к главной улице), на перекрестке колодец или святилище.
+// Три случайных дома отдаются под лавку торговца, дом знахаря и склеп со спуском в подземелье,
+// по улицам бродят горожане. Старт - на перекрестке.
+func (b *LevelBuilder) WithTown() *LevelBuilder {
+	b.exits = append(b.exits, exitSpec{direction: "down", target: 1})
+	return b.apply(b.withTown)
+}
+
+func (b *LevelBuilder) withTown() {
+	b.fillWalls()
+	b.rooms = nil
+	for y := 1; y < b.height-1; y++ {
+		for x := 1; x < b.width-1; x++ {
+			b.gameMap[y][x].SetType(domain.TileGrass)
+		}
+	}
+
+	// Улицы: главная горизонтальная и поперечная, пересекаются около центра
+	sy := b.randRange(b.height/2-2, b.height/2)
+	sx := b.randRange(b.width/2-3, b.width/2+1)
+	for y := sy; y < sy+TownStreetWidth; y++ {
+		for x := 1; x < b.width-1; x++ {
+			b.gameMap[y][x].SetType(domain.TileStreet)
+		}
+	}
+	for x := sx; x < sx+TownStreetWidth; x++ {
+		for y := 1; y < b.height-1; y++ {
+			b.gameMap[y][x].SetType(domain.TileStreet)
+		}
+	}
+
+	// Кварталы между улицами и частоколом; ряд травы вдоль улиц и у частокола остается свободным
+	north, south := sy-1, sy+TownStreetWidth
+	var houses []townHouse
+	for _, block := range []struct {
+		x0, x1  int
+		facingS bool // Квартал над главной улицей: двери в нижней стене
+	}{
+		{1, sx - 2, true}, {sx + TownStreetWidth, b.width - 3, true},
+		{1, sx - 2, false}, {sx + TownStreetWidth, b.width - 3, false},
+	} {
+		for x := block.x0 + 1; x+TownMinHouse-1 <= block.x1; {
+			w := b.randRange(TownMinHouse, min(TownMaxHouse, block.x1-x+1))
+			var h townHouse
+			if block.facingS {
+				hh := b.randRange(TownMinHouse, min(TownMaxHouse, north-2))
+				h = townHouse{X: x, Y: north - hh, W: w, H: hh}
+				h.Door = domain.Position{X: b.randRange(x+1, x+w-2), Y: north - 1}
+			} else {
+				hh := b.randRange(TownMinHouse, min(TownMaxHouse, b.height-2-(south+1)))
+				h = townHouse{X: x, Y: south + 1, W: w, H: hh}
+				h.Door = domain.Position{X: b.randRange(x+1, x+w-2), Y: south + 1}
+			}
+			b.buildHouse(h)
+			houses = append(houses, h)
+			x += w + 1 + b.rng.Intn(2)
+		}
+	}
+
+	b.start = domain.Position{X: sx, Y: sy}
+	b.floor = b.floor[:0]
+	for y := 1; y < b.height-1; y++ {
+		for x := 1; x < b.width-1; x++ {
+			if b.gameMap[y][x].Type == domain.TileStreet {
+				b.floor = append(b.floor, domain.Position{X: x, Y: y})
+			}
+		}
+	}
+
+	b.placeTownLandmarks(houses, domain.Position{X: sx - 1, Y: sy - 1})
+}
+
+// buildHouse ставит стены дома, пол внутри и дверной проем
+func (b *LevelBuilder) buildHouse(h townHouse) {
+	for y := h.Y; y < h.Y+h.H; y++ {
+		for x := h.X; x < h.X+h.W; x++ {
+			edge := x == h.X || y == h.Y || x == h.X+h.W-1 || y == h.Y+h.H-1
+			if edge {
+				b.gameMap[y][x].SetType(domain.TileStone)
+			} else {
+				b.gameMap[y][x].SetType(domain.TileFloor)
+			}
+		}
+	}
+	b.gameMap[h.Door.Y][h.Door.X].SetType(domain.TileDoorClosed)
+}
+
+// placeTownLandmarks раздает дома под лавку, знахаря и склеп, ставит колодец или святилище
+// у перекрестка и выпускает горожан на улицы
+func (b *LevelBuilder) placeTownLandmarks(houses []townHouse, plaza domain.Position) {
+	if len(houses) < townRoles {
+		return // Validate не найдет спуска, но такого города при наших размерах не бывает
+	}
+	order := b.rng.Perm(len(houses))
+
+	shop := houses[order[townShop]]
+	b.spawnTownNPC("merchant", shop.center())
+
+	healer := houses[order[townHealer]]
+	if npc := b.spawnTownNPC("healer", healer.center()); npc != nil {
+		npc.Trigger = &domain.TriggerComponent{OnInteract: restoreEvent(0, 0,
+			"Знахарка перевязывает раны и дает выпить горького отвара. Вы полностью здоровы.")}
+	}
+
+	crypt := houses[order[townCrypt]]
+	b.placeTownEntrance(crypt.center())
+
+	well := domain.Entity{
+		ID:     "o_town_well",
+		Type:   domain.EntityTypeObject,
+		Name:   "Колодец",
+		Pos:    plaza,
+		Level:  b.level,
+		Render: &domain.RenderComponent{Symbol: 'O', Color: "#60A5FA"},
+		Narrative: &domain.NarrativeComponent{
+			Description: "Старый каменный колодец с ледяной водой.",
+		},
+		Trigger: &domain.TriggerComponent{OnInteract: restoreEvent(5, 0, "Холодная вода освежает.")},
+	}
+	if b.rng.Intn(2) == 0 {
+		well.ID = "o_town_shrine"
+		well.Name = "Святилище"
+		well.Render = &domain.RenderComponent{Symbol: '_', Color: "#FDE68A"}
+		well.Narrative = &domain.NarrativeComponent{Description: "Придорожное святилище, у подножия горят свечи."}
+		well.Trigger = &domain.TriggerComponent{OnInteract: restoreEvent(0, 0, "Тепло святилища возвращает силы.")}
+	}
+	b.entities = append(b.entities, well)
+
+	// Горожане - на свободных клетках улиц, не на самом перекрестке
+	taken := map[domain.Position]bool{b.start: true}
+	for range TownVillagers {
+		for range 20 {
+			pos := b.floor[b.rng.Intn(len(b.floor))]
+			if abs(pos.X-b.start.X)+abs(pos.Y-b.start.Y) < 3 || taken[pos] {
+				continue
+			}
+			taken[pos] = true
+			b.spawnTownNPC("villager", pos)
+			break
+		}
+	}
+}
+
+// spawnTownNPC ставит NPC по шаблону, если он есть в контенте
+func (b *LevelBuilder) spawnTownNPC(key string, pos domain.Position) *domain.Entity {
+	t, ok := b.content.NPCs[key]
+	if !ok {
+		return nil
+	}
+	b.entities = append(b.entities, t.SpawnEntity(pos, b.level, b.rng))
+	return &b.entities[len(b.entities)-1]
+}
+
+// placeTownEntrance ставит спуск в подземелье (его ID ждет подъем с первого уровня)
+func (b *LevelBuilder) placeTownEntrance(pos domain.Position) {
+	eventPayload, _ := json.Marshal(map[string]interface{}{
+		"event":       "LEVEL_TRANSITION",
+		"targetLevel": 1,
+		"targetPosId": fmt.Sprintf("exit_up_from_%d", 1),
+	})
+	b.entities = append(b.entities, domain.Entity{
+		ID:        fmt.Sprintf("exit_down_from_%d", 0),
+		Type:      domain.EntityTypeExit,
+		Name:      "Спуск в подземелье",
+		Pos:       pos,
+		Level:     b.level,
+		Render:    &domain.RenderComponent{Symbol: '>', Color: "#FFFFFF"},
+		Narrative: &domain.NarrativeComponent{Description: "Темный проход под старым склепом, ведущий вглубь подземелья."},
+		Trigger:   &domain.TriggerComponent{OnInteract: eventPayload},
+	})
+}
+
+// restoreEvent - событие RESTORE (0 - до максимума)
+func restoreEvent(hp, stamina int, message string) json.RawMessage {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"event":   "RESTORE",
+		"hp":      hp,
+		"stamina": stamina,
+		"message": message,
+	})
+	return payload
+}
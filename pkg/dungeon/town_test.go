@@ -0,0 +1,56 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"math/rand"
+	"testing"
+)
+
+func TestGenerateSurface_Town(t *testing.T) {
+	content := Templates()
+	for seed := int64(1); seed <= 200; seed++ {
+		b := NewLevel(0, rand.New(rand.NewSource(seed)))
+		world, entities, start := b.WithSize(MapWidth, MapHeight).WithTown().Build()
+		if b.attempts != 1 {
+			t.Errorf("seed %d: town rebuilt %d times: %v", seed, b.attempts, b.failures)
+		}
+		if world.Map[start.Y][start.X].Type != domain.TileStreet {
+			t.Errorf("seed %d: start %v is not on a street", seed, start)
+		}
+
+		counts := make(map[string]int)
+		for _, e := range entities {
+			switch e.Type {
+			case domain.EntityTypeNPC:
+				counts[e.Name]++
+				if e.AI.IsHostile {
+					t.Errorf("seed %d: hostile %s in town", seed, e.Name)
+				}
+			case domain.EntityTypeExit:
+				counts[e.ID]++
+				// Спуск - внутри дома
+				if tile := world.Map[e.Pos.Y][e.Pos.X].Type; tile != domain.TileFloor {
+					t.Errorf("seed %d: dungeon entrance on %s", seed, tile)
+				}
+			case domain.EntityTypeObject:
+				counts["landmark"]++
+			}
+		}
+		villager := content.NPCs["villager"].Name
+		if counts[content.NPCs["merchant"].Name] != 1 || counts[content.NPCs["healer"].Name] != 1 ||
+			counts["exit_down_from_0"] != 1 || counts["landmark"] != 1 || counts[villager] < TownVillagers-1 {
+			t.Errorf("seed %d: town has %v", seed, counts)
+		}
+	}
+}
+
+func TestSpawnEntity_Wanderer(t *testing.T) {
+	villager := Templates().NPCs["villager"].SpawnEntity(domain.Position{}, 0, rand.New(rand.NewSource(1)))
+	if villager.AI.State != domain.AIStateWander {
+		t.Errorf("villager AI state = %d, want wander", villager.AI.State)
+	}
+	merchant := Templates().NPCs["merchant"].SpawnEntity(domain.Position{}, 0, rand.New(rand.NewSource(1)))
+	if merchant.AI.State != domain.AIStateIdle {
+		t.Errorf("merchant AI state = %d, want idle", merchant.AI.State)
+	}
+}
@@ -0,0 +1,120 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"errors"
+	"fmt"
+)
+
+// Ограничения проверки уровня
+const (
+	MaxBuildAttempts = 10 // Сколько раз Build пересобирает уровень, прежде чем сдаться
+	MinEnemyDistance = 4  // Ближе этого (в шагах) к старту врагов быть не должно
+	MinExitDistance  = 8  // Лестницы вверх и вниз не ближе этого друг к другу
+)
+
+// Причины, по которым уровень не прошел проверку
+var (
+	ErrBlockedTile   = errors.New("blocked tile")
+	ErrUnreachable   = errors.New("unreachable from start")
+	ErrSpawnOverlap  = errors.New("entities share a tile")
+	ErrSpawnTooClose = errors.New("enemy too close to start")
+	ErrExitPlacement = errors.New("exits too close")
+	ErrMissingExit   = errors.New("required exit missing")
+)
+
+// Validate проверяет собранный уровень: старт и все сущности стоят на проходимых клетках
+// и достижимы от старта, каждая сущность на своей клетке, враги не у старта,
+// все лестницы рецепта на месте, лестницы вверх и вниз разнесены. Возвращает первую найденную проблему.
+func (b *LevelBuilder) Validate() error {
+	start := b.GetStartPos()
+	if !b.walkable(start) {
+		return fmt.Errorf("%w: start %d,%d", ErrBlockedTile, start.X, start.Y)
+	}
+	dist := b.distancesFrom(start)
+
+	taken := make(map[domain.Position]string, len(b.entities))
+	var up, down *domain.Entity
+	for k := range b.entities {
+		e := &b.entities[k]
+		if !b.walkable(e.Pos) {
+			return fmt.Errorf("%w: %s at %d,%d", ErrBlockedTile, e.ID, e.Pos.X, e.Pos.Y)
+		}
+		if dist[e.Pos.Y][e.Pos.X] < 0 {
+			return fmt.Errorf("%w: %s at %d,%d", ErrUnreachable, e.ID, e.Pos.X, e.Pos.Y)
+		}
+		if other, ok := taken[e.Pos]; ok {
+			return fmt.Errorf("%w: %s and %s at %d,%d", ErrSpawnOverlap, other, e.ID, e.Pos.X, e.Pos.Y)
+		}
+		taken[e.Pos] = e.ID
+
+		switch {
+		case e.Type == domain.EntityTypeEnemy && dist[e.Pos.Y][e.Pos.X] < MinEnemyDistance:
+			return fmt.Errorf("%w: %s is %d steps away", ErrSpawnTooClose, e.ID, dist[e.Pos.Y][e.Pos.X])
+		case e.Type == domain.EntityTypeExit && e.Render != nil && e.Render.Symbol == '<':
+			up = e
+		case e.Type == domain.EntityTypeExit && e.Render != nil && e.Render.Symbol == '>':
+			down = e
+		}
+	}
+
+	for _, exit := range b.exits {
+		if (exit.direction == "up" && up == nil) || (exit.direction == "down" && down == nil) {
+			return fmt.Errorf("%w: %s", ErrMissingExit, exit.direction)
+		}
+	}
+
+	if up != nil && down != nil {
+		steps := b.distancesFrom(up.Pos)[down.Pos.Y][down.Pos.X]
+		if steps < MinExitDistance {
+			return fmt.Errorf("%w: %d steps apart", ErrExitPlacement, steps)
+		}
+	}
+	return nil
+}
+
+// walkable - клетка внутри карты и не стена
+func (b *LevelBuilder) walkable(pos domain.Position) bool {
+	if pos.X < 0 || pos.Y < 0 || pos.X >= b.width || pos.Y >= b.height {
+		return false
+	}
+	return !b.gameMap[pos.Y][pos.X].IsWall
+}
+
+// pathable - можно ли пройти через клетку: закрытую дверь игрок откроет, ловушку переживет,
+// а лава и пропасть путем не считаются
+func pathable(tile domain.Tile) bool {
+	def := tile.Type.Def()
+	if def.OnEnter == domain.TileEffectBurn || def.OnEnter == domain.TileEffectFall {
+		return false
+	}
+	return def.Walkable || def.Toggles
+}
+
+// distancesFrom считает шаги от start до каждой клетки (-1 - недостижима)
+func (b *LevelBuilder) distancesFrom(start domain.Position) [][]int {
+	dist := make([][]int, b.height)
+	for y := range dist {
+		dist[y] = make([]int, b.width)
+		for x := range dist[y] {
+			dist[y][x] = -1
+		}
+	}
+	dist[start.Y][start.X] = 0
+	queue := []domain.Position{start}
+	for k := 0; k < len(queue); k++ {
+		p := queue[k]
+		for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			nx, ny := p.X+d[0], p.Y+d[1]
+			if nx < 0 || ny < 0 || nx >= b.width || ny >= b.height {
+				continue
+			}
+			if dist[ny][nx] >= 0 || !pathable(b.gameMap[ny][nx]) {
+				continue
+			}
+			dist[ny][nx] = dist[p.Y][p.X] + 1
+			queue = append(queue, domain.Position{X: nx, Y: ny})
+		}
+	}
+	return dist
+}
@@ -0,0 +1,118 @@
+package dungeon
+
+import (
+	"cognitive-server/pkg/logger"
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TestBuild_ValidOverManySeeds собирает уровни разных глубин на тысячах сидов и печатает,
+// как часто приходилось пересобирать уровень и почему (go test -v).
+func TestBuild_ValidOverManySeeds(t *testing.T) {
+	seeds := 2000
+	if testing.Short() {
+		seeds = 200
+	}
+	reasons := []error{ErrBlockedTile, ErrUnreachable, ErrSpawnOverlap, ErrSpawnTooClose, ErrExitPlacement, ErrMissingExit}
+
+	caves := depthWith(t, GeneratorCaves)
+	for _, level := range []int{1, 2, depthWith(t, GeneratorBSP), caves, caves + 3} {
+		retried, worst := 0, 0
+		failures := make(map[error]int)
+		for seed := int64(1); seed <= int64(seeds); seed++ {
+			b := dungeonRecipe(level, rand.New(rand.NewSource(seed)))
+			b.Build()
+
+			if err := b.Validate(); err != nil {
+				t.Errorf("level %d seed %d: still invalid after %d attempts: %v", level, seed, b.attempts, err)
+			}
+			if b.attempts > 1 {
+				retried++
+			}
+			worst = max(worst, b.attempts)
+			for _, err := range b.failures {
+				for _, reason := range reasons {
+					if errors.Is(err, reason) {
+						failures[reason]++
+					}
+				}
+			}
+		}
+
+		t.Logf("level %d: %d/%d seeds retried (%.1f%%), max attempts %d", level, retried, seeds,
+			100*float64(retried)/float64(seeds), worst)
+		for _, reason := range reasons {
+			if n := failures[reason]; n > 0 {
+				t.Logf("    %-28s %d", reason, n)
+			}
+		}
+	}
+}
+
+func TestBuild_RetryIsDeterministic(t *testing.T) {
+	// Ищем сид, на котором понадобилась пересборка, и собираем его еще раз
+	for seed := int64(1); seed <= 500; seed++ {
+		b := dungeonRecipe(2, rand.New(rand.NewSource(seed)))
+		world, entities, start := b.Build()
+		if b.attempts == 1 {
+			continue
+		}
+
+		again, againEntities, againStart := Generate(2, rand.New(rand.NewSource(seed)))
+		if start != againStart || len(entities) != len(againEntities) {
+			t.Fatalf("seed %d: rebuilt level differs", seed)
+		}
+		for y := range world.Map {
+			for x := range world.Map[y] {
+				if world.Map[y][x] != again.Map[y][x] {
+					t.Fatalf("seed %d: rebuilt map differs at %d,%d", seed, x, y)
+				}
+			}
+		}
+		return
+	}
+	t.Skip("no seed needed a retry")
+}
+
+func TestValidate_MissingExit(t *testing.T) {
+	b := dungeonRecipe(2, rand.New(rand.NewSource(1)))
+	b.Build()
+	for k, e := range b.entities {
+		if e.ID == "exit_down_from_2" {
+			b.entities = append(b.entities[:k], b.entities[k+1:]...)
+			break
+		}
+	}
+	if err := b.Validate(); !errors.Is(err, ErrMissingExit) {
+		t.Fatalf("Validate() = %v, want %v", err, ErrMissingExit)
+	}
+}
+
+// Рецепт, который не собирается ни с какого сида (комнат нет - старт в стене),
+// дает запасной уровень с его лестницами, а не уровень, не прошедший проверку
+func TestBuild_FallbackWhenRecipeNeverValidates(t *testing.T) {
+	logger.Init()
+	logger.Log.SetLevel(logrus.FatalLevel)
+
+	b := NewLevel(2, rand.New(rand.NewSource(1))).WithRooms(0).PlaceExit("up", 1).PlaceExit("down", 3)
+	world, entities, start := b.Build()
+	if b.attempts != MaxBuildAttempts {
+		t.Errorf("attempts = %d, want %d", b.attempts, MaxBuildAttempts)
+	}
+	if err := b.Validate(); err != nil {
+		t.Fatalf("fallback level is invalid: %v", err)
+	}
+	if world.Map[start.Y][start.X].IsWall {
+		t.Error("start is in a wall")
+	}
+	ids := make(map[string]bool)
+	for _, e := range entities {
+		ids[e.ID] = true
+	}
+	if !ids["exit_up_from_2"] || !ids["exit_down_from_2"] || len(entities) != 2 {
+		t.Errorf("fallback entities = %v, want only both exits", ids)
+	}
+}
@@ -0,0 +1,503 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"cognitive-server/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Клетки макета хранилища, которые не нужно описывать в легенде
+const (
+	VaultWall  = '#'
+	VaultFloor = '.'
+	VaultDoor  = '+'
+	VaultEmpty = ' ' // Клетка не принадлежит хранилищу, карта под ней не меняется
+)
+
+// Vault - заготовка комнаты из файла контента: ASCII-макет и легенда маркеров.
+// WithVaults вырезает ее в сплошной породе и прокладывает коридоры от дверей к уровню.
+type Vault struct {
+	ID       string
+	Layout   []string
+	Legend   map[byte]VaultMarker
+	MinDepth int
+	MaxDepth int  // 0 - без ограничения
+	Weight   int  // Вес при выборе среди хранилищ, подходящих по глубине
+	Fixed    bool // Не вращать и не отражать (например, надписи)
+}
+
+// VaultMarker - что стоит на клетке маркера. Заполнено ровно одно поле.
+type VaultMarker struct {
+	Enemy        string
+	NPC          string
+	Item         string
+	SentientItem string
+	Trigger      *VaultTrigger
+}
+
+// VaultTrigger - неподвижный объект с событием по INTERACT (алтарь, рычаг)
+type VaultTrigger struct {
+	Name        string
+	Render      domain.RenderComponent
+	Description string
+	Event       json.RawMessage
+}
+
+// AllowedAt - подходит ли хранилище для уровня depth
+func (v Vault) AllowedAt(depth int) bool {
+	return depth >= v.MinDepth && (v.MaxDepth == 0 || depth <= v.MaxDepth)
+}
+
+// WithVaults вырезает до count хранилищ, выбирая их по весу среди подходящих по глубине.
+// Хранилище ставится только в сплошную породу, поэтому комнаты и пещеры уровня не портятся.
+// Существа и предметы хранилища спавнятся из шаблонов, как и обычные.
+func (b *LevelBuilder) WithVaults(count int) *LevelBuilder {
+	return b.apply(func() { b.withVaults(count) })
+}
+
+func (b *LevelBuilder) withVaults(count int) {
+	candidates := b.content.VaultsAt(b.level)
+	total := 0
+	for _, v := range candidates {
+		total += v.Weight
+	}
+	if total == 0 {
+		return
+	}
+
+	for range count {
+		roll := b.rng.Intn(total)
+		var vault Vault
+		for _, v := range candidates {
+			if roll < v.Weight {
+				vault = v
+				break
+			}
+			roll -= v.Weight
+		}
+		b.stampVault(vault, b.orientVault(vault))
+	}
+}
+
+// VaultsAt возвращает хранилища для глубины depth в порядке ключей (важно для детерминизма)
+func (c *Content) VaultsAt(depth int) []Vault {
+	keys := make([]string, 0, len(c.Vaults))
+	for key, v := range c.Vaults {
+		if v.AllowedAt(depth) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	vaults := make([]Vault, len(keys))
+	for i, key := range keys {
+		vaults[i] = c.Vaults[key]
+	}
+	return vaults
+}
+
+// orientVault поворачивает макет на случайное число четвертей и случайно отражает
+func (b *LevelBuilder) orientVault(v Vault) [][]byte {
+	grid := make([][]byte, len(v.Layout))
+	for y, row := range v.Layout {
+		grid[y] = []byte(row)
+	}
+	if v.Fixed {
+		return grid
+	}
+
+	for range b.rng.Intn(4) {
+		grid = rotateGrid(grid)
+	}
+	if b.rng.Intn(2) == 0 {
+		for _, row := range grid {
+			for l, r := 0, len(row)-1; l < r; l, r = l+1, r-1 {
+				row[l], row[r] = row[r], row[l]
+			}
+		}
+	}
+	return grid
+}
+
+// rotateGrid поворачивает макет на четверть по часовой стрелке
+func rotateGrid(grid [][]byte) [][]byte {
+	h, w := len(grid), len(grid[0])
+	rotated := make([][]byte, w)
+	for y := range rotated {
+		rotated[y] = make([]byte, h)
+		for x := range rotated[y] {
+			rotated[y][x] = grid[h-1-x][y]
+		}
+	}
+	return rotated
+}
+
+// stampVault ищет место в породе (с каймой в клетку), вырезает макет, спавнит маркеры
+// и соединяет двери с уровнем. Не нашлось места - хранилища на уровне не будет.
+func (b *LevelBuilder) stampVault(v Vault, grid [][]byte) {
+	// От края карты не меньше двух клеток: между хранилищем и краем должен пройти коридор от двери
+	h, w := len(grid), len(grid[0])
+	if w+4 > b.width || h+4 > b.height {
+		return
+	}
+
+	// Перебираем все места: в пещерах сплошной породы мало, и случайные пробы ее часто не находят
+	var places []Rect
+	for y := 2; y <= b.height-h-2; y++ {
+		for x := 2; x <= b.width-w-2; x++ {
+			if area := (Rect{X: x, Y: y, W: w, H: h}); b.solidRock(area) {
+				places = append(places, area)
+			}
+		}
+	}
+	if len(places) == 0 {
+		return
+	}
+	area := places[b.rng.Intn(len(places))]
+	b.vaults = append(b.vaults, area)
+
+	var doors []domain.Position
+	for y, row := range grid {
+		for x, c := range row {
+			pos := domain.Position{X: area.X + x, Y: area.Y + y}
+			tile := &b.gameMap[pos.Y][pos.X]
+			switch c {
+			case VaultEmpty:
+			case VaultWall:
+				tile.SetType(domain.TileStone)
+			case VaultFloor:
+				b.setFloor(pos.X, pos.Y)
+			case VaultDoor:
+				tile.SetType(domain.TileDoorOpen)
+				doors = append(doors, pos)
+			default:
+				b.setFloor(pos.X, pos.Y)
+				b.spawnMarker(v.Legend[c], pos)
+			}
+		}
+	}
+
+	for _, door := range doors {
+		b.connectDoor(door)
+	}
+}
+
+// solidRock - область вместе с каймой в клетку целиком из породы и не задевает другие хранилища
+func (b *LevelBuilder) solidRock(area Rect) bool {
+	for _, other := range b.vaults {
+		if area.Intersects(other) {
+			return false
+		}
+	}
+	for y := area.Y - 1; y <= area.Y+area.H; y++ {
+		for x := area.X - 1; x <= area.X+area.W; x++ {
+			if !b.gameMap[y][x].IsWall {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// inVault - клетка внутри одного из вырезанных хранилищ
+func (b *LevelBuilder) inVault(pos domain.Position) bool {
+	for _, v := range b.vaults {
+		if pos.X >= v.X && pos.X < v.X+v.W && pos.Y >= v.Y && pos.Y < v.Y+v.H {
+			return true
+		}
+	}
+	return false
+}
+
+// connectDoor прокладывает кратчайший коридор через породу от двери до ближайшего прохода уровня.
+// Хранилища коридор обходит, чтобы не пробить их стены.
+func (b *LevelBuilder) connectDoor(door domain.Position) {
+	prev := make(map[domain.Position]domain.Position)
+	queue := []domain.Position{door}
+	prev[door] = door
+
+	for k := 0; k < len(queue); k++ {
+		p := queue[k]
+		for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			next := domain.Position{X: p.X + d[0], Y: p.Y + d[1]}
+			if next.X < 1 || next.Y < 1 || next.X >= b.width-1 || next.Y >= b.height-1 {
+				continue
+			}
+			if _, seen := prev[next]; seen || b.inVault(next) {
+				continue
+			}
+			prev[next] = p
+
+			if !b.gameMap[next.Y][next.X].IsWall {
+				for c := p; c != door; c = prev[c] {
+					b.setFloor(c.X, c.Y)
+				}
+				return
+			}
+			queue = append(queue, next)
+		}
+	}
+}
+
+// spawnMarker ставит на клетку то, что описано в легенде
+func (b *LevelBuilder) spawnMarker(m VaultMarker, pos domain.Position) {
+	switch {
+	case m.Enemy != "":
+		if t, ok := b.content.Enemies[m.Enemy]; ok {
+			b.entities = append(b.entities, t.SpawnEntity(pos, b.level, b.rng))
+		}
+	case m.NPC != "":
+		if t, ok := b.content.NPCs[m.NPC]; ok {
+			b.entities = append(b.entities, t.SpawnEntity(pos, b.level, b.rng))
+		}
+	case m.Item != "":
+		if t, ok := b.content.Items[m.Item]; ok {
+			b.entities = append(b.entities, *t.SpawnItem(pos, b.level, b.rng))
+		}
+	case m.SentientItem != "":
+		if t, ok := b.content.SentientItems[m.SentientItem]; ok {
+			b.entities = append(b.entities, *t.SpawnItem(pos, b.level, b.rng))
+		}
+	case m.Trigger != nil:
+		render := m.Trigger.Render
+		b.entities = append(b.entities, domain.Entity{
+			ID:        utils.GenerateDeterministicID(b.rng, "o_"),
+			Type:      domain.EntityTypeObject,
+			Name:      m.Trigger.Name,
+			Pos:       pos,
+			Level:     b.level,
+			Render:    &render,
+			Narrative: &domain.NarrativeComponent{Description: m.Trigger.Description},
+			Trigger:   &domain.TriggerComponent{OnInteract: m.Trigger.Event},
+		})
+	}
+}
+
+// --- Загрузка из файлов контента ---
+
+// vaultDef - хранилище в файле контента
+type vaultDef struct {
+	Extends  string               `json:"extends,omitempty"`
+	Layout   []string             `json:"layout"`
+	Legend   map[string]markerDef `json:"legend"`
+	MinDepth int                  `json:"minDepth"`
+	MaxDepth int                  `json:"maxDepth"`
+	Weight   int                  `json:"weight"`
+	Fixed    bool                 `json:"fixed"`
+}
+
+type markerDef struct {
+	Enemy        string      `json:"enemy,omitempty"`
+	NPC          string      `json:"npc,omitempty"`
+	Item         string      `json:"item,omitempty"`
+	SentientItem string      `json:"sentientItem,omitempty"`
+	Trigger      *triggerDef `json:"trigger,omitempty"`
+}
+
+type triggerDef struct {
+	Name        string          `json:"name"`
+	Symbol      string          `json:"symbol"`
+	Color       string          `json:"color"`
+	Description string          `json:"description"`
+	Event       json.RawMessage `json:"event"`
+}
+
+// vaults собирает хранилища. Шаблоны существ и предметов уже собраны: маркеры проверяются по ним.
+func (l *contentLoader) vaults(c *Content) map[string]Vault {
+	result := make(map[string]Vault)
+	for _, key := range l.keys(SectionVaults) {
+		var def vaultDef
+		if !l.decode(SectionVaults, key, &def) {
+			continue
+		}
+
+		vault := Vault{
+			ID:       key,
+			Layout:   def.Layout,
+			Legend:   make(map[byte]VaultMarker, len(def.Legend)),
+			MinDepth: def.MinDepth,
+			MaxDepth: def.MaxDepth,
+			Weight:   def.Weight,
+			Fixed:    def.Fixed,
+		}
+
+		var errs []string
+		if def.MinDepth < 1 {
+			errs = append(errs, "minDepth must be at least 1")
+		}
+		if def.MaxDepth != 0 && def.MaxDepth < def.MinDepth {
+			errs = append(errs, "maxDepth must be 0 (no limit) or not less than minDepth")
+		}
+		if def.Weight <= 0 {
+			errs = append(errs, "weight must be positive")
+		}
+
+		glyphs := make([]string, 0, len(def.Legend))
+		for glyph := range def.Legend {
+			glyphs = append(glyphs, glyph)
+		}
+		sort.Strings(glyphs)
+		for _, glyph := range glyphs {
+			marker, markerErrs := c.marker(glyph, def.Legend[glyph])
+			errs = append(errs, markerErrs...)
+			if len(markerErrs) == 0 {
+				vault.Legend[glyph[0]] = marker
+			}
+		}
+		errs = append(errs, checkLayout(def.Layout, def.Legend)...)
+
+		if l.report(l.where(SectionVaults, key), errs) {
+			continue
+		}
+		result[key] = vault
+	}
+	return result
+}
+
+// marker проверяет запись легенды и превращает ее в маркер
+func (c *Content) marker(glyph string, def markerDef) (VaultMarker, []string) {
+	where := fmt.Sprintf("legend %q: ", glyph)
+	if len(glyph) != 1 || glyph[0] < '!' || glyph[0] > '~' {
+		return VaultMarker{}, []string{where + "glyph must be a single printable ASCII character"}
+	}
+	if strings.ContainsRune(string([]byte{VaultWall, VaultFloor, VaultDoor}), rune(glyph[0])) {
+		return VaultMarker{}, []string{where + "glyph is reserved for walls, floor and doors"}
+	}
+
+	set := 0
+	for _, v := range []string{def.Enemy, def.NPC, def.Item, def.SentientItem} {
+		if v != "" {
+			set++
+		}
+	}
+	if def.Trigger != nil {
+		set++
+	}
+	if set != 1 {
+		return VaultMarker{}, []string{where + "needs exactly one of enemy, npc, item, sentientItem, trigger"}
+	}
+
+	var errs []string
+	unknown := func(section, key string) {
+		errs = append(errs, fmt.Sprintf("%sunknown %s template %q", where, section, key))
+	}
+	switch {
+	case def.Enemy != "":
+		if _, ok := c.Enemies[def.Enemy]; !ok {
+			unknown(SectionEnemies, def.Enemy)
+		}
+	case def.NPC != "":
+		if _, ok := c.NPCs[def.NPC]; !ok {
+			unknown(SectionNPCs, def.NPC)
+		}
+	case def.Item != "":
+		if _, ok := c.Items[def.Item]; !ok {
+			unknown(SectionItems, def.Item)
+		}
+	case def.SentientItem != "":
+		if _, ok := c.SentientItems[def.SentientItem]; !ok {
+			unknown(SectionSentientItems, def.SentientItem)
+		}
+	case def.Trigger != nil:
+		for _, e := range checkCommon(def.Trigger.Name, def.Trigger.Symbol, def.Trigger.Color) {
+			errs = append(errs, where+"trigger "+e)
+		}
+		var event struct {
+			Event string `json:"event"`
+		}
+		if err := json.Unmarshal(def.Trigger.Event, &event); err != nil {
+			errs = append(errs, where+"trigger event must be an object with an \"event\" field")
+		} else if domain.ParseEvent(event.Event) == domain.EventUnknown {
+			errs = append(errs, fmt.Sprintf("%strigger has unknown event %q", where, event.Event))
+		}
+	}
+	if len(errs) > 0 {
+		return VaultMarker{}, errs
+	}
+
+	marker := VaultMarker{Enemy: def.Enemy, NPC: def.NPC, Item: def.Item, SentientItem: def.SentientItem}
+	if t := def.Trigger; t != nil {
+		marker.Trigger = &VaultTrigger{
+			Name:        t.Name,
+			Render:      domain.RenderComponent{Symbol: t.Symbol[0], Color: t.Color},
+			Description: t.Description,
+			Event:       t.Event,
+		}
+	}
+	return marker, nil
+}
+
+// checkLayout проверяет макет: прямоугольник из известных клеток, двери на краю,
+// и все проходимые клетки достижимы от дверей
+func checkLayout(layout []string, legend map[string]markerDef) []string {
+	if len(layout) == 0 || len(layout[0]) == 0 {
+		return []string{"layout is empty"}
+	}
+
+	var errs []string
+	h, w := len(layout), len(layout[0])
+	for y, row := range layout {
+		if len(row) != w {
+			errs = append(errs, fmt.Sprintf("layout row %d is %d wide, expected %d", y, len(row), w))
+			continue
+		}
+		for x := 0; x < w; x++ {
+			c := row[x]
+			if c == VaultWall || c == VaultFloor || c == VaultDoor || c == VaultEmpty {
+				continue
+			}
+			if _, ok := legend[string(c)]; !ok {
+				errs = append(errs, fmt.Sprintf("layout %d,%d: glyph %q is not in the legend", x, y, c))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	passable := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < w && y < h && layout[y][x] != VaultWall && layout[y][x] != VaultEmpty
+	}
+
+	var queue []domain.Position
+	seen := make(map[domain.Position]bool)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if layout[y][x] != VaultDoor {
+				continue
+			}
+			// Коридор к двери идет снаружи прямоугольника макета, поэтому двери - только на его краю
+			if x != 0 && y != 0 && x != w-1 && y != h-1 {
+				errs = append(errs, fmt.Sprintf("layout %d,%d: door must be on the layout edge", x, y))
+			}
+			p := domain.Position{X: x, Y: y}
+			seen[p] = true
+			queue = append(queue, p)
+		}
+	}
+	if len(queue) == 0 {
+		return append(errs, "layout needs at least one door on its edge")
+	}
+
+	for k := 0; k < len(queue); k++ {
+		p := queue[k]
+		for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			next := domain.Position{X: p.X + d[0], Y: p.Y + d[1]}
+			if passable(next.X, next.Y) && !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if passable(x, y) && !seen[domain.Position{X: x, Y: y}] {
+				errs = append(errs, fmt.Sprintf("layout %d,%d: unreachable from the doors", x, y))
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,103 @@
+package dungeon
+
+import (
+	"cognitive-server/internal/domain"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRotateGrid_FullTurn(t *testing.T) {
+	layout := []string{"#+#", "#.#", "#g#", "###"}
+	grid := make([][]byte, len(layout))
+	for y, row := range layout {
+		grid[y] = []byte(row)
+	}
+
+	once := rotateGrid(grid)
+	if len(once) != 3 || len(once[0]) != 4 || string(once[1]) != "#g.+" {
+		t.Fatalf("quarter turn: %q", once)
+	}
+	for range 3 {
+		once = rotateGrid(once)
+	}
+	for y := range layout {
+		if string(once[y]) != layout[y] {
+			t.Fatalf("full turn changed the layout: %q", once)
+		}
+	}
+}
+
+func TestCheckLayout(t *testing.T) {
+	legend := map[string]markerDef{"g": {Enemy: "goblin"}}
+	cases := []struct {
+		layout []string
+		want   string
+	}{
+		{[]string{"#####", "#.g.#", "##+##"}, ""},
+		{[]string{" #+# ", "##.##", "#.g.#", "#####"}, ""},
+		{[]string{"#####", "#.+.#", "#####"}, "door must be on the layout edge"},
+		{[]string{"#####", "#.#.#", "##+##"}, "3,1: unreachable from the doors"},
+		{[]string{"#####", "#.x.#", "##+##"}, `glyph 'x' is not in the legend`},
+		{[]string{"#####", "#...#"}, "layout needs at least one door"},
+		{[]string{"###", "#.#.", "#+#"}, "layout row 1 is 4 wide"},
+	}
+	for _, c := range cases {
+		errs := strings.Join(checkLayout(c.layout, legend), "; ")
+		if c.want == "" && errs != "" || !strings.Contains(errs, c.want) {
+			t.Errorf("%q: got %q, want %q", c.layout, errs, c.want)
+		}
+	}
+}
+
+func TestWithVaults_StampedAndReachable(t *testing.T) {
+	dir := t.TempDir()
+	writePack(t, dir, "vault.json", `{"vaults": {"test_shrine": {
+		"layout": ["##+##", "#.A.#", "#$..#", "#####"],
+		"legend": {
+			"A": {"trigger": {"name": "Алтарь", "symbol": "_", "color": "#FFFFFF", "event": {"event": "RESTORE"}}},
+			"$": {"item": "gold"}
+		},
+		"minDepth": 1, "weight": 1000000
+	}}}`)
+	content, err := LoadContent(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stamped := make(map[bool]int)
+	for seed := int64(1); seed <= 200; seed++ {
+		for _, caves := range []bool{false, true} {
+			b := NewLevel(1, rand.New(rand.NewSource(seed)))
+			b.content = content
+			b.WithSize(MapWidth, MapHeight)
+			if caves {
+				b.WithCaves(CaveFillPercent, CaveSmoothSteps)
+			} else {
+				b.WithRooms(MaxRooms)
+			}
+			b.WithVaults(1)
+
+			var altar *domain.Entity
+			for k := range b.entities {
+				if b.entities[k].Type == domain.EntityTypeObject {
+					altar = &b.entities[k]
+				}
+			}
+			if altar == nil {
+				continue
+			}
+			stamped[caves]++
+			if len(b.entities) != 2 {
+				t.Errorf("seed %d caves %v: %d entities, want altar and gold", seed, caves, len(b.entities))
+			}
+			if err := b.Validate(); err != nil {
+				t.Errorf("seed %d caves %v: %v", seed, caves, err)
+			}
+		}
+	}
+	// Между комнатами породы хватает почти всегда, в пещерах - примерно на половине уровней
+	if stamped[false] < 190 || stamped[true] < 60 {
+		t.Errorf("vault stamped on %d room levels and %d cave levels of 200", stamped[false], stamped[true])
+	}
+}